@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// changedPkgs holds, with -affected or -affected-since, the packages with
+// changed files, which the other affected packages import.
+var changedPkgs map[string]bool
+
+// changedFiles returns the absolute paths of the changed files of
+// -affected, relative to cwd, and of those git diff --name-only reports
+// with -affected-since, relative to the top of the repository of cwd.
+func changedFiles(cwd string) ([]string, error) {
+	var files []string
+	for _, file := range affectedFiles.items() {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(cwd, file)
+		}
+		files = append(files, filepath.Clean(file))
+	}
+	if *affectedSince == "" {
+		return files, nil
+	}
+	// The path of the top relative to cwd keeps the symbolic links of cwd,
+	// as the directories of the packages do.
+	cdup, err := git(cwd, "rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	out, err := git(cwd, "diff", "--name-only", "--no-renames", *affectedSince, "--")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(out, "\n") {
+		if file != "" {
+			files = append(files, filepath.Join(cwd, cdup, filepath.FromSlash(file)))
+		}
+	}
+	return files, nil
+}
+
+// changedPackages returns the sorted packages of the graph the files are
+// part of: the package of the directory of a file or, for the files of
+// directories without one, such as testdata or embedded assets, the
+// nearest package above it; and for go.mod and go.sum files, those of every
+// package below their directory.
+func changedPackages(files []string) []string {
+	byDir := make(map[string][]string)
+	for name, pkg := range pkgs {
+		if !isIgnored(pkg) && pkg.Dir != "" {
+			dir := filepath.Clean(pkg.Dir)
+			byDir[dir] = append(byDir[dir], name)
+		}
+	}
+	changed := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if base := filepath.Base(file); base == "go.mod" || base == "go.sum" {
+			for d, names := range byDir {
+				if d == dir || strings.HasPrefix(d, dir+string(filepath.Separator)) {
+					for _, name := range names {
+						changed[name] = true
+					}
+				}
+			}
+			continue
+		}
+		for {
+			if names, ok := byDir[dir]; ok {
+				for _, name := range names {
+					changed[name] = true
+				}
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	var names []string
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// affectedGraph removes the packages that neither are changed nor import a
+// changed package, directly or not, leaving those a change can break, the
+// packages to build and test again.
+func affectedGraph(changed []string) {
+	rev := importers()
+	affected := reachable(changed, func(name string) []string {
+		return rev[name]
+	})
+	changedPkgs = make(map[string]bool)
+	for _, name := range changed {
+		changedPkgs[name] = true
+	}
+	for name := range pkgs {
+		if !affected[name] {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// writeAffectedTests writes to w the packages of pkgKeys whose tests are to
+// run again, for -affected-tests: those of the roots' own packages with
+// test files, the packages of the external tests drawn with -xtest included,
+// sorted and space-separated on one line as go test takes them. Nothing is
+// written if there are none, which scripts are to check for: go test given
+// no packages tests the one in the current directory.
+func writeAffectedTests(w io.Writer, pkgKeys []string) error {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot || pkg.TestGoFiles == 0 {
+			continue
+		}
+		path := name
+		if pkg.XTest {
+			path = strings.TrimSuffix(name, "_test")
+		}
+		if seen[path] || isExternal(path, rootPaths) {
+			continue
+		}
+		seen[path] = true
+		names = append(names, path)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	_, err := fmt.Fprintln(w, strings.Join(names, " "))
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// reportAliases writes how every dependency is named by the files importing
+// it to w. Only dependencies imported under a name other than their default
+// are listed; those imported under more than one name are flagged as
+// inconsistent, and dot imports are called out separately.
+func reportAliases(w io.Writer, pkgKeys []string) {
+	// names[path][name] lists the files importing path as name, where the
+	// empty name stands for the package's default name.
+	names := make(map[string]map[string][]string)
+	for _, key := range pkgKeys {
+		pkg := pkgs[key]
+		if isIgnored(pkg) || (pkg.Goroot && !*delveGoroot) {
+			continue
+		}
+		for _, f := range packageFiles(pkg) {
+			for _, imp := range f.Imports {
+				if ignored[imp.Path] {
+					continue
+				}
+				byName := names[imp.Path]
+				if byName == nil {
+					byName = make(map[string][]string)
+					names[imp.Path] = byName
+				}
+				byName[imp.Name] = append(byName[imp.Name], key+"/"+f.Name)
+			}
+		}
+	}
+
+	paths := []string{}
+	var dots []string
+	for path, byName := range names {
+		if len(byName) > 1 || byName[""] == nil {
+			paths = append(paths, path)
+		}
+		for _, file := range byName["."] {
+			dots = append(dots, fmt.Sprintf("%s in %s", path, file))
+		}
+	}
+	sort.Strings(paths)
+	sort.Strings(dots)
+
+	if len(paths) == 0 {
+		fmt.Fprintln(w, "no renamed imports")
+	} else {
+		fmt.Fprintln(w, "import names:")
+	}
+	for _, path := range paths {
+		byName := names[path]
+		aliases := []string{}
+		for name := range byName {
+			aliases = append(aliases, name)
+		}
+		sort.Strings(aliases)
+
+		note := ""
+		if nonBlank(byName) > 1 {
+			note = " (inconsistent)"
+		}
+		fmt.Fprintf(w, "\t%s%s\n", path, note)
+		for _, name := range aliases {
+			files := byName[name]
+			sort.Strings(files)
+			label := name
+			if label == "" {
+				label = "(default)"
+			}
+			fmt.Fprintf(w, "\t\t%s: %d files: %s\n", label, len(files), strings.Join(files, ", "))
+		}
+	}
+	if len(dots) > 0 {
+		fmt.Fprintln(w, "dot imports:")
+		for _, d := range dots {
+			fmt.Fprintf(w, "\t%s\n", d)
+		}
+	}
+}
+
+// nonBlank returns the number of distinct names, other than the blank
+// identifier, under which a package is imported.
+func nonBlank(byName map[string][]string) int {
+	n := len(byName)
+	if _, ok := byName["_"]; ok {
+		n--
+	}
+	return n
+}
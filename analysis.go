@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// taint computes, for every scanned package, the sorted set of labels that it
+// carries either directly, as reported by direct, or through any of the
+// packages it imports.
+func taint(direct func(pkg *node) []string) map[string][]string {
+	imports := func(name string) []string {
+		pkg := pkgs[name]
+		if pkg == nil || isIgnored(pkg) || (pkg.Goroot && !*delveGoroot) {
+			return nil
+		}
+		return getImports(pkg)
+	}
+
+	// The packages of an import cycle, introduced by test imports, carry
+	// the same labels, so they are computed for every strongly connected
+	// component at once, as Tarjan's algorithm completes it: all it imports
+	// outside of it is complete by then.
+	sets := make(map[string]map[string]bool)
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	next := 0
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = next
+		low[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, imp := range imports(name) {
+			if _, ok := index[imp]; !ok {
+				connect(imp)
+				if low[imp] < low[name] {
+					low[name] = low[imp]
+				}
+			} else if onStack[imp] && index[imp] < low[name] {
+				low[name] = index[imp]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		var comp []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			comp = append(comp, top)
+			if top == name {
+				break
+			}
+		}
+		set := make(map[string]bool)
+		for _, member := range comp {
+			if pkg := pkgs[member]; pkg != nil && !isIgnored(pkg) {
+				for _, l := range direct(pkg) {
+					set[l] = true
+				}
+			}
+			for _, imp := range imports(member) {
+				for l := range sets[imp] {
+					set[l] = true
+				}
+			}
+		}
+		for _, member := range comp {
+			sets[member] = set
+		}
+	}
+	for name := range pkgs {
+		if _, ok := index[name]; !ok {
+			connect(name)
+		}
+	}
+
+	result := make(map[string][]string)
+	for name := range pkgs {
+		var labels []string
+		for l := range sets[name] {
+			labels = append(labels, l)
+		}
+		if len(labels) > 0 {
+			sort.Strings(labels)
+			result[name] = labels
+		}
+	}
+	return result
+}
+
+// isInternal reports whether path is an internal package, importable only
+// from within the tree rooted at the parent of its internal element.
+func isInternal(path string) bool {
+	return strings.HasPrefix(path, "internal/") || strings.HasSuffix(path, "/internal") ||
+		strings.Contains(path, "/internal/") || path == "internal"
+}
+
+// isGorootVendored reports whether pkg is one of the packages the standard
+// library vendors, as vendor/golang.org/x/net/..., which only it may import.
+func isGorootVendored(pkg *node) bool {
+	return pkg.Goroot && strings.HasPrefix(pkg.ImportPath, "vendor/")
+}
+
+// hasPathPrefix reports whether path is prefix or a package below it.
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || len(path) > len(prefix) && path[len(prefix)] == '/' && path[:len(prefix)] == prefix
+}
+
+// reachable returns the set of packages reachable from the packages in from,
+// including themselves, by following next.
+func reachable(from []string, next func(name string) []string) map[string]bool {
+	seen := make(map[string]bool)
+	stack := append([]string(nil), from...)
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		stack = append(stack, next(name)...)
+	}
+	return seen
+}
+
+// importers returns the drawn reverse edges of the graph: for every package,
+// the packages that import it.
+func importers() map[string][]string {
+	rev := make(map[string][]string)
+	for name, pkg := range pkgs {
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			rev[imp] = append(rev[imp], name)
+		}
+	}
+	return rev
+}
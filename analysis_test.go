@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaintCycle(t *testing.T) {
+	defer func(saved map[string]*node) { pkgs = saved }(pkgs)
+	// a and b import each other, as through test imports, and only c,
+	// imported by b, carries a label.
+	pkgs = map[string]*node{
+		"x/a": {ImportPath: "x/a", Imports: []string{"x/b"}},
+		"x/b": {ImportPath: "x/b", Imports: []string{"x/a", "x/c"}},
+		"x/c": {ImportPath: "x/c"},
+		"x/d": {ImportPath: "x/d", Imports: []string{"x/a"}},
+	}
+	direct := func(pkg *node) []string {
+		if pkg.ImportPath == "x/c" {
+			return []string{"label"}
+		}
+		return nil
+	}
+	want := map[string][]string{"x/a": {"label"}, "x/b": {"label"}, "x/c": {"label"}, "x/d": {"label"}}
+	// The labels used to depend on the order the packages were visited in.
+	for i := 0; i < 20; i++ {
+		if got := taint(direct); !reflect.DeepEqual(got, want) {
+			t.Fatalf("taint = %v, want %v", got, want)
+		}
+	}
+}
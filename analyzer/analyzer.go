@@ -0,0 +1,127 @@
+// Package analyzer reports the dependency policy violations godepgraph
+// checks as go/analysis diagnostics, so that the same rules can run under go
+// vet:
+//
+//	go install github.com/kisielk/godepgraph/cmd/godepgraph-vet
+//	go vet -vettool=$(which godepgraph-vet) -godepgraph.deny 'example.com/app/domain/...=>example.com/app/infra/...' ./...
+//
+// Import cycles need no check of their own here, since the compiler rejects
+// them.
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/kisielk/godepgraph/pattern"
+)
+
+// Analyzer reports imports forbidden by its -deny rules and, with
+// -max-chain, the packages starting a chain of imports longer than that.
+var Analyzer = &analysis.Analyzer{
+	Name:      "godepgraph",
+	Doc:       "report imports violating godepgraph dependency policies",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(chainFact)},
+}
+
+var (
+	denyRules denyFlag
+	maxChain  int
+)
+
+func init() {
+	Analyzer.Flags.Var(&denyRules, "deny", "forbid the imports matching the rule from=>to, where both sides are import path prefixes or globs; may be repeated")
+	Analyzer.Flags.IntVar(&maxChain, "max-chain", 0, "report packages starting a chain of imports longer than this")
+}
+
+type denyRule struct {
+	from, to pattern.Pattern
+}
+
+// denyFlag is the repeatable -deny flag.
+type denyFlag []denyRule
+
+func (f *denyFlag) String() string {
+	var rules []string
+	for _, r := range *f {
+		rules = append(rules, r.from.String()+"=>"+r.to.String())
+	}
+	return strings.Join(rules, ",")
+}
+
+func (f *denyFlag) Set(s string) error {
+	i := strings.Index(s, "=>")
+	if i < 0 {
+		return fmt.Errorf("malformed rule %q, want from=>to", s)
+	}
+	from, err := pattern.Parse(strings.TrimSpace(s[:i]))
+	if err != nil {
+		return err
+	}
+	to, err := pattern.Parse(strings.TrimSpace(s[i+2:]))
+	if err != nil {
+		return err
+	}
+	*f = append(*f, denyRule{from, to})
+	return nil
+}
+
+// A chainFact records the longest chain of imports starting at a package,
+// not counting the package itself.
+type chainFact struct {
+	Chain []string
+}
+
+func (*chainFact) AFact() {}
+
+func (f *chainFact) String() string {
+	return fmt.Sprintf("chain of %d imports", len(f.Chain))
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	path := pass.Pkg.Path()
+	specs := make(map[string]*ast.ImportSpec)
+	for _, f := range pass.Files {
+		for _, spec := range f.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			if _, ok := specs[imp]; !ok {
+				specs[imp] = spec
+			}
+			for _, r := range denyRules {
+				if r.from.Match(path) && r.to.Match(imp) {
+					pass.Reportf(spec.Pos(), "import of %s denied by %s=>%s", imp, r.from, r.to)
+				}
+			}
+		}
+	}
+
+	var longest []string
+	var via string
+	for _, imp := range pass.Pkg.Imports() {
+		var f chainFact
+		pass.ImportPackageFact(imp, &f)
+		if chain := append([]string{imp.Path()}, f.Chain...); len(chain) > len(longest) {
+			longest, via = chain, imp.Path()
+		}
+	}
+	pass.ExportPackageFact(&chainFact{Chain: longest})
+
+	// Only the package at the start of the shortest chain over the limit
+	// is reported, rather than every package importing it too.
+	if maxChain > 0 && len(longest) == maxChain+1 {
+		pos := pass.Files[0].Package
+		if spec := specs[via]; spec != nil {
+			pos = spec.Pos()
+		}
+		pass.Reportf(pos, "chain of %d imports, over the limit of %d: %s -> %s", len(longest), maxChain, path, strings.Join(longest, " -> "))
+	}
+	return nil, nil
+}
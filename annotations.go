@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kisielk/godepgraph/pattern"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// An annotationRule gives the packages matching Pattern the annotations
+// Attrs.
+type annotationRule struct {
+	Pattern pattern.Pattern
+	Attrs   map[string]string
+}
+
+// annotationRules holds the rules read from the -annotations file.
+var annotationRules []annotationRule
+
+// annotationName matches the names of annotations, which become properties
+// of the graph databases too.
+var annotationName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// readAnnotations reads an annotations file, mapping import path prefixes
+// or globs to the annotations of the packages matching them. Files named
+// .json hold an object of objects of strings; others are written in the
+// subset of YAML of configuration files:
+//
+//	github.com/acme/app/legacy/...:
+//	  owner: payments
+//	  status: deprecated
+//	  severity: high
+//	  notes: "use github.com/acme/app/billing"
+//
+// Owner, status, severity and notes have a meaning to the writers, but any
+// annotation may be given.
+func readAnnotations(path string) ([]annotationRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if filepath.Ext(path) == ".json" {
+		return parseAnnotationsJSON(f)
+	}
+	return parseAnnotationsYAML(f)
+}
+
+// parseAnnotationsJSON parses the rules of an annotations file in JSON, in
+// the order they are written.
+func parseAnnotationsJSON(r io.Reader) ([]annotationRule, error) {
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("want an object of patterns")
+	}
+	var rules []annotationRule
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := t.(string)
+		var attrs map[string]string
+		if err := dec.Decode(&attrs); err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		rule, err := newAnnotationRule(key, attrs)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseAnnotationsYAML parses the rules of an annotations file in YAML: a
+// pattern ending in a colon, followed by the indented annotations of its
+// packages.
+func parseAnnotationsYAML(r io.Reader) ([]annotationRule, error) {
+	var rules []annotationRule
+	var key string
+	var attrs map[string]string
+	flush := func() error {
+		if attrs == nil {
+			return nil
+		}
+		rule, err := newAnnotationRule(key, attrs)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+		return nil
+	}
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := stripComment(s.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("line %d: expected pattern:", lineno)
+			}
+			if err := flush(); err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineno, err)
+			}
+			var err error
+			if key, err = configScalar(strings.TrimSpace(trimmed[:len(trimmed)-1])); err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineno, err)
+			}
+			attrs = make(map[string]string)
+			continue
+		}
+
+		if attrs == nil {
+			return nil, fmt.Errorf("line %d: annotation outside of a pattern", lineno)
+		}
+		i := strings.Index(trimmed, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected name: value", lineno)
+		}
+		v, err := configScalar(strings.TrimSpace(trimmed[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+		attrs[strings.TrimSpace(trimmed[:i])] = v
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// newAnnotationRule returns the rule giving the packages matching the
+// pattern key the annotations attrs.
+func newAnnotationRule(key string, attrs map[string]string) (annotationRule, error) {
+	p, err := pattern.Parse(key)
+	if err != nil {
+		return annotationRule{}, fmt.Errorf("%s: %s", key, err)
+	}
+	for name := range attrs {
+		if !annotationName.MatchString(name) {
+			return annotationRule{}, fmt.Errorf("%s: invalid annotation name %q", key, name)
+		}
+	}
+	return annotationRule{p, attrs}, nil
+}
+
+// annotationsOf returns the annotations of the package path, those of every
+// rule matching it, of which later ones override earlier ones.
+func annotationsOf(path string) map[string]string {
+	var attrs map[string]string
+	for _, r := range annotationRules {
+		if !r.Pattern.Match(path) {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		for k, v := range r.Attrs {
+			attrs[k] = v
+		}
+	}
+	return attrs
+}
+
+// decorateAnnotations adds the annotations of a package to its node as the
+// writers of package render do: its owner and status on a line of its label,
+// every annotation in its tooltip, its severity as its fill and its status
+// as its style.
+func decorateAnnotations(a *attrs, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+	if l := render.AnnotationLabel(annotations); l != "" {
+		a.appendAttr("label", dotEscape(l), `\n`)
+	}
+	if c := render.AnnotationColor(annotations); c != "" {
+		a.set("fillcolor", c)
+	}
+	if s := render.AnnotationStyle(annotations); s != "" {
+		a.addStyle(s)
+	}
+	for _, line := range render.AnnotationLines(annotations) {
+		a.appendAttr("tooltip", dotEscape(line), `\n`)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// keptElements matches the path elements anonymizedPath keeps, those the go
+// command gives a meaning: internal, vendor and testdata directories and
+// major version suffixes, as well as . and .. of local paths and the empty
+// first element of absolute ones.
+var keptElements = regexp.MustCompile(`^(|internal|vendor|testdata|v[0-9]+|_|\.|\.\.)$`)
+
+// anonymizedPath returns the pseudonym of the import path path with
+// -anonymize: every path element replaced by a keyed hash of the path up to
+// it, so that the packages of a directory or a module keep sharing a
+// prefix, but the elements of keptElements. Packages of the standard
+// library keep their paths, and the first element of other paths stays a
+// domain name if it was one.
+func anonymizedPath(path string) string {
+	if path == "C" || isGorootPath(path) {
+		return path
+	}
+	suffix := ""
+	if strings.HasSuffix(path, "_test") {
+		path, suffix = strings.TrimSuffix(path, "_test"), "_test"
+	}
+	orig := strings.Split(path, "/")
+	elems := make([]string, len(orig))
+	for i, elem := range orig {
+		if keptElements.MatchString(elem) {
+			elems[i] = elem
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(*anonymizeKey))
+		mac.Write([]byte(strings.Join(orig[:i+1], "/")))
+		elems[i] = hex.EncodeToString(mac.Sum(nil))[:8]
+		if i == 0 && strings.Contains(elem, ".") {
+			elems[i] += ".example"
+		}
+	}
+	return strings.Join(elems, "/") + suffix
+}
+
+// anonymizeGraph renames the packages and modules of the graph after their
+// anonymizedPath, and returns roots renamed alike, for sharing the graph
+// without exposing its import paths. What else names the packages or their
+// files is dropped: their documentation, import comments, embed patterns,
+// import positions and the errors of the packages that failed to load.
+// Directories are kept for the analyses that read them, but aren't written:
+// the outputs writing them, such as the URLs of -links, are rejected.
+func anonymizeGraph(roots []string) []string {
+	roots = mergePackages(anonymizedPath, roots, false)
+	for _, pkg := range pkgs {
+		pkg.Doc = ""
+		pkg.ImportComment = ""
+		pkg.EmbedPatterns = nil
+		pkg.ImportPos = nil
+	}
+	for name := range failures {
+		failures[name] = errors.New("failed to load")
+	}
+
+	renamed := make(map[string]*moduleInfo, len(pkgModules))
+	for name, m := range pkgModules {
+		renamed[anonymizedPath(name)] = m
+	}
+	pkgModules = renamed
+	if modules != nil {
+		byPath := make(map[string]*moduleInfo, len(modules))
+		for _, m := range modules {
+			anonymizeModule(m)
+			byPath[m.Path] = m
+		}
+		modules = byPath
+		modulePaths = modulePaths[:0]
+		for path := range modules {
+			modulePaths = append(modulePaths, path)
+		}
+	}
+	for _, m := range pkgModules {
+		anonymizeModule(m)
+	}
+	for i, r := range modReqs {
+		modReqs[i] = modRequirement{anonymizedPath(r.From), anonymizedPath(r.To)}
+	}
+	return roots
+}
+
+// anonymizeModule renames m, its replacement and its latest versions, once,
+// after their anonymizedPath.
+func anonymizeModule(m *moduleInfo) {
+	if m == nil || anonymized[m] {
+		return
+	}
+	anonymized[m] = true
+	m.Path = anonymizedPath(m.Path)
+	anonymizeModule(m.Replace)
+	anonymizeModule(m.Update)
+	anonymizeModule(m.Major)
+}
+
+// anonymized records the modules anonymizeModule renamed, which modules and
+// pkgModules may share.
+var anonymized = make(map[*moduleInfo]bool)
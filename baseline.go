@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// baseline holds the external packages read from the -baseline file.
+var baseline map[string]bool
+
+// externalPackages returns the sorted external packages of the graph: those
+// outside the standard library and the main modules or, without module
+// information, outside the trees of the roots.
+func externalPackages(pkgKeys, roots []string) []string {
+	var external []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if !isIgnored(pkg) && !pkg.Goroot && isExternal(name, roots) {
+			external = append(external, name)
+		}
+	}
+	return external
+}
+
+// isExternal reports whether the package at path, outside the standard
+// library, is outside the main modules or, without module information,
+// outside the trees of the roots.
+func isExternal(path string, roots []string) bool {
+	if m := packageModule(path); m != nil {
+		return !m.Main
+	}
+	return !underRoot(path, roots)
+}
+
+func underRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		if hasPathPrefix(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBaselineFile writes the external packages to the file at path.
+func writeBaselineFile(path string, external []string) error {
+	var b strings.Builder
+	b.WriteString("# External packages reached by the graph, written by godepgraph -write-baseline.\n")
+	for _, name := range external {
+		b.WriteString(name + "\n")
+	}
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// readBaselineFile reads a baseline file written by writeBaselineFile.
+func readBaselineFile(path string) (map[string]bool, error) {
+	names, err := readRootsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set, nil
+}
+
+// newExternal returns the external packages that aren't in the baseline.
+func newExternal(pkgKeys, roots []string) []string {
+	var added []string
+	for _, name := range externalPackages(pkgKeys, roots) {
+		if !baseline[name] {
+			added = append(added, name)
+		}
+	}
+	return added
+}
+
+// checkBaseline reports the external packages missing from the -baseline
+// file.
+func checkBaseline(pkgKeys []string) []violation {
+	var vs []violation
+	for _, name := range newExternal(pkgKeys, rootPaths) {
+		vs = append(vs, violation{"baseline", name})
+	}
+	return vs
+}
+
+// reportBaseline writes the external packages missing from the baseline,
+// with their importers, to w and returns their number.
+func reportBaseline(w io.Writer, pkgKeys, roots []string) int {
+	added := newExternal(pkgKeys, roots)
+	rev := importers()
+	for _, name := range added {
+		importedBy := rev[name]
+		sort.Strings(importedBy)
+		fmt.Fprintf(w, "new external package: %s", name)
+		if len(importedBy) > 0 {
+			fmt.Fprintf(w, " (imported by %s)", strings.Join(importedBy, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+	return len(added)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A bazelRule is a go_library, go_binary or go_test rule of a BUILD file,
+// with the string arguments of the call and the strings of its list
+// arguments. Arguments of other kinds, such as select calls or variables,
+// are left out, as are the lists and calls concatenated to a list.
+type bazelRule struct {
+	Kind    string
+	Strings map[string]string
+	Lists   map[string][]string
+}
+
+// bazelRuleKinds are the rules of rules_go whose deps are checked.
+var bazelRuleKinds = map[string]bool{"go_library": true, "go_binary": true, "go_test": true}
+
+// findBazelWorkspace walks up from dir looking for the root of a Bazel
+// workspace, marked by a MODULE.bazel, WORKSPACE.bazel or WORKSPACE file,
+// and returns it, or "" if there is none.
+func findBazelWorkspace(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		for _, name := range []string{"MODULE.bazel", "WORKSPACE.bazel", "WORKSPACE"} {
+			if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readBuildFile returns the path of the BUILD.bazel or BUILD file of dir
+// and its Go rules, or "" if dir has none.
+func readBuildFile(dir string) (string, []bazelRule, error) {
+	for _, name := range []string{"BUILD.bazel", "BUILD"} {
+		p := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		rules, err := parseBuildFile(string(data))
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s", p, err)
+		}
+		return p, rules, nil
+	}
+	return "", nil, nil
+}
+
+// parseBuildFile returns the Go rules called at the top level of the
+// Starlark source src. Everything else is skipped.
+func parseBuildFile(src string) ([]bazelRule, error) {
+	toks, err := starlarkTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	var rules []bazelRule
+	for i := 0; i+1 < len(toks); i++ {
+		if !bazelRuleKinds[toks[i]] || toks[i+1] != "(" || i > 0 && toks[i-1] == "." {
+			continue
+		}
+		r := bazelRule{Kind: toks[i], Strings: make(map[string]string), Lists: make(map[string][]string)}
+		i += 2
+		for i < len(toks) && toks[i] != ")" {
+			// An argument name = expression, up to the comma ending it.
+			name := ""
+			if i+1 < len(toks) && toks[i+1] == "=" && isStarlarkIdent(toks[i]) {
+				name = toks[i]
+				i += 2
+			}
+			start, depth := i, 0
+		scan:
+			for ; i < len(toks); i++ {
+				switch toks[i] {
+				case "(", "[", "{":
+					depth++
+				case ")", "]", "}":
+					if depth == 0 {
+						break scan
+					}
+					depth--
+				case ",":
+					if depth == 0 {
+						break scan
+					}
+				}
+			}
+			if name != "" {
+				expr := toks[start:i]
+				switch {
+				case len(expr) == 1 && isStarlarkString(expr[0]):
+					r.Strings[name] = expr[0][1:]
+				case len(expr) > 0 && expr[0] == "[":
+					// The strings of the first list only.
+					for _, t := range expr[1:] {
+						if t == "]" {
+							break
+						}
+						if isStarlarkString(t) {
+							r.Lists[name] = append(r.Lists[name], t[1:])
+						}
+					}
+				}
+			}
+			if i < len(toks) && toks[i] == "," {
+				i++
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// starlarkTokens splits src into identifiers, numbers, punctuation and
+// strings, the last marked by a leading quote and unquoted, leaving out
+// comments and white space.
+func starlarkTokens(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			quote := src[i : i+1]
+			if strings.HasPrefix(src[i:], strings.Repeat(quote, 3)) {
+				quote = strings.Repeat(quote, 3)
+			}
+			var b strings.Builder
+			j := i + len(quote)
+			for ; j < len(src) && !strings.HasPrefix(src[j:], quote); j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, `"`+b.String())
+			i = j + len(quote)
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		default:
+			toks = append(toks, src[i:i+1])
+			i++
+		}
+	}
+	return toks, nil
+}
+
+func isStarlarkString(tok string) bool {
+	return strings.HasPrefix(tok, `"`)
+}
+
+func isStarlarkIdent(tok string) bool {
+	return tok != "" && !isStarlarkString(tok) && (tok[0] == '_' || tok[0] >= 'a' && tok[0] <= 'z' || tok[0] >= 'A' && tok[0] <= 'Z')
+}
+
+// bazelPackageKey returns the repository and package a label points at, as
+// @repo//dir, or //dir in the main repository, given the package of the
+// BUILD file it appears in. Target names are dropped, as Gazelle names
+// libraries either go_default_library or after their directory.
+func bazelPackageKey(label, pkg string) string {
+	repo := ""
+	if strings.HasPrefix(label, "@") {
+		label = strings.TrimLeft(label, "@")
+		i := strings.Index(label, "//")
+		if i < 0 {
+			// @repo, the target of the root package named after it.
+			return "@" + strings.SplitN(label, ":", 2)[0] + "//"
+		}
+		repo, label = label[:i], label[i:]
+	}
+	if strings.HasPrefix(label, "//") {
+		label = label[2:]
+		if i := strings.Index(label, ":"); i >= 0 {
+			label = label[:i]
+		}
+	} else {
+		// A relative label, :name or name, of a target of pkg.
+		label = pkg
+	}
+	if repo != "" {
+		return "@" + repo + "//" + label
+	}
+	return "//" + label
+}
+
+// bazelRepoName returns the name Gazelle gives the go_repository of the
+// module path modPath: its domain name reversed and its other elements,
+// lowercased and joined by underscores.
+func bazelRepoName(modPath string) string {
+	parts := strings.Split(strings.ToLower(modPath), "/")
+	labels := strings.Split(parts[0], ".")
+	var reversed []string
+	for i := len(labels) - 1; i >= 0; i-- {
+		reversed = append(reversed, labels[i])
+	}
+	repo := strings.Join(append(reversed, parts[1:]...), ".")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(repo)
+}
+
+// importKey returns the Bazel package providing the import imp, as
+// bazelPackageKey does for labels: its directory in the workspace at root
+// for the packages in it, and otherwise its directory in the repository
+// Gazelle names after its module, or after its repository as guessed from
+// the import path outside module mode.
+func importKey(root, imp string) string {
+	if pkg := pkgs[imp]; pkg != nil && pkg.Dir != "" {
+		if rel, err := filepath.Rel(root, pkg.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				rel = ""
+			}
+			return "//" + filepath.ToSlash(rel)
+		}
+	}
+	modPath := packageOwner(jsonPackage{ImportPath: imp})
+	if m := packageModule(imp); m != nil {
+		modPath = m.Path
+	}
+	dir := strings.TrimPrefix(strings.TrimPrefix(imp, modPath), "/")
+	return "@" + bazelRepoName(modPath) + "//" + dir
+}
+
+// checkBazel compares the deps of the Go rules of the BUILD files of the
+// scanned packages in the Bazel workspace at root with the imports of the
+// packages, and writes the discrepancies to w: the imports no dep of a rule
+// provides, and the deps providing no import. The deps of go_library and
+// go_binary rules are compared with the imports of the package but those
+// only its tests make, and with -t those of go_test rules with the imports
+// of the tests, only those only the tests make if the rule embeds the
+// library.
+func checkBazel(w io.Writer, root string) error {
+	var names []string
+	for name, pkg := range pkgs {
+		if pkg.Goroot || pkg.XTest || isIgnored(pkg) || pkg.Dir == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(root, pkg.Dir); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Bazel workspace: %s\n", root)
+	var noBuild []string
+	problems := 0
+	for _, name := range names {
+		pkg := pkgs[name]
+		file, rules, err := readBuildFile(pkg.Dir)
+		if err != nil {
+			return err
+		}
+		if file == "" {
+			noBuild = append(noBuild, name)
+			continue
+		}
+		rel, _ := filepath.Rel(root, pkg.Dir)
+		dir := filepath.ToSlash(rel)
+		if dir == "." {
+			dir = ""
+		}
+
+		var code, tests, testOnly []string
+		for _, imp := range getImports(pkg) {
+			if isGorootPath(imp) || imp == "C" || ignored[imp] {
+				continue
+			}
+			if isTestImport(pkg, imp) {
+				testOnly = append(testOnly, imp)
+			} else {
+				code = append(code, imp)
+			}
+			if isTestImport(pkg, imp) || isSharedTestImport(pkg, imp) {
+				tests = append(tests, imp)
+			}
+		}
+		if xt := pkgs[name+"_test"]; xt != nil && xt.XTest {
+			for _, imp := range getImports(xt) {
+				if !isGorootPath(imp) && imp != "C" && !ignored[imp] && imp != name && !containsString(tests, imp) {
+					tests = append(tests, imp)
+					if !containsString(code, imp) {
+						testOnly = append(testOnly, imp)
+					}
+				}
+			}
+		}
+
+		for _, r := range rules {
+			want := code
+			if r.Kind == "go_test" {
+				if !*includeTests {
+					continue
+				}
+				want = tests
+				if len(r.Lists["embed"]) > 0 {
+					want = testOnly
+				}
+			}
+			label := "//" + dir + ":" + r.Strings["name"]
+			missingDeps, staleDeps := diffBazelDeps(root, dir, r.Lists["deps"], want)
+			for _, imp := range missingDeps {
+				fmt.Fprintf(w, "%s: missing dep %s for %s\n", label, importKey(root, imp), imp)
+				problems++
+			}
+			for _, dep := range staleDeps {
+				fmt.Fprintf(w, "%s: stale dep %s\n", label, dep)
+				problems++
+			}
+		}
+	}
+	if len(noBuild) > 0 {
+		fmt.Fprintln(w, "packages without a BUILD file:")
+		for _, name := range noBuild {
+			fmt.Fprintf(w, "\t%s\n", name)
+		}
+	}
+	if problems == 0 && len(noBuild) == 0 {
+		fmt.Fprintln(w, "no discrepancies found")
+	}
+	return nil
+}
+
+// diffBazelDeps returns the imports of want that no label of deps, of a
+// rule of the Bazel package dir, provides, and the labels of deps that
+// provide no import of want.
+func diffBazelDeps(root, dir string, deps, want []string) (missingDeps, staleDeps []string) {
+	declared := make(map[string]bool)
+	for _, dep := range deps {
+		declared[bazelPackageKey(dep, dir)] = true
+	}
+	wanted := make(map[string]bool)
+	for _, imp := range want {
+		key := importKey(root, imp)
+		wanted[key] = true
+		if !declared[key] {
+			missingDeps = append(missingDeps, imp)
+		}
+	}
+	for _, dep := range deps {
+		if !wanted[bazelPackageKey(dep, dir)] {
+			staleDeps = append(staleDeps, dep)
+		}
+	}
+	sort.Strings(missingDeps)
+	sort.Strings(staleDeps)
+	return missingDeps, staleDeps
+}
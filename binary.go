@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// loadBinary fills pkgs with the packages linked into the Go executable at
+// path and returns the import path of its main package.
+//
+// The package set comes from the binary's symbol table. The imports of each
+// package are then resolved by listing the packages in a scratch module that
+// requires exactly the module versions recorded in the binary's build info,
+// and restricted to packages present in the binary. Packages of the binary's
+// own module are resolved from the working directory, if it is a checkout of
+// that module. If the main package cannot be resolved, it is connected to the
+// linked packages nothing else imports with dashed, inferred edges.
+func loadBinary(path string) (string, error) {
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	names, err := binaryPackages(path)
+	if err != nil {
+		return "", err
+	}
+	mainPath := bi.Path
+	if mainPath == "" {
+		mainPath = "main"
+	}
+	linked := make(map[string]bool)
+	for _, n := range names {
+		if n == "main" {
+			n = mainPath
+		}
+		linked[n] = true
+	}
+
+	recordBinaryModules(bi)
+
+	resolved, err := resolveBinaryPackages(bi, linked)
+	if err != nil {
+		log.Printf("warning: cannot resolve imports of %s: %s", path, err)
+		resolved = make(map[string]*build.Package)
+	}
+	// Packages of the binary's own module can only be found in its source,
+	// which is there if godepgraph runs inside a checkout.
+	var local []string
+	for name := range linked {
+		if resolved[name] == nil && !isGorootPath(name) {
+			local = append(local, name)
+		}
+	}
+	if len(local) > 0 {
+		sort.Strings(local)
+		if cwd, err := os.Getwd(); err == nil {
+			if lps, err := goList(cwd, nil, local...); err == nil {
+				for _, lp := range lps {
+					if pkg, err := lp.buildPackage(); err == nil && linked[pkg.ImportPath] {
+						resolved[pkg.ImportPath] = pkg
+					}
+				}
+			}
+		}
+	}
+
+	imported := make(map[string]bool)
+	for name := range linked {
+		pkg := resolved[name]
+		if pkg == nil {
+			pkg = &build.Package{ImportPath: name, Goroot: isGorootPath(name)}
+		} else {
+			var imports []string
+			for _, imp := range pkg.Imports {
+				if linked[imp] {
+					imports = append(imports, imp)
+					imported[imp] = true
+				}
+			}
+			pkg.Imports = imports
+		}
+		pkgs[name] = newNode(pkg)
+	}
+
+	inferred = make(map[[2]string]bool)
+	if resolved[mainPath] == nil {
+		for other := range linked {
+			if other != mainPath && !imported[other] && !pkgs[other].Goroot {
+				pkgs[mainPath].Imports = append(pkgs[mainPath].Imports, other)
+				inferred[[2]string{mainPath, other}] = true
+			}
+		}
+		sort.Strings(pkgs[mainPath].Imports)
+	}
+	return mainPath, nil
+}
+
+// inferred holds the edges that loadBinary had to guess.
+var inferred map[[2]string]bool
+
+// recordBinaryModules makes the modules of the build info available to the
+// module-aware features.
+func recordBinaryModules(bi *buildinfo.BuildInfo) {
+	modules = make(map[string]*moduleInfo)
+	modulePaths = nil
+	add := func(m *moduleInfo) {
+		modules[m.Path] = m
+		modulePaths = append(modulePaths, m.Path)
+	}
+	add(&moduleInfo{Path: bi.Main.Path, Version: bi.Main.Version, Main: true})
+	for _, d := range bi.Deps {
+		m := &moduleInfo{Path: d.Path, Version: d.Version}
+		if d.Replace != nil {
+			m.Replace = &moduleInfo{Path: d.Replace.Path, Version: d.Replace.Version}
+		}
+		add(m)
+	}
+}
+
+// resolveBinaryPackages lists the linked packages in a scratch module pinned
+// to the module versions of bi, using the build settings recorded in it.
+func resolveBinaryPackages(bi *buildinfo.BuildInfo, linked map[string]bool) (map[string]*build.Package, error) {
+	dir, err := ioutil.TempDir("", "godepgraph-binary")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var gomod bytes.Buffer
+	fmt.Fprintln(&gomod, "module godepgraph.invalid/binary")
+	var requires []string
+	if bi.Main.Path != "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		requires = append(requires, bi.Main.Path+" "+bi.Main.Version)
+	}
+	for _, d := range bi.Deps {
+		requires = append(requires, d.Path+" "+d.Version)
+		if r := d.Replace; r != nil && r.Version != "" {
+			fmt.Fprintf(&gomod, "replace %s => %s %s\n", d.Path, r.Path, r.Version)
+		}
+	}
+	for _, r := range requires {
+		fmt.Fprintf(&gomod, "require %s\n", r)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), gomod.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for name := range linked {
+		args = append(args, name)
+	}
+	sort.Strings(args)
+
+	env := append(goEnv(), "GOFLAGS="+withModFlag("mod"), "GOWORK=off")
+	var tags string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "GOOS", "GOARCH", "CGO_ENABLED":
+			env = append(env, s.Key+"="+s.Value)
+		case "-tags":
+			tags = s.Value
+		}
+	}
+
+	cmdArgs := []string{"list", "-e", "-json"}
+	if tags != "" {
+		cmdArgs = append(cmdArgs, "-tags="+tags)
+	}
+	cmd := exec.Command(goCmd, append(append(cmdArgs, "--"), args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	lps, err := decodeListPackages(out)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]*build.Package)
+	for _, lp := range lps {
+		if pkg, err := lp.buildPackage(); err == nil && linked[pkg.ImportPath] {
+			resolved[pkg.ImportPath] = pkg
+		}
+	}
+	return resolved, nil
+}
+
+// binaryPackages returns the sorted import paths of the packages that have
+// functions in the Go executable at path. The main package is reported as
+// "main".
+func binaryPackages(path string) ([]string, error) {
+	pclntab, text, err := readPclntab(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(pclntab, text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read symbol table: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, fn := range table.Funcs {
+		p := symbolPackage(fn.Sym)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// cCloneRe matches the suffixes the C compiler gives the parts and clones
+// of a function.
+var cCloneRe = regexp.MustCompile(`^(cold|(cold|part|constprop|isra|lto_priv)\.[0-9]+)$`)
+
+// symbolPackage returns the import path of the package of the symbol sym of
+// a Go executable, or "" for the symbols of no package: those the compiler
+// and the linker generate, such as go:buildid, go.shape instantiations, type
+// equality functions and the functions of blank identifiers, named after _,
+// and the C functions of cgo, whose names are unqualified but for the
+// suffixes of the parts and clones the C compiler splits off, such as
+// x_cgo_munmap.cold.
+func symbolPackage(sym *gosym.Sym) string {
+	if !strings.Contains(sym.Name, ".") {
+		return ""
+	}
+	p := sym.PackageName()
+	if p == "go" || p == "type" || strings.HasPrefix(p, "go.") || strings.HasPrefix(p, "type.") {
+		return ""
+	}
+	if cCloneRe.MatchString(strings.TrimPrefix(sym.Name, p+".")) {
+		return ""
+	}
+	// The linker escapes the dots of the last element of import paths.
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "" || elem == "_" || elem[0] == '.' {
+			return ""
+		}
+		for _, r := range elem {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-._~+", r) {
+				return ""
+			}
+		}
+	}
+	return p
+}
+
+// readPclntab returns the contents of the Go line table of the executable at
+// path and the start address of its text segment.
+func readPclntab(path string) ([]byte, uint64, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		sect, text := f.Section(".gopclntab"), f.Section(".text")
+		if sect == nil || text == nil {
+			return nil, 0, errors.New("no Go line table found")
+		}
+		data, err := sect.Data()
+		return data, text.Addr, err
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		sect, text := f.Section("__gopclntab"), f.Section("__text")
+		if sect == nil || text == nil {
+			return nil, 0, errors.New("no Go line table found")
+		}
+		data, err := sect.Data()
+		return data, text.Addr, err
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return readPEPclntab(f)
+	}
+	return nil, 0, fmt.Errorf("%s: unrecognized executable format", path)
+}
+
+// readPEPclntab locates the line table of a PE executable through the
+// runtime.pclntab and runtime.epclntab symbols.
+func readPEPclntab(f *pe.File) ([]byte, uint64, error) {
+	var imageBase uint64
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(oh.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = oh.ImageBase
+	}
+	var start, end *pe.Symbol
+	for _, s := range f.Symbols {
+		switch s.Name {
+		case "runtime.pclntab":
+			start = s
+		case "runtime.epclntab":
+			end = s
+		}
+	}
+	if start == nil || end == nil || start.SectionNumber != end.SectionNumber || start.SectionNumber < 1 || int(start.SectionNumber) > len(f.Sections) {
+		return nil, 0, errors.New("no Go line table found")
+	}
+	sect := f.Sections[start.SectionNumber-1]
+	data, err := sect.Data()
+	if err != nil {
+		return nil, 0, err
+	}
+	if end.Value > uint32(len(data)) || start.Value > end.Value {
+		return nil, 0, errors.New("malformed Go line table")
+	}
+	text := f.Section(".text")
+	if text == nil {
+		return nil, 0, errors.New("no text section found")
+	}
+	return data[start.Value:end.Value], imageBase + uint64(text.VirtualAddress), nil
+}
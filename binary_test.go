@@ -0,0 +1,29 @@
+package main
+
+import (
+	"debug/gosym"
+	"testing"
+)
+
+func TestSymbolPackage(t *testing.T) {
+	for name, want := range map[string]string{
+		"main.main":                            "main",
+		"runtime.goready.func1":                "runtime",
+		"net/http.(*Client).Do":                "net/http",
+		"github.com/foo/bar.part.func1":        "github.com/foo/bar",
+		"github.com/foo/bar.coldStart":         "github.com/foo/bar",
+		"gopkg.in/yaml%2ev3.Unmarshal":         "gopkg.in/yaml.v3",
+		"_.goready.func1":                      "",
+		"go:buildid":                           "",
+		"go.shape.int":                         "",
+		"type:.eq.[2]interface {}":             "",
+		"x_cgo_munmap":                         "",
+		"x_cgo_munmap.cold":                    "",
+		"x_cgo_thread_start.part.0":            "",
+		"github.com/foo/bar.Baz[go.shape.int]": "github.com/foo/bar",
+	} {
+		if got := symbolPackage(&gosym.Sym{Name: name}); got != want {
+			t.Errorf("symbolPackage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
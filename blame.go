@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// moduleBlame returns, for every external module of the graph, or external
+// package without module information, the packages of the roots' own code
+// whose imports bring it in, each with those of its imports of external
+// packages that reach the module. They are the importers to change to drop
+// the module: the roots' own packages reaching it only through other such
+// packages are left out.
+func moduleBlame(pkgKeys, roots []string) map[string]map[string][]string {
+	external := make(map[string]bool)
+	for _, name := range externalPackages(pkgKeys, roots) {
+		external[name] = true
+	}
+	group := func(name string) string {
+		if m := packageModule(name); m != nil {
+			return m.Path
+		}
+		return name
+	}
+	externalImports := func(name string) []string {
+		var imps []string
+		for _, imp := range drawnImports(name) {
+			if external[imp] {
+				imps = append(imps, imp)
+			}
+		}
+		return imps
+	}
+
+	reached := make(map[string]map[string]bool)
+	blame := make(map[string]map[string][]string)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot || external[name] {
+			continue
+		}
+		for _, imp := range externalImports(name) {
+			groups := reached[imp]
+			if groups == nil {
+				groups = make(map[string]bool)
+				for dep := range reachable([]string{imp}, externalImports) {
+					groups[group(dep)] = true
+				}
+				reached[imp] = groups
+			}
+			for g := range groups {
+				if blame[g] == nil {
+					blame[g] = make(map[string][]string)
+				}
+				blame[g][name] = append(blame[g][name], imp)
+			}
+		}
+	}
+	return blame
+}
+
+// reportBlame writes every external module of the graph to w with the
+// packages of the roots' own code whose imports bring it in.
+func reportBlame(w io.Writer, pkgKeys, roots []string) {
+	blame := moduleBlame(pkgKeys, roots)
+	if len(blame) == 0 {
+		return
+	}
+	var modules []string
+	for m := range blame {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	fmt.Fprintln(w, "external modules and the packages whose imports bring them in:")
+	for _, m := range modules {
+		fmt.Fprintf(w, "\t%s\n", m)
+		var names []string
+		for name := range blame[m] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			imps := blame[m][name]
+			sort.Strings(imps)
+			fmt.Fprintf(w, "\t\t%s imports %s\n", name, strings.Join(imps, ", "))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// blankImports returns, for every package that pkg imports only for its side
+// effects, the positions of the blank import specs, by the import path it
+// resolves to as getImports does, and is drawn by with -V. An import that is also used under a
+// regular name in another file is not reported.
+func blankImports(pkg *node) map[string][]token.Position {
+	blank := make(map[string][]token.Position)
+	named := make(map[string]bool)
+	for _, f := range packageFiles(pkg) {
+		for _, imp := range f.Imports {
+			path := resolvedImport(pkg.Dir, imp.Path)
+			if *stripVendor {
+				path = unvendoredPath(path)
+			}
+			if imp.Name == "_" {
+				blank[path] = append(blank[path], imp.Pos)
+			} else {
+				named[path] = true
+			}
+		}
+	}
+	for path := range named {
+		delete(blank, path)
+	}
+	return blank
+}
+
+// reportBlankImports writes every side-effect import edge between rendered
+// packages to w.
+func reportBlankImports(w io.Writer, pkgKeys []string) {
+	found := false
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || (pkg.Goroot && !*delveGoroot) {
+			continue
+		}
+		blank := blankImports(pkg)
+		imps := []string{}
+		for imp := range blank {
+			if impPkg := pkgs[imp]; impPkg != nil && !isIgnored(impPkg) {
+				imps = append(imps, imp)
+			}
+		}
+		sort.Strings(imps)
+		for _, imp := range imps {
+			if !found {
+				fmt.Fprintln(w, "blank imports:")
+				found = true
+			}
+			fmt.Fprintf(w, "\t%s -> %s\n", name, imp)
+			for _, pos := range blank[imp] {
+				fmt.Fprintf(w, "\t\t%s\n", pos)
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "no blank imports")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// drawnImports returns the imports of name drawn as edges, or none if it
+// isn't in the graph.
+func drawnImports(name string) []string {
+	if pkg := pkgs[name]; pkg != nil && !isIgnored(pkg) {
+		return edgeImports(pkg)
+	}
+	return nil
+}
+
+// closureSize returns the number of packages reachable from root, not
+// counting root itself, without following the edge from root to skip.
+func closureSize(root, skip string) int {
+	var from []string
+	for _, imp := range drawnImports(root) {
+		if imp != skip {
+			from = append(from, imp)
+		}
+	}
+	seen := reachable(from, drawnImports)
+	delete(seen, root)
+	return len(seen)
+}
+
+// A budgetExcess is a root whose transitive closure exceeds -max-deps.
+type budgetExcess struct {
+	Root string
+	Deps int
+	// Contributions lists the direct imports of Root adding the most
+	// packages that are reached through them alone, largest first.
+	Contributions []contribution
+}
+
+type contribution struct {
+	Import string
+	Deps   int
+}
+
+// overBudget returns the roots reaching more than max packages.
+func overBudget(roots []string, max int) []budgetExcess {
+	var excesses []budgetExcess
+	for _, root := range roots {
+		n := closureSize(root, "")
+		if n <= max {
+			continue
+		}
+		e := budgetExcess{Root: root, Deps: n}
+		for _, imp := range drawnImports(root) {
+			if d := n - closureSize(root, imp); d > 0 {
+				e.Contributions = append(e.Contributions, contribution{imp, d})
+			}
+		}
+		sort.Slice(e.Contributions, func(i, j int) bool {
+			ci, cj := e.Contributions[i], e.Contributions[j]
+			if ci.Deps != cj.Deps {
+				return ci.Deps > cj.Deps
+			}
+			return ci.Import < cj.Import
+		})
+		excesses = append(excesses, e)
+	}
+	return excesses
+}
+
+// checkMaxDeps reports the roots over the -max-deps budget.
+func checkMaxDeps(pkgKeys []string) []violation {
+	var vs []violation
+	for _, e := range overBudget(rootPaths, *maxDeps) {
+		vs = append(vs, violation{"max-deps", fmt.Sprintf("%s\t%d > %d", e.Root, e.Deps, *maxDeps)})
+	}
+	return vs
+}
+
+// maxReportedContributions limits the imports listed per root over budget.
+const maxReportedContributions = 10
+
+// reportMaxDeps writes the roots over the budget of max packages to w, with
+// the imports contributing most to their closure, and returns their number.
+func reportMaxDeps(w io.Writer, roots []string, max int) int {
+	excesses := overBudget(roots, max)
+	for _, e := range excesses {
+		fmt.Fprintf(w, "%s reaches %d packages, over the budget of %d\n", e.Root, e.Deps, max)
+		for i, c := range e.Contributions {
+			if i == maxReportedContributions {
+				fmt.Fprintf(w, "\t... and %d more imports\n", len(e.Contributions)-i)
+				break
+			}
+			fmt.Fprintf(w, "\t%s -> %s adds %d\n", e.Root, c.Import, c.Deps)
+		}
+	}
+	return len(excesses)
+}
+
+// longestChain returns the longest chain of drawn imports starting at root,
+// including root. Imports closing a cycle are not followed.
+func longestChain(root string) []string {
+	next := make(map[string]string)
+	length := make(map[string]int)
+	onStack := make(map[string]bool)
+	var walk func(name string) int
+	walk = func(name string) int {
+		if n, ok := length[name]; ok {
+			return n
+		}
+		onStack[name] = true
+		best := 0
+		for _, imp := range drawnImports(name) {
+			if onStack[imp] {
+				continue
+			}
+			if n := walk(imp) + 1; n > best {
+				best = n
+				next[name] = imp
+			}
+		}
+		onStack[name] = false
+		length[name] = best
+		return best
+	}
+	walk(root)
+
+	chain := []string{root}
+	for name := root; next[name] != ""; name = next[name] {
+		chain = append(chain, next[name])
+	}
+	return chain
+}
+
+// longChains returns the longest chain of each root whose chain is more
+// than max imports long.
+func longChains(roots []string, max int) [][]string {
+	var chains [][]string
+	for _, root := range roots {
+		if chain := longestChain(root); len(chain)-1 > max {
+			chains = append(chains, chain)
+		}
+	}
+	return chains
+}
+
+// checkMaxChain reports the roots with a chain longer than -max-chain.
+func checkMaxChain(pkgKeys []string) []violation {
+	var vs []violation
+	for _, chain := range longChains(rootPaths, *maxChain) {
+		vs = append(vs, violation{"max-chain", fmt.Sprintf("%s\t%d > %d", strings.Join(chain, " -> "), len(chain)-1, *maxChain)})
+	}
+	return vs
+}
+
+// reportMaxChain writes the chains longer than max imports to w and returns
+// their number.
+func reportMaxChain(w io.Writer, roots []string, max int) int {
+	chains := longChains(roots, max)
+	for _, chain := range chains {
+		fmt.Fprintf(w, "%s has a chain of %d imports, over the limit of %d: %s\n", chain[0], len(chain)-1, max, strings.Join(chain, " -> "))
+	}
+	return len(chains)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compileTimes holds, with -build-time, the time the go command spent
+// compiling every package of the graph in a fresh build of the roots, by
+// import path.
+var compileTimes map[string]time.Duration
+
+// A buildAction is an action of the graph the go command writes with
+// -debug-actiongraph, of which those of mode build compile a package.
+type buildAction struct {
+	Mode      string
+	Package   string
+	CmdReal   time.Duration
+	TimeStart time.Time
+	TimeDone  time.Time
+}
+
+// computeBuildTimes builds roots from scratch, discarding the result, with
+// the go command of -go in the build context of the graph, and reads the
+// time each package took to compile into compileTimes from the action graph
+// of the build: that of the compiler and assembler commands run for it, or
+// of the whole action if it ran none.
+func computeBuildTimes(cwd string, roots []string) error {
+	tmp, err := ioutil.TempDir("", "godepgraph-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	actionGraph := filepath.Join(tmp, "actions.json")
+
+	dir := cwd
+	if buildContext.Dir != "" {
+		dir = buildContext.Dir
+	}
+	cmdArgs := []string{"build", "-a", "-o", os.DevNull, "-debug-actiongraph=" + actionGraph}
+	if tags := goTags(); len(tags) > 0 {
+		cmdArgs = append(cmdArgs, "-tags="+strings.Join(tags, ","))
+	}
+	cmdArgs = append(cmdArgs, "--")
+	for _, root := range roots {
+		cmdArgs = append(cmdArgs, loaderPath(root, dir))
+	}
+	cmd := exec.Command(goCmd, cmdArgs...)
+	cmd.Dir = dir
+	cmd.Env = goEnv()
+	if *verbose {
+		debugf("running go %s in %s\n", strings.Join(cmdArgs, " "), cmd.Dir)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go build: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := ioutil.ReadFile(actionGraph)
+	if err != nil {
+		return err
+	}
+	var actions []buildAction
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return fmt.Errorf("failed to decode the action graph: %s", err)
+	}
+	compileTimes = make(map[string]time.Duration)
+	for _, a := range actions {
+		if a.Mode != "build" || a.Package == "" {
+			continue
+		}
+		d := a.CmdReal
+		if d == 0 && !a.TimeStart.IsZero() {
+			d = a.TimeDone.Sub(a.TimeStart)
+		}
+		compileTimes[a.Package] += d
+	}
+	return nil
+}
+
+// maxCompileTime returns the longest compile time of the packages of
+// pkgKeys.
+func maxCompileTime(pkgKeys []string) time.Duration {
+	var max time.Duration
+	for _, name := range pkgKeys {
+		if d := compileTimes[name]; d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// decorateBuildTime thickens the border of the node of a package compiled
+// in d, of at most max, from 1 to 6 points with its share of the longest
+// compile time of the graph.
+func decorateBuildTime(a *attrs, d, max time.Duration) {
+	if max == 0 {
+		return
+	}
+	a.set("penwidth", fmt.Sprint(1+int(5*d/max)))
+	a.appendAttr("tooltip", "compiled in "+formatCompileTime(d), `\n`)
+}
+
+// formatCompileTime formats d to the millisecond.
+func formatCompileTime(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// reportBuildTimes writes the packages of pkgKeys heading the dependency
+// subtrees that take the longest to compile, by the compile times of the
+// package and of the packages it imports directly or indirectly, to w.
+// Packages compile in parallel, so the subtree time is the work, not the
+// latency, of building it.
+func reportBuildTimes(w io.Writer, pkgKeys []string) {
+	type subtree struct {
+		name       string
+		own, total time.Duration
+	}
+	var subtrees []subtree
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		own, ok := compileTimes[name]
+		if !ok || isIgnored(pkg) {
+			continue
+		}
+		var total time.Duration
+		for dep := range reachable([]string{name}, func(name string) []string {
+			if pkg := pkgs[name]; pkg != nil {
+				return edgeImports(pkg)
+			}
+			return nil
+		}) {
+			total += compileTimes[dep]
+		}
+		subtrees = append(subtrees, subtree{name, own, total})
+	}
+	if len(subtrees) == 0 {
+		return
+	}
+	sort.Slice(subtrees, func(i, j int) bool {
+		if subtrees[i].total != subtrees[j].total {
+			return subtrees[i].total > subtrees[j].total
+		}
+		return subtrees[i].name < subtrees[j].name
+	})
+	if len(subtrees) > 10 {
+		subtrees = subtrees[:10]
+	}
+	fmt.Fprintln(w, "slowest dependency subtrees to compile:")
+	for _, s := range subtrees {
+		fmt.Fprintf(w, "\t%s: %s with its dependencies, %s itself\n", s.name, formatCompileTime(s.total), formatCompileTime(s.own))
+	}
+}
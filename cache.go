@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// cacheVersion is part of every cache key, to be incremented whenever what
+// is cached changes.
+const cacheVersion = 2
+
+// A cacheEntry is a package resolved by a loader, saved in the -cache
+// directory. It is used again while the files of the package directory are
+// those it was resolved from, as Fingerprint records.
+type cacheEntry struct {
+	Fingerprint string
+	Package     *build.Package
+	Module      *moduleInfo `json:",omitempty"`
+}
+
+// cachedLoader returns a loader serving the packages resolved by load from
+// the cache in dir, keyed by the import path, the directory it is resolved
+// from and the build context, and adding those it has to resolve. Failures
+// aren't cached.
+func cachedLoader(dir string, load loader) loader {
+	var mu sync.Mutex
+	contexts := make(map[string]string)
+	return func(pkgName, srcDir string) (*build.Package, error) {
+		// The build context changes between the loads of the variants of
+		// -tags-set and -platforms, so it is part of the memo's key.
+		env := cacheEnv()
+		mu.Lock()
+		context, ok := contexts[env+srcDir]
+		if !ok {
+			context = cacheContext(env, srcDir)
+			contexts[env+srcDir] = context
+		}
+		mu.Unlock()
+
+		sum := sha256.Sum256([]byte(context + "\x00" + pkgName + "\x00" + srcDir))
+		key := hex.EncodeToString(sum[:])
+		path := filepath.Join(dir, key[:2], key+".json")
+		if e := readCacheEntry(path); e != nil && e.Fingerprint == dirFingerprint(e.Package.Dir) {
+			if e.Module != nil {
+				pkgModulesMu.Lock()
+				pkgModules[e.Package.ImportPath] = e.Module
+				pkgModulesMu.Unlock()
+			}
+			atomic.AddInt64(&cacheHits, 1)
+			return e.Package, nil
+		}
+
+		atomic.AddInt64(&cacheMisses, 1)
+		pkg, err := load(pkgName, srcDir)
+		if err != nil || pkg.Dir == "" {
+			return pkg, err
+		}
+		pkgModulesMu.Lock()
+		e := &cacheEntry{Fingerprint: dirFingerprint(pkg.Dir), Package: pkg, Module: pkgModules[pkg.ImportPath]}
+		pkgModulesMu.Unlock()
+		if err := writeCacheEntry(path, e); err != nil && *verbose {
+			debugf("failed to cache %s: %s\n", pkgName, err)
+		}
+		return pkg, nil
+	}
+}
+
+// cacheEnv describes the loader, the build context and the environment of
+// the go command packages are resolved in.
+func cacheEnv() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "godepgraph cache %d\nloader %s\n", cacheVersion, *loaderName)
+	fmt.Fprintf(&b, "context %s %s %s %s %t %q %q %q %q\n", buildContext.GOOS, buildContext.GOARCH, buildContext.GOROOT, buildContext.GOPATH, buildContext.CgoEnabled, buildContext.BuildTags, buildContext.ReleaseTags, buildContext.ToolTags, buildContext.Dir)
+	for _, name := range []string{"GO111MODULE", "GOFLAGS", "GOWORK", "GOPROXY", "GONOSUMDB", "GOPRIVATE"} {
+		fmt.Fprintf(&b, "%s=%s\n", name, os.Getenv(name))
+	}
+	return b.String()
+}
+
+// cacheContext describes everything besides the import path and the
+// package directory that packages resolved from srcDir depend on: env, as
+// cacheEnv describes it, and the go.mod, go.sum and go.work files in effect.
+func cacheContext(env, srcDir string) string {
+	var b strings.Builder
+	b.WriteString(env)
+	dir := srcDir
+	if buildContext.Dir != "" {
+		dir = buildContext.Dir
+	}
+	var files []string
+	if mod := findGoMod(dir); mod != "" {
+		files = append(files, mod, strings.TrimSuffix(mod, ".mod")+".sum")
+	}
+	if work := findGoWork(dir); work != "" {
+		files = append(files, work, work+".sum")
+	}
+	for _, f := range files {
+		data, _ := ioutil.ReadFile(f)
+		fmt.Fprintf(&b, "%s %x\n", f, sha256.Sum256(data))
+	}
+	return b.String()
+}
+
+// dirFingerprint returns a hash of the names, sizes and modification times
+// of the files in dir, which changes whenever one of them is edited, added
+// or removed.
+func dirFingerprint(dir string) string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			fmt.Fprintf(h, "%s %d %d\n", fi.Name(), fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCacheEntry(path string) *cacheEntry {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Package == nil {
+		return nil
+	}
+	return &e
+}
+
+// writeCacheEntry writes e to path through a temporary file, so that
+// concurrent runs never read a partial entry.
+func writeCacheEntry(path string, e *cacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(e)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCachedLoaderVariants(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "godepgraph-gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	files := map[string]string{
+		"src/tc/a/a.go":     "package a\n",
+		"src/tc/a/a_foo.go": "//go:build foo\n\npackage a\n\nimport _ \"tc/b\"\n",
+		"src/tc/b/b.go":     "package b\n",
+	}
+	for name, src := range files {
+		path := filepath.Join(gopath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := buildContext
+	defer func() { buildContext = saved }()
+	buildContext.GOPATH = gopath
+	buildContext.Dir = ""
+	load := cachedLoader(filepath.Join(gopath, "cache"), buildLoader)
+	srcDir := filepath.Join(gopath, "src")
+
+	// The variants are loaded one after the other, by the same loader, as
+	// -cache -tags-set "" -tags-set foo does.
+	for _, v := range []struct {
+		tags    []string
+		imports []string
+	}{
+		{nil, nil},
+		{[]string{"foo"}, []string{"tc/b"}},
+		{nil, nil},
+	} {
+		buildContext.BuildTags = v.tags
+		pkg, err := load("tc/a", srcDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(pkg.Imports, " ") != strings.Join(v.imports, " ") {
+			t.Errorf("tags %q: imports %q, want %q", v.tags, pkg.Imports, v.imports)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+//go:build callgraph
+
+package main
+
+import (
+	"log"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/rta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// callGraphBuilt reports whether -callgraph is supported by this build.
+const callGraphBuilt = true
+
+// writeCallGraph writes, instead of the package graph, the graph of the
+// calls between the functions of its packages in the -format, as found by
+// the -callgraph algorithm: cha, class hierarchy analysis, which takes every
+// dynamic call to reach every method of a matching signature, or rta, rapid
+// type analysis, which only takes those of the types the main packages of
+// roots create. Each function is a node named as go/ssa names it, such as
+// (*example.com/app.Server).Serve, colored after its package. The functions
+// of the packages left out of the package graph are left out too.
+func writeCallGraph(cwd string, roots []string) {
+	if render.Lookup(*outputFormat) == nil {
+		log.Fatalf("-callgraph needs a format registered with package render, one of %s", strings.Join(render.Formats(), ", "))
+	}
+	dir := cwd
+	if buildContext.Dir != "" {
+		dir = buildContext.Dir
+	}
+	cfg := &packages.Config{
+		Mode:  packages.LoadAllSyntax,
+		Dir:   dir,
+		Env:   goEnv(),
+		Tests: *includeTests,
+	}
+	if tags := goTags(); len(tags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	var patterns []string
+	for _, root := range roots {
+		patterns = append(patterns, loaderPath(root, dir))
+	}
+	initial, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		log.Fatalf("failed to load packages: %s", err)
+	}
+	if packages.PrintErrors(initial) > 0 && !*keepGoing {
+		log.Fatal("failed to load packages")
+	}
+	prog, ssaPkgs := ssautil.AllPackages(initial, ssa.InstantiateGenerics)
+	prog.Build()
+
+	var cg *callgraph.Graph
+	switch *callGraph {
+	case "cha":
+		cg = cha.CallGraph(prog)
+	case "rta":
+		var fns []*ssa.Function
+		for _, p := range ssautil.MainPackages(ssaPkgs) {
+			for _, name := range []string{"init", "main"} {
+				if fn := p.Func(name); fn != nil {
+					fns = append(fns, fn)
+				}
+			}
+		}
+		if len(fns) == 0 {
+			log.Fatal("-callgraph rta needs a main package among the packages")
+		}
+		cg = rta.Analyze(fns, true).CallGraph
+	}
+
+	g := graph.New()
+	drawn := make(map[string]bool)
+	called := make(map[[2]string]bool)
+	callgraph.GraphVisitEdges(cg, func(e *callgraph.Edge) error {
+		from, ok := callNode(g, drawn, e.Caller.Func)
+		if !ok {
+			return nil
+		}
+		to, ok := callNode(g, drawn, e.Callee.Func)
+		if !ok || called[[2]string{from, to}] {
+			return nil
+		}
+		called[[2]string{from, to}] = true
+		g.Edges = append(g.Edges, graph.Edge{From: from, To: to})
+		return nil
+	})
+	g.Sort()
+
+	out := newOutput(*outputPath)
+	if err := render.Write(out, *outputFormat, g); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputFormat, err)
+	}
+	if err := out.close(); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputPath, err)
+	}
+}
+
+// callNode adds the node of fn to g unless it is already drawn, and returns
+// its name, or false if the package of fn is left out of the graph.
+// Instances of generic functions are drawn as their package's.
+func callNode(g *graph.Graph, drawn map[string]bool, fn *ssa.Function) (string, bool) {
+	owner := fn
+	if owner.Pkg == nil && owner.Origin() != nil {
+		owner = owner.Origin()
+	}
+	if owner == nil || owner.Pkg == nil {
+		return "", false
+	}
+	pkg := pkgs[owner.Pkg.Pkg.Path()]
+	if pkg == nil || isIgnored(pkg) {
+		return "", false
+	}
+	name := fn.String()
+	if !drawn[name] {
+		drawn[name] = true
+		g.Packages = append(g.Packages, graph.Package{
+			ImportPath: name,
+			Dir:        pkg.Dir,
+			Goroot:     pkg.Goroot,
+			Cgo:        pkg.CgoFiles > 0,
+		})
+	}
+	return name, true
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unlinkGraph merges the packages reached through symbolic links under
+// several import paths, such as the path of a checkout in GOPATH and the
+// local path of the directory the link points to, into one node by
+// mergePackages, and returns roots renamed alike.
+func unlinkGraph(roots []string) []string {
+	byDir := make(map[string][]string)
+	for name, pkg := range pkgs {
+		if pkg.Dir == "" || pkg.Goroot {
+			continue
+		}
+		dir, err := filepath.EvalSymlinks(pkg.Dir)
+		if err != nil {
+			dir = pkg.Dir
+		}
+		if pkg.XTest {
+			// The external tests of a package share its directory.
+			dir += "\x00xtest"
+		}
+		byDir[dir] = append(byDir[dir], name)
+	}
+	canon := make(map[string]string)
+	for _, names := range byDir {
+		if len(names) < 2 {
+			continue
+		}
+		sortPreferred(names)
+		for _, name := range names[1:] {
+			canon[name] = names[0]
+		}
+	}
+	if len(canon) == 0 {
+		return roots
+	}
+	return mergePackages(func(path string) string {
+		if c, ok := canon[path]; ok {
+			return c
+		}
+		return path
+	}, roots, true)
+}
+
+// sortPreferred sorts the import paths of packages to be merged by import
+// path, local paths last, so that the first is the one kept.
+func sortPreferred(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if a, b := isLocalPath(names[i]), isLocalPath(names[j]); a != b {
+			return b
+		}
+		return names[i] < names[j]
+	})
+}
+
+// mergePackages renames every package of the graph to canonical of its
+// import path and returns roots renamed alike. Packages renamed to the same
+// path become one node, that of the first by sortPreferred,
+// with the imports of all of them. With sameSource, they are expected to
+// be copies of one package, and those whose Go files differ are reported, as
+// the node only shows one of them.
+func mergePackages(canonical func(string) string, roots []string, sameSource bool) []string {
+	copies := make(map[string][]string)
+	for name := range pkgs {
+		canon := canonical(name)
+		copies[canon] = append(copies[canon], name)
+	}
+	merged := make(map[string]*node, len(copies))
+	for canon, names := range copies {
+		sortPreferred(names)
+		var n *node
+		found := make(map[string]bool)
+		for _, name := range names {
+			pkg := pkgs[name]
+			if n == nil {
+				c := *pkg
+				n = &c
+				n.ImportPath = intern(canon)
+				n.Imports = nil
+				if pkg.ImportPos != nil {
+					n.ImportPos = make(map[string][]token.Position)
+				}
+			}
+			for _, path := range pkg.Imports {
+				imp := intern(canonical(path))
+				if n.ImportPos != nil {
+					n.ImportPos[imp] = append(n.ImportPos[imp], pkg.ImportPos[path]...)
+				}
+				if imp == canon || found[imp] {
+					continue
+				}
+				found[imp] = true
+				n.Imports = append(n.Imports, imp)
+			}
+		}
+		if sameSource && len(names) > 1 {
+			first := sourceHash(pkgs[names[0]].Dir)
+			for _, name := range names[1:] {
+				if sourceHash(pkgs[name].Dir) != first {
+					log.Printf("warning: merging differing copies of %s: %s", canon, strings.Join(names, ", "))
+					break
+				}
+			}
+		}
+		merged[canon] = n
+	}
+	pkgs = merged
+
+	// canonical needn't be idempotent, as anonymizedPath isn't, so the
+	// failures are rebuilt rather than renamed in place.
+	failed := make(map[string]error, len(failures))
+	for name, err := range failures {
+		if canon := canonical(name); failed[canon] == nil {
+			failed[canon] = err
+		}
+	}
+	failures = failed
+	var out []string
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		if root = canonical(root); !seen[root] {
+			seen[root] = true
+			out = append(out, root)
+		}
+	}
+	return out
+}
+
+// sourceHash returns a hash of the names and contents of the Go files in
+// dir.
+func sourceHash(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	h := sha256.New()
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s %d\n", filepath.Base(f), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// modulePathsByFile memoizes the module paths of go.mod files for
+// canonicalPath, "" for those that can't be read.
+var modulePathsByFile = make(map[string]string)
+
+// canonicalPath returns the import path pkg declares it must be imported
+// by, or "" if it declares none: the path of the module of the go.mod file
+// governing its directory followed by the directory below the module's, or
+// else the path of its import comment, which the go command only enforces
+// outside modules.
+func canonicalPath(pkg *node) string {
+	if pkg.Goroot || pkg.Dir == "" {
+		return ""
+	}
+	if gomod := findGoMod(pkg.Dir); gomod != "" {
+		modPath, ok := modulePathsByFile[gomod]
+		if !ok {
+			if mf, err := readGoMod(gomod); err == nil {
+				modPath = mf.Path
+			}
+			modulePathsByFile[gomod] = modPath
+		}
+		if modPath == "" {
+			return ""
+		}
+		rel, err := filepath.Rel(filepath.Dir(gomod), pkg.Dir)
+		if err != nil {
+			return ""
+		}
+		return path.Join(modPath, filepath.ToSlash(rel))
+	}
+	return pkg.ImportComment
+}
+
+// canonicalMismatches returns the packages of the graph reached by an
+// import path other than their canonical one, with that path. Vendored
+// packages are compared by the path they are vendored as, and packages
+// named by their directories aren't compared.
+func canonicalMismatches(pkgKeys []string) [][2]string {
+	var ms [][2]string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || isLocalPath(name) || pkg.XTest {
+			continue
+		}
+		if want := canonicalPath(pkg); want != "" && unvendoredPath(renamedPath(want)) != unvendoredPath(name) {
+			ms = append(ms, [2]string{name, renamedPath(want)})
+		}
+	}
+	return ms
+}
+
+// renamedPath returns the import path path renamed as the packages of the
+// graph are by -move and -anonymize.
+func renamedPath(path string) string {
+	if len(moveRules) > 0 {
+		path = movedPath(path)
+	}
+	if *anonymizeKey != "" {
+		path = anonymizedPath(path)
+	}
+	return path
+}
+
+// checkCanonical reports every package reached by a path other than its
+// canonical one.
+func checkCanonical(pkgKeys []string) []violation {
+	var vs []violation
+	for _, m := range canonicalMismatches(pkgKeys) {
+		vs = append(vs, violation{"canonical", m[0] + "\t" + m[1]})
+	}
+	return vs
+}
+
+// reportCanonical writes the packages reached by a path other than their
+// canonical one, with their importers, to w.
+func reportCanonical(w io.Writer, pkgKeys []string) {
+	ms := canonicalMismatches(pkgKeys)
+	if len(ms) == 0 {
+		return
+	}
+	importers := make(map[string][]string)
+	for _, name := range pkgKeys {
+		for _, imp := range getImports(pkgs[name]) {
+			importers[imp] = append(importers[imp], name)
+		}
+	}
+	fmt.Fprintln(w, "warning: packages imported by a path other than their canonical one:")
+	for _, m := range ms {
+		fmt.Fprintf(w, "\t%s, canonically %s\n", m[0], m[1])
+		if imps := importers[m[0]]; len(imps) > 0 {
+			sort.Strings(imps)
+			fmt.Fprintf(w, "\t\timported by %s\n", strings.Join(imps, ", "))
+		}
+	}
+}
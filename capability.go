@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// capability is a sensitive facility provided by a set of packages.
+type capability struct {
+	name     string
+	color    string
+	packages []string
+}
+
+// capabilities are listed from most to least sensitive.
+var capabilities = []capability{
+	{"unsafe", "orangered", []string{"unsafe"}},
+	{"syscall", "orange", []string{"syscall", "golang.org/x/sys"}},
+	{"exec", "gold", []string{"os/exec"}},
+	{"plugin", "orchid", []string{"plugin"}},
+	{"net", "lightskyblue", []string{"net"}},
+}
+
+// pathCapabilities returns the names of the capabilities provided by the
+// package with the given import path.
+func pathCapabilities(path string) []string {
+	var names []string
+	for _, c := range capabilities {
+		for _, p := range c.packages {
+			if hasPathPrefix(path, p) {
+				names = append(names, c.name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func directCapabilities(pkg *node) []string {
+	names := pathCapabilities(pkg.ImportPath)
+	for _, imp := range getImports(pkg) {
+		names = append(names, pathCapabilities(imp)...)
+	}
+	return names
+}
+
+func capabilityColor(name string) string {
+	for _, c := range capabilities {
+		if c.name == name {
+			return c.color
+		}
+	}
+	return ""
+}
+
+// decorateCapabilities fills a node with the colors of the capabilities it
+// reaches. Packages reaching several capabilities are drawn as wedges.
+func decorateCapabilities(a *attrs, caps []string) {
+	if len(caps) == 0 {
+		return
+	}
+	colors := make([]string, len(caps))
+	for i, c := range caps {
+		colors[i] = capabilityColor(c)
+	}
+	if len(colors) > 1 {
+		a.set("style", strings.Replace(a.get("style"), "filled", "wedged", 1))
+	}
+	a.set("fillcolor", strings.Join(colors, ":"))
+	a.appendAttr("tooltip", "reaches "+strings.Join(caps, ", "), `\n`)
+}
+
+// reportCapabilities writes the capabilities reached by every scanned
+// package to w.
+func reportCapabilities(w io.Writer, caps map[string][]string) {
+	keys := []string{}
+	for k := range caps {
+		if !isIgnored(pkgs[k]) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "capabilities:")
+	for _, k := range keys {
+		fmt.Fprintf(w, "\t%s: %s\n", k, strings.Join(caps[k], ", "))
+	}
+}
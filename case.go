@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// caseCollisions returns the sets of import paths of the graph, loaded or
+// missing, that differ only in letter case. On case-insensitive file systems
+// such paths resolve to the same directory, and the module cache, which
+// escapes capitals as !x, stores them apart, so at most one of each set is
+// what its importers meant.
+func caseCollisions(pkgKeys []string) [][]string {
+	byFold := make(map[string][]string)
+	add := func(path string) {
+		fold := strings.ToLower(path)
+		for _, p := range byFold[fold] {
+			if p == path {
+				return
+			}
+		}
+		byFold[fold] = append(byFold[fold], path)
+	}
+	for _, name := range pkgKeys {
+		add(name)
+	}
+	for path := range failures {
+		add(path)
+	}
+	var sets [][]string
+	for _, paths := range byFold {
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			sets = append(sets, paths)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i][0] < sets[j][0] })
+	return sets
+}
+
+// checkCase reports every set of import paths differing only in case.
+func checkCase(pkgKeys []string) []violation {
+	var vs []violation
+	for _, paths := range caseCollisions(pkgKeys) {
+		vs = append(vs, violation{"case", strings.Join(paths, " ")})
+	}
+	return vs
+}
+
+// reportCaseCollisions writes the import paths differing only in case, with
+// their importers, to w.
+func reportCaseCollisions(w io.Writer, pkgKeys []string) {
+	sets := caseCollisions(pkgKeys)
+	if len(sets) == 0 {
+		return
+	}
+	importers := make(map[string][]string)
+	for _, name := range pkgKeys {
+		for _, imp := range getImports(pkgs[name]) {
+			importers[imp] = append(importers[imp], name)
+		}
+	}
+	fmt.Fprintln(w, "warning: import paths differing only in case:")
+	for _, paths := range sets {
+		for _, p := range paths {
+			fmt.Fprintf(w, "\t%s\n", p)
+			if imps := importers[p]; len(imps) > 0 {
+				sort.Strings(imps)
+				fmt.Fprintf(w, "\t\timported by %s\n", strings.Join(imps, ", "))
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// centralityMeasures are the values of -centrality.
+var centralityMeasures = []string{"pagerank", "degree", "betweenness"}
+
+// centrality returns the score of every drawn package of pkgKeys by the
+// -centrality measure, higher for more central packages.
+func centrality(pkgKeys []string) map[string]float64 {
+	switch *centralityBy {
+	case "degree":
+		return degreeCentrality(pkgKeys)
+	case "betweenness":
+		scores, _ := betweenness(pkgKeys)
+		return scores
+	}
+	return pageRank(pkgKeys)
+}
+
+// degreeCentrality returns the number of drawn imports and importers of
+// every drawn package of pkgKeys.
+func degreeCentrality(pkgKeys []string) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		imports := edgeImports(pkg)
+		scores[name] += float64(len(imports))
+		for _, imp := range imports {
+			scores[imp]++
+		}
+	}
+	return scores
+}
+
+// pageRank returns the PageRank of every drawn package of pkgKeys, the
+// share of a random walk along imports spent on it, which is high for the
+// packages many others depend on, directly or through other central ones.
+// The walk jumps to a package at random with a probability of 0.15 at every
+// step and from packages importing none, and the ranks sum to 1.
+func pageRank(pkgKeys []string) map[string]float64 {
+	const (
+		damping    = 0.85
+		iterations = 50
+	)
+	var names []string
+	for _, name := range pkgKeys {
+		if !isIgnored(pkgs[name]) {
+			names = append(names, name)
+		}
+	}
+	n := float64(len(names))
+	rank := make(map[string]float64, len(names))
+	for _, name := range names {
+		rank[name] = 1 / n
+	}
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64, len(names))
+		dangling := 0.0
+		for _, name := range names {
+			imports := edgeImports(pkgs[name])
+			if len(imports) == 0 {
+				dangling += rank[name]
+				continue
+			}
+			share := rank[name] / float64(len(imports))
+			for _, imp := range imports {
+				next[imp] += share
+			}
+		}
+		for _, name := range names {
+			next[name] = (1-damping)/n + damping*(next[name]+dangling/n)
+		}
+		rank = next
+	}
+	return rank
+}
+
+// betweenness returns the betweenness centrality of every drawn package of
+// pkgKeys, the number of shortest import chains between two other packages
+// passing through it, chains of the same length sharing one, computed with
+// the algorithm of Brandes, and the number of pairs of packages of which
+// one reaches the other.
+func betweenness(pkgKeys []string) (map[string]float64, int) {
+	var names []string
+	for _, name := range pkgKeys {
+		if !isIgnored(pkgs[name]) {
+			names = append(names, name)
+		}
+	}
+	scores := make(map[string]float64, len(names))
+	for _, name := range names {
+		scores[name] = 0
+	}
+	pairs := 0
+	for _, s := range names {
+		// The packages in order of their distance from s, the number of
+		// shortest chains from s to each and the packages before each on
+		// them.
+		var order []string
+		paths := map[string]float64{s: 1}
+		dist := map[string]int{s: 0}
+		pred := make(map[string][]string)
+		queue := []string{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			order = append(order, v)
+			for _, w := range edgeImports(pkgs[v]) {
+				if _, ok := dist[w]; !ok {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					paths[w] += paths[v]
+					pred[w] = append(pred[w], v)
+				}
+			}
+		}
+		pairs += len(order) - 1
+		dependency := make(map[string]float64)
+		for i := len(order) - 1; i > 0; i-- {
+			w := order[i]
+			for _, v := range pred[w] {
+				dependency[v] += paths[v] / paths[w] * (1 + dependency[w])
+			}
+			scores[w] += dependency[w]
+		}
+	}
+	return scores, pairs
+}
+
+// reportChokePoints writes to w the n packages of pkgKeys on the most
+// shortest import chains between other packages, the choke points most of
+// the dependencies of the graph flow through, with the share of the pairs
+// of packages one of which reaches the other they are between and their
+// numbers of importers and imports.
+func reportChokePoints(w io.Writer, pkgKeys []string, n int) {
+	scores, pairs := betweenness(pkgKeys)
+	for name, score := range scores {
+		if score == 0 {
+			delete(scores, name)
+		}
+	}
+	names := mostCentral(scores, n)
+	if len(names) == 0 {
+		fmt.Fprintln(w, "no package is between two others")
+		return
+	}
+	rev := importers()
+	fmt.Fprintln(w, "choke points:")
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%s: betweenness %.1f (%.1f%% of the reaching pairs), %d importers, %d imports\n", name, scores[name], scores[name]*100/float64(pairs), len(rev[name]), len(edgeImports(pkgs[name])))
+	}
+}
+
+// mostCentral returns the n packages of scores with the highest scores, in
+// decreasing order of them, ties broken by import path.
+func mostCentral(scores map[string]float64, n int) []string {
+	var names []string
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if scores[names[i]] != scores[names[j]] {
+			return scores[names[i]] > scores[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
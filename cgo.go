@@ -0,0 +1,19 @@
+package main
+
+// cgoTainted returns the packages that do not use cgo themselves but import,
+// directly or transitively, a package that does.
+func cgoTainted() map[string]bool {
+	reach := taint(func(pkg *node) []string {
+		if pkg.CgoFiles > 0 {
+			return []string{"cgo"}
+		}
+		return nil
+	})
+	tainted := make(map[string]bool)
+	for name := range reach {
+		if pkgs[name].CgoFiles == 0 {
+			tainted[name] = true
+		}
+	}
+	return tainted
+}
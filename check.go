@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A violation is a condition found by a check.
+type violation struct {
+	Check  string
+	Detail string
+}
+
+func (v violation) String() string {
+	return v.Check + "\t" + v.Detail
+}
+
+// checks holds the available checks of -check by name.
+var checks = map[string]func(pkgKeys []string) []violation{
+	"canonical": checkCanonical,
+	"case":      checkCase,
+	"cycles":    checkCycles,
+	"deny":      checkDenied,
+	"layers":    checkLayers,
+	"move":      checkMoves,
+	"baseline":  checkBaseline,
+	"max-deps":  checkMaxDeps,
+	"max-chain": checkMaxChain,
+}
+
+// availableChecks returns the sorted names of the available checks.
+func availableChecks() []string {
+	var names []string
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// policyChecks are the checks configured by a flag of the same name, which
+// policySet reports whether it is set.
+var policyChecks = []string{"deny", "layers", "move", "baseline", "max-deps", "max-chain"}
+
+func isPolicyCheck(name string) bool {
+	for _, p := range policyChecks {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func policySet(name string) bool {
+	switch name {
+	case "deny":
+		return len(denyRules) > 0
+	case "layers":
+		return layers != nil
+	case "move":
+		return len(moveRules) > 0
+	case "baseline":
+		return baseline != nil
+	case "max-deps":
+		return *maxDeps > 0
+	case "max-chain":
+		return *maxChain > 0
+	}
+	return false
+}
+
+// selectChecks validates the checks named with -check and adds those
+// whose policy flags are set, which are always checked.
+func selectChecks(names []string) ([]string, error) {
+	listed := make(map[string]bool)
+	for _, name := range names {
+		if checks[name] == nil {
+			return nil, fmt.Errorf("unknown check %q, want one of %s", name, strings.Join(availableChecks(), ", "))
+		}
+		if isPolicyCheck(name) && !policySet(name) {
+			return nil, fmt.Errorf("the %s check needs -%s", name, name)
+		}
+		listed[name] = true
+	}
+	for _, name := range policyChecks {
+		if policySet(name) && !listed[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// runChecks runs the named checks on the graph and writes their violations
+// to w, one per line as the name of the check and the details separated by
+// a tab. It returns the number of violations.
+func runChecks(w io.Writer, names []string, pkgKeys []string) int {
+	n := 0
+	for _, name := range names {
+		for _, v := range checks[name](pkgKeys) {
+			fmt.Fprintln(w, v)
+			n++
+		}
+	}
+	return n
+}
+
+// checkCycles reports every import cycle of the graph, as a path through
+// the strongly connected component it belongs to.
+func checkCycles(pkgKeys []string) []violation {
+	var vs []violation
+	for _, comp := range cycles(pkgKeys) {
+		vs = append(vs, violation{"cycles", strings.Join(comp, " -> ")})
+	}
+	return vs
+}
+
+// cycles returns one cycle for every strongly connected component of the
+// drawn graph with more than one package. Each starts and ends with the
+// alphabetically first package of its component.
+func cycles(pkgKeys []string) [][]string {
+	var result [][]string
+	for _, comp := range strongComponents(pkgKeys) {
+		in := make(map[string]bool)
+		for _, name := range comp {
+			in[name] = true
+		}
+		start := comp[0]
+		for _, name := range comp {
+			if name < start {
+				start = name
+			}
+		}
+		result = append(result, cyclePath(start, in))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
+
+// strongComponents returns the strongly connected components of the drawn
+// graph with more than one package, the packages of each in no particular
+// order.
+func strongComponents(pkgKeys []string) [][]string {
+	// Tarjan's algorithm.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var comps [][]string
+	next := 0
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = next
+		low[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, imp := range edgeImports(pkgs[name]) {
+			if _, ok := index[imp]; !ok {
+				connect(imp)
+				if low[imp] < low[name] {
+					low[name] = low[imp]
+				}
+			} else if onStack[imp] && index[imp] < low[name] {
+				low[name] = index[imp]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		var comp []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			comp = append(comp, top)
+			if top == name {
+				break
+			}
+		}
+		if len(comp) > 1 {
+			comps = append(comps, comp)
+		}
+	}
+	for _, name := range pkgKeys {
+		if _, ok := index[name]; !ok && !isIgnored(pkgs[name]) {
+			connect(name)
+		}
+	}
+	return comps
+}
+
+// cyclePath returns the shortest path from start back to itself through the
+// packages in the component in.
+func cyclePath(start string, in map[string]bool) []string {
+	prev := make(map[string]string)
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, imp := range edgeImports(pkgs[name]) {
+			if !in[imp] {
+				continue
+			}
+			if imp == start {
+				path := []string{start}
+				for n := name; n != start; n = prev[n] {
+					path = append(path, n)
+				}
+				// path is reversed after its first element.
+				for i, j := 1, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return append(path, start)
+			}
+			if _, ok := prev[imp]; !ok {
+				prev[imp] = name
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return []string{start, start}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// packageChurn holds, with -churn, the number of commits changing the files
+// of every package directory in the period, by directory.
+var packageChurn map[string]int
+
+// churnSince returns the git --since value of the -churn period, which is
+// either a number of days, as in 90d, or anything git understands as a date.
+func churnSince(period string) string {
+	if days, err := strconv.Atoi(strings.TrimSuffix(period, "d")); err == nil && strings.HasSuffix(period, "d") {
+		return time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	}
+	return period
+}
+
+// computeChurn reads the history of the git repositories of the packages of
+// pkgKeys outside the standard library into packageChurn, counting for each
+// package directory the commits of the -churn period changing a file
+// directly in it.
+func computeChurn(pkgKeys []string) {
+	packageChurn = make(map[string]int)
+	since := churnSince(*churnPeriod)
+	read := make(map[string]bool)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot || pkg.Dir == "" {
+			continue
+		}
+		top, err := git(pkg.Dir, "rev-parse", "--show-toplevel")
+		if err != nil || read[top] {
+			continue
+		}
+		read[top] = true
+		out, err := git(top, "log", "--since="+since, "--format=%x00", "--name-only", "--no-renames")
+		if err != nil {
+			log.Printf("failed to read the history of %s: %s", top, err)
+			continue
+		}
+		for _, commit := range strings.Split(out, "\x00") {
+			dirs := make(map[string]bool)
+			for _, file := range strings.Split(commit, "\n") {
+				if file == "" {
+					continue
+				}
+				dirs[filepath.Join(top, filepath.Dir(filepath.FromSlash(file)))] = true
+			}
+			for dir := range dirs {
+				packageChurn[dir]++
+			}
+		}
+	}
+}
+
+// churnOf returns the number of commits changing pkg in the -churn period.
+func churnOf(pkg *node) int {
+	if pkg.Dir == "" {
+		return 0
+	}
+	if n, ok := packageChurn[pkg.Dir]; ok {
+		return n
+	}
+	dir, err := filepath.EvalSymlinks(pkg.Dir)
+	if err != nil {
+		return 0
+	}
+	return packageChurn[dir]
+}
+
+// maxChurn returns the highest churn of the packages of pkgKeys.
+func maxChurn(pkgKeys []string) int {
+	max := 0
+	for _, name := range pkgKeys {
+		if n := churnOf(pkgs[name]); n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// decorateChurn colors the node of pkg, with a churn of n of at most max,
+// on a scale of reds from the least to the most changed.
+func decorateChurn(a *attrs, n, max int) {
+	if max == 0 {
+		return
+	}
+	a.set("color", "/reds9/"+strconv.Itoa(1+n*8/max))
+	if n*8/max >= 5 {
+		a.set("fontcolor", "white")
+	}
+	a.appendAttr("tooltip", fmt.Sprintf("%d commits since %s", n, churnSince(*churnPeriod)), `\n`)
+}
+
+// reportChurn writes the packages of pkgKeys that are both the most
+// changed in the -churn period and the most depended on, by the number of
+// commits changing them times the number of packages importing them
+// directly or indirectly, to w: the riskiest spots of the graph.
+func reportChurn(w io.Writer, pkgKeys []string) {
+	importers := make(map[string][]string)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			importers[imp] = append(importers[imp], name)
+		}
+	}
+	type risk struct {
+		name           string
+		churn, reached int
+	}
+	var risks []risk
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		n := churnOf(pkg)
+		if n == 0 || isIgnored(pkg) || len(importers[name]) == 0 {
+			continue
+		}
+		reached := reachable(importers[name], func(name string) []string { return importers[name] })
+		risks = append(risks, risk{name, n, len(reached)})
+	}
+	if len(risks) == 0 {
+		return
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		ri, rj := risks[i].churn*risks[i].reached, risks[j].churn*risks[j].reached
+		if ri != rj {
+			return ri > rj
+		}
+		return risks[i].name < risks[j].name
+	})
+	if len(risks) > 10 {
+		risks = risks[:10]
+	}
+	fmt.Fprintln(w, "most changed and depended-on packages:")
+	for _, r := range risks {
+		fmt.Fprintf(w, "\t%s: %d commits, %d importers\n", r.name, r.churn, r.reached)
+	}
+}
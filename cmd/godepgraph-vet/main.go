@@ -0,0 +1,12 @@
+// Command godepgraph-vet runs the godepgraph analyzer under go vet -vettool.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/kisielk/godepgraph/analyzer"
+)
+
+func main() {
+	unitchecker.Main(analyzer.Analyzer)
+}
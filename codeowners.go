@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An ownerRule is a line of a CODEOWNERS file: the owners of the paths
+// matching a pattern.
+type ownerRule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+// packageOwners holds, with -codeowners, the owners of every package, as
+// listed by the CODEOWNERS file and joined with spaces, by import path.
+// Packages nobody owns have none.
+var packageOwners map[string]string
+
+// readCodeowners reads the rules of the CODEOWNERS file at path.
+func readCodeowners(path string) ([]ownerRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []ownerRule
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		re, err := codeownersRegexp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		var owners []string
+		for _, o := range fields[1:] {
+			if strings.HasPrefix(o, "#") {
+				break
+			}
+			owners = append(owners, o)
+		}
+		rules = append(rules, ownerRule{re, owners})
+	}
+	return rules, s.Err()
+}
+
+// codeownersRegexp compiles a CODEOWNERS pattern, which follows the rules
+// of gitignore: a pattern with a slash other than a trailing one is
+// relative to the root, others match at any depth, * and ? match within a
+// path element and ** across them, and a pattern matching a directory
+// matches everything below it.
+func codeownersRegexp(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	p := strings.Trim(pattern, "/")
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	b.WriteString("(/.*)?$")
+	return regexp.Compile(b.String())
+}
+
+// codeownersRoot returns the directory the paths of the CODEOWNERS file at
+// path are relative to: that of the repository, of which the file is at the
+// top or in .github or docs.
+func codeownersRoot(path string) string {
+	dir := filepath.Dir(path)
+	switch filepath.Base(dir) {
+	case ".github", "docs":
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// computeOwners records in packageOwners the owners the CODEOWNERS file at
+// path assigns to the packages of pkgKeys: those of their Go files, which
+// the last matching rule gives.
+func computeOwners(path string, pkgKeys []string) error {
+	rules, err := readCodeowners(path)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	root := codeownersRoot(abs)
+	packageOwners = make(map[string]string)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot || pkg.Dir == "" {
+			continue
+		}
+		rel, err := filepath.Rel(root, pkg.Dir)
+		if err != nil || isParentRel(rel) {
+			continue
+		}
+		// Rules may select files by name, so a Go file of the package
+		// stands for it.
+		probe := "x.go"
+		if files, _ := filepath.Glob(filepath.Join(pkg.Dir, "*.go")); len(files) > 0 {
+			probe = filepath.Base(files[0])
+		}
+		file := filepath.ToSlash(filepath.Join(rel, probe))
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].re.MatchString(file) {
+				packageOwners[name] = strings.Join(rules[i].owners, " ")
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// writeOwnerClusters draws, with -codeowners, the packages of every owner
+// in a cluster of their own, with a node for the owner, and a dashed edge
+// from every owner to each other owner whose packages theirs import,
+// labeled with the number of imports.
+func writeOwnerClusters(w io.Writer, pkgKeys []string) {
+	members := make(map[string][]string)
+	var owners []string
+	for _, name := range pkgKeys {
+		owner, ok := packageOwners[name]
+		if !ok || owner == "" {
+			continue
+		}
+		if members[owner] == nil {
+			owners = append(owners, owner)
+		}
+		members[owner] = append(members[owner], name)
+	}
+	sort.Strings(owners)
+
+	for i, owner := range owners {
+		fmt.Fprintf(w, "subgraph cluster_owner_%d {\n", i)
+		fmt.Fprintf(w, "graph [%s];\n", attrs{{"label", owner}, {"style", "rounded"}, {"color", "gray50"}})
+		fmt.Fprintf(w, "_%d [%s];\n", getId("owner "+owner), attrs{{"label", owner}, {"shape", "house"}, {"style", "filled"}, {"color", "gray85"}})
+		for _, name := range members[owner] {
+			fmt.Fprintf(w, "_%d;\n", getId(name))
+		}
+		fmt.Fprintln(w, "}")
+	}
+
+	imports := make(map[[2]string]int)
+	for _, name := range pkgKeys {
+		from := packageOwners[name]
+		if from == "" {
+			continue
+		}
+		for _, imp := range edgeImports(pkgs[name]) {
+			if to := packageOwners[imp]; to != "" && to != from {
+				imports[[2]string{from, to}]++
+			}
+		}
+	}
+	var pairs [][2]string
+	for p := range imports {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	for _, p := range pairs {
+		a := attrs{{"style", "dashed"}, {"color", "gray50"}, {"label", fmt.Sprint(imports[p])}}
+		fmt.Fprintf(w, "_%d -> _%d [%s];\n", getId("owner "+p[0]), getId("owner "+p[1]), a)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// coImporters returns the sorted packages of the graph importing every one
+// of targets directly, whether or not the imports are drawn.
+func coImporters(targets []string) []string {
+	var names []string
+	for name, pkg := range pkgs {
+		if isIgnored(pkg) {
+			continue
+		}
+		imports := getImports(pkg)
+		all := true
+		for _, t := range targets {
+			if !containsString(imports, t) {
+				all = false
+				break
+			}
+		}
+		if all {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reportCoImporters writes the packages importing every one of targets
+// directly to w, to know who touches all of them before merging or
+// splitting them.
+func reportCoImporters(w io.Writer, targets []string) {
+	for _, t := range targets {
+		if pkgs[t] == nil {
+			fmt.Fprintf(w, "%s is not in the graph\n", t)
+		}
+	}
+	names := coImporters(targets)
+	if len(names) == 0 {
+		fmt.Fprintf(w, "no package imports all of %s\n", strings.Join(targets, ", "))
+		return
+	}
+	fmt.Fprintf(w, "packages importing all of %s:\n", strings.Join(targets, ", "))
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%s\n", name)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git runs git with args in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if *verbose {
+		debugf("running git %s in %s\n", strings.Join(args, " "), dir)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// refGraph computes the graph of ref of the repository containing cwd. It
+// checks ref out in a temporary worktree and runs godepgraph again in the
+// directory corresponding to cwd, with the same flags and arguments but
+// -format json.
+func refGraph(cwd, ref string) (*jsonGraph, error) {
+	var g *jsonGraph
+	err := inWorktree(cwd, ref, func(top, dir string) error {
+		var err error
+		g, err = runGraph(dir, refArgs(cwd))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("graph of %s: %s", ref, err)
+	}
+	return g, nil
+}
+
+// inWorktree checks ref of the repository containing cwd out in a temporary
+// worktree and calls f with the top of the worktree and the directory in it
+// corresponding to cwd, removing the worktree when it returns.
+func inWorktree(cwd, ref string, f func(top, dir string) error) error {
+	top, err := git(cwd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(top, cwd)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir("", "godepgraph-ref")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	// The go command reports the directories of packages with the links
+	// of their paths resolved.
+	if resolved, err := filepath.EvalSymlinks(tmp); err == nil {
+		tmp = resolved
+	}
+	tree := filepath.Join(tmp, "tree")
+	if _, err := git(cwd, "worktree", "add", "--detach", tree, ref); err != nil {
+		return err
+	}
+	defer git(cwd, "worktree", "remove", "--force", tree)
+	return f(tree, filepath.Join(tree, rel))
+}
+
+// runGraph runs godepgraph with args, which must select -format json, in dir
+// and returns the graph it writes. Policy violations, which make it exit
+// nonzero after writing the graph, are not errors.
+func runGraph(dir string, args []string) (*jsonGraph, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(self, args...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+	out, runErr := cmd.Output()
+	var g jsonGraph
+	if err := json.Unmarshal(out, &g); err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, err
+	}
+	return &g, nil
+}
+
+// refPathFlags are the flags naming files or directories outside the tree
+// of the packages, which the runs computing the graphs of refs are to read
+// from the working tree rather than from the worktree of the ref.
+var refPathFlags = []string{"config", "annotations", "codeowners", "layers", "baseline", "write-baseline", "vulns", "roots-file", "from-list", "binary", "size", "work", "cache", "incremental", "cpuprofile", "memprofile"}
+
+// refArgs returns the command line computing the graph of a ref: the flags
+// of this run followed by overrides turning -compare-ref and -timeline off,
+// selecting JSON output, reading the configuration of the working tree, if
+// any, and naming the files of refPathFlags and the -gopath and -go given by
+// absolute paths, since the run is in the worktree, then the flags extra,
+// and then the arguments.
+func refArgs(cwd string, extra ...string) []string {
+	overrides := []string{"-compare-ref=", "-timeline=", "-format=json", "-o=", "-watch=false"}
+	if *configFile == "" {
+		if _, err := os.Stat(filepath.Join(cwd, configName)); err == nil {
+			overrides = append(overrides, "-config="+filepath.Join(cwd, configName))
+		}
+	}
+	// The flags set by the configuration file are visited too.
+	flag.VisitAll(func(f *flag.Flag) {
+		v := f.Value.String()
+		if v == f.DefValue || v == "" || v == "-" {
+			return
+		}
+		switch {
+		case containsString(refPathFlags, f.Name):
+			if abs, err := filepath.Abs(v); err == nil {
+				overrides = append(overrides, "-"+f.Name+"="+abs)
+			}
+		case f.Name == "gopath":
+			if abs, err := absPathList(v); err == nil {
+				overrides = append(overrides, "-gopath="+abs)
+			}
+		case f.Name == "go" && strings.ContainsRune(v, filepath.Separator):
+			if abs, err := filepath.Abs(v); err == nil {
+				overrides = append(overrides, "-go="+abs)
+			}
+		}
+	})
+	return rerunArgs(append(overrides, extra...)...)
+}
+
+// writeCompare writes the difference from the graph of -compare-ref to the
+// graph of the named packages to w, as a graph of both in dot format or, with
+// -format json, as a graphDiff.
+func writeCompare(w io.Writer, cwd string, pkgKeys []string) error {
+	before, err := refGraph(cwd, *compareRef)
+	if err != nil {
+		return err
+	}
+	after := jsonGraphOf(pkgKeys)
+	d := diffGraphs(before, after)
+	if *outputFormat == "json" {
+		return writeIndentedJSON(w, d)
+	}
+	return writeDiffDot(w, before, after, d)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// flagValues lists the accepted values of flags taking one of a fixed set.
+var flagValues = map[string][]string{
+	"format":     outputFormats(),
+	"loader":     {"build", "list", "deps"},
+	"target":     targetPresetNames(),
+	"cgo":        {"0", "1"},
+	"mod":        {"vendor", "mod", "readonly"},
+	"centrality": centralityMeasures,
+	"timeline":   timelineKinds,
+}
+
+// fileFlags and dirFlags are the flags taking a file or directory name.
+var (
+	fileFlags = []string{"config", "annotations", "roots-file", "from-list", "vulns", "binary", "work", "go", "o"}
+	dirFlags  = []string{"monorepo", "gopath", "plugin", "timeline-dir"}
+)
+
+// runCompletion implements the completion subcommand: `completion bash`,
+// `completion zsh` and `completion fish` print a completion script for that
+// shell, and `completion packages`, which the scripts run, prints the import
+// path prefixes known from the nearest go.mod: its module and requirements.
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: godepgraph completion bash|zsh|fish")
+	}
+	var err error
+	switch args[0] {
+	case "bash":
+		err = writeBashCompletion(os.Stdout)
+	case "zsh":
+		fmt.Fprintln(os.Stdout, "autoload -U +X bashcompinit && bashcompinit")
+		err = writeBashCompletion(os.Stdout)
+	case "fish":
+		err = writeFishCompletion(os.Stdout)
+	case "packages":
+		err = writeCompletionPackages(os.Stdout)
+	default:
+		log.Fatalf("unsupported shell %q", args[0])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// completionFlags returns the names of all flags, sorted, and those taking a
+// value.
+func completionFlags() (names []string, takesValue map[string]bool) {
+	takesValue = make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			takesValue[f.Name] = true
+		}
+	})
+	sort.Strings(names)
+	return names, takesValue
+}
+
+func writeBashCompletion(w io.Writer) error {
+	names, takesValue := completionFlags()
+	var words, valueFlags []string
+	for _, name := range names {
+		words = append(words, "-"+name)
+		if takesValue[name] {
+			valueFlags = append(valueFlags, "-"+name, "--"+name)
+		}
+	}
+	patterns := func(names []string) string {
+		var ps []string
+		for _, name := range names {
+			ps = append(ps, "-"+name, "--"+name)
+		}
+		return strings.Join(ps, "|")
+	}
+
+	fmt.Fprintln(w, "_godepgraph() {")
+	fmt.Fprintln(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	fmt.Fprintln(w, "\tcase \"$prev\" in")
+	valueNames := make([]string, 0, len(flagValues))
+	for name := range flagValues {
+		valueNames = append(valueNames, name)
+	}
+	sort.Strings(valueNames)
+	for _, name := range valueNames {
+		fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\")); return;;\n", patterns([]string{name}), strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\")); return;;\n", patterns(fileFlags))
+	fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -d -- \"$cur\")); return;;\n", patterns(dirFlags))
+	fmt.Fprintf(w, "\t%s)\n\t\treturn;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tif [[ \"$cur\" == -* ]]; then")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(words, " "))
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$(godepgraph completion packages 2>/dev/null)\" -- \"$cur\") $(compgen -d -- \"$cur\"))")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	_, err := fmt.Fprintln(w, "complete -F _godepgraph godepgraph")
+	return err
+}
+
+func writeFishCompletion(w io.Writer) error {
+	names, takesValue := completionFlags()
+	files := make(map[string]bool)
+	for _, name := range append(fileFlags, dirFlags...) {
+		files[name] = true
+	}
+	for _, name := range names {
+		usage := strings.Replace(flag.Lookup(name).Usage, "'", `\'`, -1)
+		fmt.Fprintf(w, "complete -c godepgraph -o %s -d '%s'", name, usage)
+		switch {
+		case flagValues[name] != nil:
+			fmt.Fprintf(w, " -x -a '%s'", strings.Join(flagValues[name], " "))
+		case files[name]:
+			fmt.Fprint(w, " -r -F")
+		case takesValue[name]:
+			fmt.Fprint(w, " -x")
+		}
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintln(w, "complete -c godepgraph -a '(godepgraph completion packages 2>/dev/null)'")
+	return err
+}
+
+func writeCompletionPackages(w io.Writer) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path := findGoMod(cwd)
+	if path == "" {
+		return nil
+	}
+	mf, err := readGoMod(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, mf.Path)
+	fmt.Fprintln(w, mf.Path+"/...")
+	for _, req := range mf.Require {
+		fmt.Fprintln(w, req.Path)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configName is the name of the configuration file looked for in the
+// working directory.
+const configName = ".godepgraph.yaml"
+
+// A configEntry sets the flag Name to each of Values.
+type configEntry struct {
+	Name   string
+	Values []string
+	Line   int
+}
+
+// applyConfig reads the configuration file at path and sets the flags it
+// lists that weren't given on the command line. A missing file is not an
+// error unless required is set.
+func applyConfig(path string, required bool) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := parseConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[flagName(f.Name)] = true
+	})
+	for _, e := range entries {
+		fl := flag.Lookup(e.Name)
+		if fl == nil || e.Name == "config" {
+			return fmt.Errorf("%s:%d: unknown flag %s", path, e.Line, e.Name)
+		}
+		if set[flagName(e.Name)] {
+			continue
+		}
+		values := e.Values
+		if _, ok := fl.Value.(*stringList); !ok && len(values) > 1 {
+			// Lists of values for flags taking comma-separated lists.
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, v := range values {
+			if err := fl.Value.Set(v); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %s", path, e.Line, v, e.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseConfig parses the subset of YAML used by configuration files: a
+// mapping from flag names, without the leading dash, to a scalar or a
+// sequence of scalars.
+//
+//	p: github.com/foo/
+//	i:
+//	  - github.com/foo/bar
+//	  - github.com/foo/baz
+//	s: true
+func parseConfig(r io.Reader) ([]configEntry, error) {
+	var entries []configEntry
+	last := -1 // the entry taking list items
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := stripComment(s.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if last < 0 {
+				return nil, fmt.Errorf("line %d: list item outside of a key", lineno)
+			}
+			v, err := configScalar(strings.TrimSpace(trimmed[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineno, err)
+			}
+			entries[last].Values = append(entries[last].Values, v)
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineno)
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", lineno)
+		}
+		name := strings.TrimPrefix(strings.TrimSpace(line[:i]), "-")
+		e := configEntry{Name: name, Line: lineno}
+		last = -1
+		if rest := strings.TrimSpace(line[i+1:]); rest != "" {
+			v, err := configScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineno, err)
+			}
+			e.Values = []string{v}
+		} else {
+			last = len(entries)
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
+
+// stripComment removes a # comment from line. A # only starts a comment at
+// the start of the line or after whitespace, and not inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// configScalar returns the value of a plain, single- or double-quoted
+// scalar.
+func configScalar(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	if len(s) > 0 && s[0] == '"' {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeConstraints writes, instead of the graph, the imports of every one of
+// roots by the build constraints of the files importing them to w, so that
+// it shows which tags and platforms bring each import in. All the files of
+// the package are read, whether the build context selects them or not;
+// those it doesn't are marked excluded.
+func writeConstraints(w io.Writer, cwd string, roots []string) {
+	for i, root := range roots {
+		pkg, err := importPackage(loaderPath(root, cwd), cwd)
+		if err != nil {
+			log.Fatalf("failed to import %s: %s", root, err)
+		}
+		byImport, err := fileConstraints(pkg.Dir, pkg.Name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, root)
+		var imps []string
+		for imp := range byImport {
+			if !isIgnoredPath(imp) && !(*ignoreStdlib && isGorootPath(imp)) {
+				imps = append(imps, imp)
+			}
+		}
+		sort.Strings(imps)
+		for _, imp := range imps {
+			fmt.Fprintf(w, "\t%s\n", imp)
+			for _, line := range byImport[imp] {
+				fmt.Fprintf(w, "\t\t%s\n", line)
+			}
+		}
+	}
+}
+
+// fileConstraints reads the Go files of the package name in dir, and of its
+// external test package with -t, and returns for every import the lines
+// "constraint: files" of the constraints of the files importing it, sorted.
+// Files without constraints are listed as "always".
+func fileConstraints(dir, name string) (map[string][]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]map[string][]string)
+	fset := token.NewFileSet()
+	for _, fi := range fis {
+		file := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(file, ".go") || strings.HasPrefix(file, "_") || strings.HasPrefix(file, ".") {
+			continue
+		}
+		isTest := strings.HasSuffix(file, "_test.go")
+		if isTest && !*includeTests {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, file), nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if f.Name.Name != name && !(isTest && f.Name.Name == name+"_test") {
+			continue
+		}
+
+		var conds []string
+		conds = append(conds, fileNameConstraints(file)...)
+		for _, c := range f.Comments {
+			if c.Pos() > f.Package {
+				break
+			}
+			for _, line := range c.List {
+				if constraint.IsGoBuild(line.Text) || constraint.IsPlusBuild(line.Text) {
+					if x, err := constraint.Parse(line.Text); err == nil && !containsString(conds, x.String()) {
+						conds = append(conds, x.String())
+					}
+				}
+			}
+		}
+		usesC := false
+		for _, spec := range f.Imports {
+			if spec.Path.Value == `"C"` {
+				usesC = true
+			}
+		}
+		if usesC {
+			conds = append(conds, "cgo")
+		}
+		if isTest {
+			conds = append(conds, "test")
+		}
+		cond := "always"
+		if len(conds) > 0 {
+			cond = joinConstraints(conds)
+		}
+		if ok, err := buildContext.MatchFile(dir, file); err == nil && !ok {
+			cond += " (excluded)"
+		}
+
+		for _, spec := range f.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || imp == "C" {
+				continue
+			}
+			if files[imp] == nil {
+				files[imp] = make(map[string][]string)
+			}
+			files[imp][cond] = append(files[imp][cond], file)
+		}
+	}
+
+	byImport := make(map[string][]string)
+	for imp, byCond := range files {
+		for cond, names := range byCond {
+			byImport[imp] = append(byImport[imp], cond+": "+strings.Join(names, ", "))
+		}
+		sort.Strings(byImport[imp])
+	}
+	return byImport, nil
+}
+
+// joinConstraints returns the conjunction of the constraint expressions
+// conds, parenthesizing the disjunctions among them.
+func joinConstraints(conds []string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	parts := make([]string, len(conds))
+	for i, c := range conds {
+		if strings.Contains(c, "||") {
+			c = "(" + c + ")"
+		}
+		parts[i] = c
+	}
+	return strings.Join(parts, " && ")
+}
+
+// fileNameConstraints returns the GOOS and GOARCH the name of a Go file
+// constrains it to, as in x_linux_arm64.go, according to the platforms the
+// go command knows.
+func fileNameConstraints(file string) []string {
+	name := strings.TrimSuffix(strings.TrimSuffix(file, ".go"), "_test")
+	parts := strings.Split(name, "_")
+	if len(parts) < 2 {
+		return nil
+	}
+	oses, arches := knownPlatforms()
+	last := parts[len(parts)-1]
+	if len(parts) >= 3 && oses[parts[len(parts)-2]] && arches[last] {
+		return []string{parts[len(parts)-2], last}
+	}
+	if oses[last] || arches[last] {
+		return []string{last}
+	}
+	return nil
+}
+
+var knownOS, knownArch map[string]bool
+
+// knownPlatforms returns the GOOS and GOARCH values listed by
+// `go tool dist list`, read once.
+func knownPlatforms() (oses, arches map[string]bool) {
+	if knownOS != nil {
+		return knownOS, knownArch
+	}
+	knownOS, knownArch = make(map[string]bool), make(map[string]bool)
+	out, err := exec.Command(goCmd, "tool", "dist", "list").Output()
+	if err != nil {
+		log.Printf("failed to list platforms: %s", err)
+	}
+	for _, line := range strings.Fields(string(out)) {
+		if i := strings.IndexByte(line, '/'); i > 0 {
+			knownOS[line[:i]] = true
+			knownArch[line[i+1:]] = true
+		}
+	}
+	return knownOS, knownArch
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, t := range list {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A GraphService answers queries about the graph of the command line of
+// the daemon subcommand over JSON-RPC. It keeps the graph in memory and
+// scans the tree again only once a Go file of the graph has changed.
+type GraphService struct {
+	cwd  string
+	args []string
+
+	mu        sync.Mutex
+	graph     *jsonGraph
+	imports   map[string][]string
+	importers map[string][]string
+	files     map[string]time.Time
+}
+
+// GraphArgs selects the part of the graph returned by Resolve, as the
+// stdlib, focus and depth parameters of serve do.
+type GraphArgs struct {
+	HideStdlib bool
+	Focus      string
+	Depth      int
+}
+
+// Graph is the reply of Resolve, a graph as -format json writes it; net/rpc
+// only replies with exported types.
+type Graph jsonGraph
+
+// WhyArgs asks for a chain of imports from From to To.
+type WhyArgs struct {
+	From, To string
+}
+
+// PackageArgs names a package, with Transitive set to ask about indirect
+// importers too.
+type PackageArgs struct {
+	Package    string
+	Transitive bool
+}
+
+// runDaemon implements the daemon subcommand: it serves GraphService on the
+// -listen address, a unix socket if it starts with unix:, until killed, and
+// its metrics on the -metrics address if set.
+func runDaemon(cwd string) {
+	s := &GraphService{
+		cwd:  cwd,
+		args: rerunArgs("-format=json", "-o=", "-watch=false", "-compare-ref=", "-check="),
+	}
+	if err := s.rescan(nil); err != nil {
+		log.Fatal(err)
+	}
+	if *metricsAddr != "" {
+		listenMetrics(*metricsAddr, s.metricsGraph)
+	}
+	srv := rpc.NewServer()
+	if err := srv.Register(s); err != nil {
+		log.Fatal(err)
+	}
+
+	network, addr := "tcp", *listenAddr
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+		os.Remove(addr)
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("answering JSON-RPC on %s", *listenAddr)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// rescan computes the graph again or, if the directories whose files
+// changed are given, updates it with -incremental.
+func (s *GraphService) rescan(changed []string) error {
+	args := s.args
+	if len(changed) > 0 && s.graph != nil && incrementalConflict() == "" {
+		f, err := ioutil.TempFile("", "godepgraph-daemon")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		err = writeIndentedJSON(f, s.graph)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		overrides := []string{"-format=json", "-o=", "-watch=false", "-compare-ref=", "-check=", "-incremental=" + f.Name()}
+		for _, dir := range changed {
+			overrides = append(overrides, "-changed="+dir)
+		}
+		args = rerunArgs(overrides...)
+	}
+	g, err := runGraph(s.cwd, args)
+	if err != nil {
+		return err
+	}
+	s.graph = g
+	s.imports, s.importers = g.Adjacency()
+	s.files = watchedFiles(s.cwd, graphDirs(g))
+	return nil
+}
+
+// graphDirs returns the directories of the packages of g outside GOROOT.
+func graphDirs(g *jsonGraph) []string {
+	var dirs []string
+	for _, p := range g.Packages {
+		if !p.Goroot && p.Dir != "" {
+			dirs = append(dirs, p.Dir)
+		}
+	}
+	return dirs
+}
+
+// current locks s, scanning the tree again if a file of the graph changed,
+// only for the packages of the changed directories unless a go.mod or go.sum
+// file did, and returns the function unlocking it.
+func (s *GraphService) current() (func(), error) {
+	s.mu.Lock()
+	if files := watchedFiles(s.cwd, graphDirs(s.graph)); !sameModTimes(s.files, files) {
+		changed, _ := changedDirs(s.files, files)
+		if err := s.rescan(changed); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	return s.mu.Unlock, nil
+}
+
+// metricsGraph returns the graph, scanning the tree again first if a file of
+// it changed.
+func (s *GraphService) metricsGraph() (*jsonGraph, error) {
+	unlock, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.graph, nil
+}
+
+// Resolve returns the graph, restricted as args asks.
+func (s *GraphService) Resolve(args GraphArgs, reply *Graph) error {
+	unlock, err := s.current()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	g, err := filterJSONGraph(s.graph, args.HideStdlib, args.Focus, args.Depth)
+	if err != nil {
+		return err
+	}
+	*reply = Graph(*g)
+	return nil
+}
+
+// Why returns a shortest chain of imports from args.From to args.To.
+func (s *GraphService) Why(args WhyArgs, reply *[]string) error {
+	unlock, err := s.current()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prev := map[string]string{args.From: ""}
+	queue := []string{args.From}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if name == args.To {
+			var chain []string
+			for n := name; n != ""; n = prev[n] {
+				chain = append([]string{n}, chain...)
+			}
+			*reply = chain
+			return nil
+		}
+		for _, imp := range s.imports[name] {
+			if _, ok := prev[imp]; !ok {
+				prev[imp] = name
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return fmt.Errorf("%s does not import %s", args.From, args.To)
+}
+
+// ReverseDeps returns the sorted packages importing args.Package, directly
+// or, with args.Transitive, through other packages.
+func (s *GraphService) ReverseDeps(args PackageArgs, reply *[]string) error {
+	unlock, err := s.current()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !args.Transitive {
+		*reply = append([]string{}, s.importers[args.Package]...)
+		return nil
+	}
+	seen := reachable(s.importers[args.Package], func(name string) []string {
+		return s.importers[name]
+	})
+	delete(seen, args.Package)
+	deps := []string{}
+	for name := range seen {
+		deps = append(deps, name)
+	}
+	sort.Strings(deps)
+	*reply = deps
+	return nil
+}
+
+// Rescan scans the tree again.
+func (s *GraphService) Rescan(args struct{}, reply *bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.rescan(nil); err != nil {
+		return err
+	}
+	*reply = true
+	return nil
+}
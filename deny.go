@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kisielk/godepgraph/pattern"
+)
+
+// A denyRule forbids imports from the packages matching From of the
+// packages matching To.
+type denyRule struct {
+	From, To pattern.Pattern
+}
+
+func (r denyRule) String() string {
+	return r.From.String() + "=>" + r.To.String()
+}
+
+// denyRules holds the rules given with -deny.
+var denyRules []denyRule
+
+// parseDenyRule parses a rule of the form from=>to.
+func parseDenyRule(s string) (denyRule, error) {
+	i := strings.Index(s, "=>")
+	if i < 0 {
+		return denyRule{}, fmt.Errorf("malformed rule %q, want from=>to", s)
+	}
+	from, to := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+2:])
+	if from == "" || to == "" {
+		return denyRule{}, fmt.Errorf("malformed rule %q, want from=>to", s)
+	}
+	var r denyRule
+	var err error
+	if r.From, err = pattern.Parse(from); err != nil {
+		return r, err
+	}
+	if r.To, err = pattern.Parse(to); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// deniedBy returns the first rule forbidding the import of imp by pkg, or
+// nil if it is allowed.
+func deniedBy(pkg, imp string) *denyRule {
+	for i := range denyRules {
+		if r := &denyRules[i]; r.From.Match(pkg) && r.To.Match(imp) {
+			return r
+		}
+	}
+	return nil
+}
+
+// edgeDenial returns why the import of imp by pkg is forbidden, by a -deny
+// rule or the -layers rules, or the empty string if it is allowed.
+func edgeDenial(pkg, imp string) string {
+	if r := deniedBy(pkg, imp); r != nil {
+		return "denied by " + r.String()
+	}
+	if layers != nil {
+		if from, to, ok := layers.violation(pkg, imp); ok {
+			return fmt.Sprintf("layer %s may not import layer %s", from, to)
+		}
+	}
+	return moveProblems[[2]string{pkg, imp}]
+}
+
+// checkDenied reports the drawn edges forbidden by a -deny rule.
+func checkDenied(pkgKeys []string) []violation {
+	var vs []violation
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			if r := deniedBy(name, imp); r != nil {
+				vs = append(vs, violation{"deny", fmt.Sprintf("%s -> %s\t%s", name, imp, r)})
+			}
+		}
+	}
+	return vs
+}
+
+// reportDenied writes the forbidden edges to w and returns their number.
+func reportDenied(w io.Writer, pkgKeys []string) int {
+	vs := checkDenied(pkgKeys)
+	for _, v := range vs {
+		fmt.Fprintf(w, "denied import: %s\n", strings.Replace(v.Detail, "\t", " by rule ", 1))
+	}
+	return len(vs)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// deprecatedPackages maps deprecated or frozen packages to a short note on
+// what to use instead.
+var deprecatedPackages = map[string]string{
+	"io/ioutil":                        "deprecated: use io and os",
+	"syscall":                          "frozen: use golang.org/x/sys",
+	"golang.org/x/net/context":         "alias: use context",
+	"crypto/dsa":                       "deprecated: use crypto/ed25519 or crypto/ecdsa",
+	"crypto/elliptic":                  "deprecated for direct use: use crypto/ecdh or crypto/ecdsa",
+	"net/rpc":                          "frozen: consider gRPC or net/http",
+	"net/rpc/jsonrpc":                  "frozen: consider gRPC or net/http",
+	"net/smtp":                         "frozen: consider a maintained third-party client",
+	"log/syslog":                       "frozen: consider a maintained third-party client",
+	"golang.org/x/crypto/ssh/terminal": "deprecated: use golang.org/x/term",
+	"golang.org/x/net/http2/h2demo":    "removed upstream",
+}
+
+func isDeprecated(path string) bool {
+	_, ok := deprecatedPackages[path]
+	return ok
+}
+
+// reportDeprecated writes the deprecated packages that are imported by any
+// scanned package, along with their importers, to w.
+func reportDeprecated(w io.Writer) {
+	importers := make(map[string][]string)
+	for _, pkg := range pkgs {
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range getImports(pkg) {
+			if isDeprecated(imp) && !ignored[imp] {
+				importers[imp] = append(importers[imp], pkg.ImportPath)
+			}
+		}
+	}
+	if len(importers) == 0 {
+		fmt.Fprintln(w, "no deprecated packages imported")
+		return
+	}
+
+	keys := []string{}
+	for k := range importers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "deprecated packages:")
+	for _, k := range keys {
+		sort.Strings(importers[k])
+		fmt.Fprintf(w, "\t%s (%s)\n\t\timported by %s\n", k, deprecatedPackages[k], strings.Join(importers[k], ", "))
+	}
+}
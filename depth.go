@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// rootDistances returns the number of imports on the shortest chain from
+// one of roots to every package they reach.
+func rootDistances(roots []string) map[string]int {
+	dist := make(map[string]int)
+	var queue []string
+	for _, root := range roots {
+		if _, ok := dist[root]; !ok {
+			dist[root] = 0
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, imp := range drawnImports(name) {
+			if _, ok := dist[imp]; !ok {
+				dist[imp] = dist[name] + 1
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return dist
+}
+
+// maxDistance returns the largest of the distances dist.
+func maxDistance(dist map[string]int) int {
+	max := 0
+	for _, d := range dist {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// decorateDepth colors, with -depth-colors, the node of a package at the
+// distance d from the nearest root, of at most max, on a scale of blues
+// from the roots to the deepest packages, so that a package importing
+// one of a lighter shade, nearer the roots, stands out.
+func decorateDepth(a *attrs, d, max int) {
+	shade := 1 + d
+	if max > 8 {
+		shade = 1 + d*8/max
+	}
+	a.set("color", "/blues9/"+strconv.Itoa(shade))
+	if shade >= 6 {
+		a.set("fontcolor", "white")
+	}
+	a.appendAttr("tooltip", fmt.Sprintf("depth %d from the roots", d), `\n`)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// A graphDiff lists the packages and edges added and removed between two
+// graphs written by -format json.
+type graphDiff struct {
+	AddedPackages   []string
+	RemovedPackages []string
+	AddedEdges      []diffEdge
+	RemovedEdges    []diffEdge
+}
+
+type diffEdge struct {
+	From string
+	To   string
+}
+
+func (e diffEdge) String() string {
+	return e.From + " -> " + e.To
+}
+
+// runDiff implements the diff subcommand: `diff old.json new.json` compares
+// two graphs written by -format json and reports the differences, as text,
+// with -format json as a graphDiff or with -format dot as a graph of both.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text, json or dot")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: godepgraph diff [-format text|json|dot] old.json new.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "text", "json", "dot":
+	default:
+		log.Fatalf("unsupported diff format %q, want text, json or dot", *format)
+	}
+
+	before, err := readJSONGraph(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := readJSONGraph(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := diffGraphs(before, after)
+	switch *format {
+	case "json":
+		err = writeIndentedJSON(os.Stdout, d)
+	case "dot":
+		err = writeDiffDot(os.Stdout, before, after, d)
+	default:
+		err = writeDiffText(os.Stdout, d)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readJSONGraph reads a graph written by -format json from the file at path.
+func readJSONGraph(path string) (*jsonGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := graph.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return g, nil
+}
+
+// graphSets returns the packages and import edges of g as sets.
+func graphSets(g *jsonGraph) (map[string]bool, map[diffEdge]bool) {
+	packages := make(map[string]bool)
+	for _, p := range g.Packages {
+		packages[p.ImportPath] = true
+	}
+	edges := make(map[diffEdge]bool)
+	for _, e := range g.Edges {
+		// Implements edges are no dependencies to compare.
+		if e.Kind == "" {
+			edges[diffEdge{e.From, e.To}] = true
+		}
+	}
+	return packages, edges
+}
+
+// diffGraphs returns the differences from before to after, sorted.
+func diffGraphs(before, after *jsonGraph) graphDiff {
+	oldPackages, oldEdges := graphSets(before)
+	newPackages, newEdges := graphSets(after)
+	d := graphDiff{
+		AddedPackages:   []string{},
+		RemovedPackages: []string{},
+		AddedEdges:      []diffEdge{},
+		RemovedEdges:    []diffEdge{},
+	}
+	for p := range newPackages {
+		if !oldPackages[p] {
+			d.AddedPackages = append(d.AddedPackages, p)
+		}
+	}
+	for p := range oldPackages {
+		if !newPackages[p] {
+			d.RemovedPackages = append(d.RemovedPackages, p)
+		}
+	}
+	for e := range newEdges {
+		if !oldEdges[e] {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for e := range oldEdges {
+		if !newEdges[e] {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+	sort.Strings(d.AddedPackages)
+	sort.Strings(d.RemovedPackages)
+	sortEdges(d.AddedEdges)
+	sortEdges(d.RemovedEdges)
+	return d
+}
+
+func sortEdges(edges []diffEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+// writeDiffText writes d to w, one package or edge per line prefixed with +
+// if it was added or - if it was removed.
+func writeDiffText(w io.Writer, d graphDiff) error {
+	for _, p := range d.RemovedPackages {
+		if _, err := fmt.Fprintf(w, "- package %s\n", p); err != nil {
+			return err
+		}
+	}
+	for _, p := range d.AddedPackages {
+		if _, err := fmt.Fprintf(w, "+ package %s\n", p); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		if _, err := fmt.Fprintf(w, "- edge %s\n", e); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.AddedEdges {
+		if _, err := fmt.Fprintf(w, "+ edge %s\n", e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiffDot writes the union of the graphs before and after to w in
+// Graphviz dot format. Packages and edges added are drawn green, those
+// removed red and dashed, and the rest as godepgraph draws them.
+func writeDiffDot(w io.Writer, before, after *jsonGraph, d graphDiff) error {
+	info := make(map[string]jsonPackage)
+	for _, g := range []*jsonGraph{before, after} {
+		for _, p := range g.Packages {
+			info[p.ImportPath] = p
+		}
+	}
+	added := make(map[string]bool)
+	for _, p := range d.AddedPackages {
+		added[p] = true
+	}
+	removed := make(map[string]bool)
+	for _, p := range d.RemovedPackages {
+		removed[p] = true
+	}
+	addedEdges := make(map[diffEdge]bool)
+	for _, e := range d.AddedEdges {
+		addedEdges[e] = true
+	}
+	removedEdges := make(map[diffEdge]bool)
+	for _, e := range d.RemovedEdges {
+		removedEdges[e] = true
+	}
+
+	_, beforeEdges := graphSets(before)
+	_, afterEdges := graphSets(after)
+	var edges []diffEdge
+	for e := range beforeEdges {
+		edges = append(edges, e)
+	}
+	for e := range afterEdges {
+		if !beforeEdges[e] {
+			edges = append(edges, e)
+		}
+	}
+	sortEdges(edges)
+	for _, e := range edges {
+		for _, p := range []string{e.From, e.To} {
+			if _, ok := info[p]; !ok {
+				info[p] = jsonPackage{ImportPath: p}
+			}
+		}
+	}
+	var names []string
+	for name := range info {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nodeIds := make(map[string]int)
+	for i, name := range names {
+		nodeIds[name] = i
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "digraph godep {")
+	for _, name := range names {
+		a := jsonNodeAttrs(info[name])
+		switch {
+		case added[name]:
+			a.set("color", "green3")
+			a.set("fillcolor", "honeydew")
+			a.set("penwidth", "2")
+		case removed[name]:
+			a.addStyle("dashed")
+			a.set("color", "red")
+			a.set("fillcolor", "mistyrose")
+		}
+		fmt.Fprintf(&b, "_%d [%s];\n", nodeIds[name], a)
+	}
+	for _, e := range edges {
+		var ea attrs
+		if addedEdges[e] {
+			ea = attrs{{"color", "green3"}, {"penwidth", "2"}}
+		} else if removedEdges[e] {
+			ea = attrs{{"color", "red"}, {"style", "dashed"}}
+		}
+		if len(ea) > 0 {
+			fmt.Fprintf(&b, "_%d -> _%d [%s];\n", nodeIds[e.From], nodeIds[e.To], ea)
+		} else {
+			fmt.Fprintf(&b, "_%d -> _%d;\n", nodeIds[e.From], nodeIds[e.To])
+		}
+	}
+	fmt.Fprintln(&b, "}")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsonNodeAttrs returns the dot attributes of a package read from a JSON
+// graph, colored as the dot format colors them.
+func jsonNodeAttrs(p jsonPackage) attrs {
+	color := "paleturquoise"
+	if p.Goroot {
+		color = "palegreen"
+	} else if p.Cgo {
+		color = "darkgoldenrod1"
+	}
+	a := attrs{{"label", p.ImportPath}, {"style", "filled"}, {"color", color}}
+	decorateAnnotations(&a, p.Annotations)
+	return a
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"html"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+func init() {
+	render.Register("dot", func(w io.Writer) render.Writer { return newDotWriter(w) })
+}
+
+// A dotWriter is the dot format of godepgraph: a render.DotWriter drawing
+// the packages the command loaded with everything its flags add, and the
+// others, such as those of the graphs serve, explore and merge read back,
+// with what their graph records.
+type dotWriter struct {
+	*render.DotWriter
+	w io.Writer
+
+	// What the flags draw, computed once for the loaded packages.
+	caps                         map[string][]string
+	unsafeDirect, unsafeIndirect map[string]bool
+	dups, mismatches, fanIn      map[string][]string
+	tainted                      map[string]bool
+	unported, reach              map[string][]string
+	dist                         map[string]int
+	distMax                      int
+	churnMax                     int
+	buildMax                     time.Duration
+	sizeMax                      int64
+
+	// loaded are the loaded packages drawn, for the clusters, and missing
+	// the packages drawn as missing.
+	loaded  []string
+	missing map[string]bool
+	// from is the package whose edges are being drawn, and blank and
+	// counts its blank imports and the counts of its edges.
+	from   string
+	blank  map[string][]token.Position
+	counts map[string]*edgeCount
+}
+
+func newDotWriter(w io.Writer) *dotWriter {
+	d := &dotWriter{DotWriter: render.NewDotWriter(w), w: w, missing: make(map[string]bool)}
+	d.NodeAttrs, d.EdgeAttrs = d.nodeAttrs, d.edgeAttrs
+	return d
+}
+
+// writeGraphDot writes g, read back rather than loaded, in the dot format,
+// scaling its packages by the churn, compile time and code size it records.
+func writeGraphDot(w io.Writer, g *jsonGraph) error {
+	d := newDotWriter(w)
+	for _, p := range g.Packages {
+		if p.Churn > d.churnMax {
+			d.churnMax = p.Churn
+		}
+		if p.CompileTime > d.buildMax {
+			d.buildMax = p.CompileTime
+		}
+		if p.CodeSize > d.sizeMax {
+			d.sizeMax = p.CodeSize
+		}
+	}
+	return render.Emit(d, g)
+}
+
+func (d *dotWriter) Begin() error {
+	if err := d.DotWriter.Begin(); err != nil {
+		return err
+	}
+	if *horizontal {
+		fmt.Fprintln(d.w, `rankdir="LR"`)
+	}
+	if len(pkgs) == 0 {
+		return nil
+	}
+	// The packages the command loaded are numbered by getId, and drawn
+	// each followed by its edges, as they always were.
+	d.ID = func(path, pattern string) int {
+		if pattern != "" {
+			return getId(path + "\x00" + pattern)
+		}
+		return getId(path)
+	}
+	d.Grouped = true
+	var pkgKeys []string
+	for name := range pkgs {
+		pkgKeys = append(pkgKeys, name)
+	}
+	sort.Strings(pkgKeys)
+
+	if *showCaps {
+		d.caps = taint(directCapabilities)
+	}
+	if *markUnsafe {
+		d.unsafeDirect, d.unsafeIndirect = unsafeUsers(*unsafeDeep)
+	}
+	if *showMajor {
+		d.dups = majorDuplicates(pkgKeys)
+	}
+	if plugins != nil {
+		d.mismatches = pluginMismatches(pkgKeys)
+	}
+	if *cgoTaint {
+		d.tainted = cgoTainted()
+	}
+	d.unported = unportable()
+	if *churnPeriod != "" {
+		d.churnMax = maxChurn(pkgKeys)
+	}
+	d.buildMax = maxCompileTime(pkgKeys)
+	if *hideFanIn > 0 {
+		d.fanIn = importers()
+	}
+	if *depthColors {
+		d.dist = rootDistances(rootPaths)
+		d.distMax = maxDistance(d.dist)
+	}
+	if *rootColors {
+		d.reach = rootReach(rootPaths)
+	}
+	d.sizeMax = maxCodeSize(pkgKeys)
+	return nil
+}
+
+func (d *dotWriter) Node(p graph.Package) error {
+	if !*showEmbeds {
+		p.Embeds = nil
+	}
+	if p.Missing {
+		d.missing[p.ImportPath] = true
+	} else if pkgs[p.ImportPath] != nil {
+		d.loaded = append(d.loaded, p.ImportPath)
+	}
+	return d.DotWriter.Node(p)
+}
+
+// Edge draws e, unless it imports a package whose imports are hidden by
+// -hide-fan-in.
+func (d *dotWriter) Edge(e graph.Edge) error {
+	if *hideFanIn > 0 && e.Kind == "" && len(d.fanIn[e.To]) > *hideFanIn {
+		return nil
+	}
+	return d.DotWriter.Edge(e)
+}
+
+func (d *dotWriter) End() error {
+	if err := d.Flush(); err != nil {
+		return err
+	}
+	sort.Strings(d.loaded)
+	if *moduleClusters {
+		writeModuleClusters(d.w, d.loaded, modReqs)
+	}
+	if *ownersFile != "" {
+		writeOwnerClusters(d.w, d.loaded)
+	}
+	if *orgClusters {
+		writeOrgClusters(d.w, d.loaded)
+	}
+	return d.DotWriter.End()
+}
+
+// nodeAttrs returns the attributes of the node of p: those of its graph,
+// and, if the command loaded it, those of its package.
+func (d *dotWriter) nodeAttrs(p graph.Package) string {
+	pkgName := p.ImportPath
+	if p.Missing {
+		return missingAttrs(pkgName, p.Error).String()
+	}
+	var color string
+	if p.Goroot {
+		color = "palegreen"
+	} else if p.Cgo {
+		color = "darkgoldenrod1"
+	} else if d.tainted[pkgName] {
+		color = "lightgoldenrod1"
+	} else {
+		color = "paleturquoise"
+	}
+
+	pkg := pkgs[pkgName]
+	label := pkgName
+	if *showVersions && !*htmlLabels && pkg != nil {
+		label = versionedLabel(pkgName)
+	}
+
+	a := attrs{{"label", label}, {"style", "filled"}, {"color", color}}
+	if pkg != nil {
+		d.decoratePackage(&a, pkg)
+	}
+	if *churnPeriod != "" && !p.Goroot {
+		decorateChurn(&a, p.Churn, d.churnMax)
+	}
+	if *buildTimes {
+		decorateBuildTime(&a, p.CompileTime, d.buildMax)
+	}
+	if *sizeBinary != "" {
+		decorateCodeSize(&a, p.CodeSize, d.sizeMax)
+	}
+	decorateAnnotations(&a, p.Annotations)
+	if plugins != nil && pkg != nil {
+		decoratePlugin(&a, pkgName, d.mismatches)
+	}
+	if p.Tool {
+		a.set("color", "gainsboro")
+		a.appendAttr("tooltip", "tooling", `\n`)
+	}
+	if *linkTemplate != "" && pkg != nil {
+		if u := packageURL(pkg); u != "" {
+			a.set("URL", u)
+		}
+	}
+	if p.Doc != "" {
+		a.appendAttr("tooltip", dotEscape(p.Doc), `\n`)
+	}
+	if n := len(d.fanIn[pkgName]); *hideFanIn > 0 && n > *hideFanIn {
+		a.appendAttr("label", fmt.Sprintf("(%d imports hidden)", n), `\n`)
+	}
+	if *htmlLabels && pkg != nil {
+		a.set("label", htmlLabel(pkg, a.get("label")))
+	}
+	return a.String()
+}
+
+// decoratePackage adds to a what the flags draw of the loaded package pkg.
+func (d *dotWriter) decoratePackage(a *attrs, pkg *node) {
+	pkgName := pkg.ImportPath
+	if reasons := d.unported[pkgName]; len(reasons) > 0 {
+		decoratePortable(a, pkg, reasons)
+	}
+	if members := summaryMembers[pkgName]; len(members) > 0 {
+		decorateSummary(a, members)
+	}
+	if changedPkgs[pkgName] {
+		a.set("color", "gold")
+		a.appendAttr("tooltip", "changed", `\n`)
+	}
+	if pkgName == "C" {
+		a.set("shape", "box")
+		a.set("color", "darkgoldenrod1")
+		a.set("tooltip", "cgo")
+	}
+	if *markDeprecated && isDeprecated(pkgName) {
+		a.addStyle("dashed")
+		a.set("penwidth", "2")
+		a.set("fontcolor", "firebrick")
+		a.appendAttr("tooltip", deprecatedPackages[pkgName], `\n`)
+	}
+	if dist, ok := d.dist[pkgName]; ok {
+		decorateDepth(a, dist, d.distMax)
+	}
+	if *rootColors {
+		decorateRootReach(a, d.reach[pkgName], rootPaths)
+	}
+	if *showCaps {
+		decorateCapabilities(a, d.caps[pkgName])
+	}
+	if *showLicenses {
+		decorateLicense(a, pkg)
+	}
+	if *showPseudo {
+		decoratePseudo(a, pkgName)
+	}
+	if *showOrigins {
+		decorateOrigin(a, pkgName)
+	}
+	if *showOutdated {
+		decorateOutdated(a, pkgName)
+	}
+	if *showReplaced {
+		decorateReplaced(a, pkgName)
+	}
+	if *showMajor && !pkg.Goroot {
+		decorateMajor(a, pkgName, d.dups)
+	}
+	if vulns != nil {
+		decorateVulns(a, vulns, pkgName)
+	}
+	if *markInternal && (isInternal(pkgName) || isGorootVendored(pkg)) {
+		a.set("peripheries", "2")
+	}
+	if *markGenerated {
+		if gen, total := generatedFiles(pkg); gen*2 > total {
+			a.addStyle("dotted")
+			a.set("fontcolor", "gray40")
+			a.appendAttr("tooltip", fmt.Sprintf("%d of %d files generated", gen, total), `\n`)
+		}
+	}
+	if *markAsm && pkg.SFiles > 0 {
+		a.set("shape", "component")
+		a.appendAttr("tooltip", fmt.Sprintf("%d assembly files", pkg.SFiles), `\n`)
+	}
+	if *markUnsafe {
+		decorateUnsafe(a, d.unsafeDirect[pkgName], d.unsafeIndirect[pkgName])
+	}
+}
+
+// edgeAttrs returns the attributes of the edge e: those of its graph, and,
+// if the command loaded the importing package, those of its import.
+func (d *dotWriter) edgeAttrs(e graph.Edge) string {
+	if e.Kind == implementsKind {
+		ea := attrs{{"style", "dashed"}, {"color", "purple"}, {"arrowhead", "empty"}}
+		ea.set("tooltip", strings.Join(e.Symbols, `\n`))
+		return ea.String()
+	}
+	if d.missing[e.To] {
+		return `color="red"`
+	}
+	pkg, imp := pkgs[e.From], e.To
+	if pkg != nil && e.From != d.from {
+		d.from, d.blank, d.counts = e.From, nil, nil
+		if *markBlank {
+			d.blank = blankImports(pkg)
+		}
+		if *edgeCountsFlag {
+			d.counts = edgeCounts(pkg)
+		}
+	}
+
+	var ea attrs
+	if e.Test {
+		ea.set("style", "dashed")
+		ea.appendAttr("tooltip", "test import", `\n`)
+	} else if pkg != nil && isSharedTestImport(pkg, imp) {
+		// Half solid black, half gray: the code and the tests.
+		ea.set("color", "black;0.5:gray60")
+		ea.appendAttr("tooltip", "imported by the code and the tests", `\n`)
+	}
+	if inferred[[2]string{e.From, imp}] {
+		ea.set("style", "dashed")
+		ea.set("color", "gray50")
+	}
+	if pkg != nil {
+		if _, ok := d.blank[imp]; ok {
+			ea.set("style", "dashed")
+			ea.set("arrowhead", "odot")
+		}
+		if isToolImport(pkg, imp) {
+			ea.set("style", "dashed")
+			ea.set("color", "gray60")
+			ea.appendAttr("tooltip", "tool import", `\n`)
+		}
+	}
+	if e.Denied != "" {
+		ea.set("color", "red")
+		ea.set("penwidth", "2")
+		ea.appendAttr("tooltip", e.Denied, `\n`)
+	}
+	if c := d.counts[imp]; c != nil && pkg != nil {
+		ea.set("label", c.String())
+	}
+	if len(e.Symbols) > 0 {
+		ea.appendAttr("tooltip", strings.Join(e.Symbols, `\n`), `\n`)
+	}
+	if *showPositions {
+		if pkg != nil {
+			for _, pos := range importPositions(pkg, imp) {
+				ea.appendAttr("tooltip", fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line), `\n`)
+			}
+		} else {
+			// The positions of graphs are file:line:column.
+			for _, pos := range e.Positions {
+				pos = filepath.Base(pos)
+				if strings.Count(pos, ":") > 1 {
+					pos = pos[:strings.LastIndexByte(pos, ':')]
+				}
+				ea.appendAttr("tooltip", pos, `\n`)
+			}
+		}
+	}
+	return ea.String()
+}
+
+// attrs is an ordered list of DOT attributes.
+type attrs [][2]string
+
+// set sets the value of the attribute k, replacing any previous value.
+func (a *attrs) set(k, v string) {
+	for i := range *a {
+		if (*a)[i][0] == k {
+			(*a)[i][1] = v
+			return
+		}
+	}
+	*a = append(*a, [2]string{k, v})
+}
+
+func (a attrs) get(k string) string {
+	for _, kv := range a {
+		if kv[0] == k {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// addStyle appends s to the comma-separated style attribute.
+func (a *attrs) addStyle(s string) {
+	if cur := a.get("style"); cur != "" {
+		for _, st := range strings.Split(cur, ",") {
+			if st == s {
+				return
+			}
+		}
+		s = cur + "," + s
+	}
+	a.set("style", s)
+}
+
+// appendAttr appends v to the attribute k, separated from any previous value
+// by sep.
+func (a *attrs) appendAttr(k, v, sep string) {
+	if cur := a.get(k); cur != "" {
+		v = cur + sep + v
+	}
+	a.set(k, v)
+}
+
+// dotEscape escapes s for use in a quoted DOT string.
+func dotEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+func (a attrs) String() string {
+	parts := make([]string, len(a))
+	for i, kv := range a {
+		if kv[0] == "label" && strings.HasPrefix(kv[1], "<") && strings.HasSuffix(kv[1], ">") {
+			parts[i] = fmt.Sprintf("%s=%s", kv[0], kv[1])
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s=\"%s\"", kv[0], quoteEscape(kv[1]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteEscape escapes the double quotes of the attribute value s that
+// aren't escaped already. Values hold DOT escapes such as \n, so
+// backslashes are kept as they are.
+func quoteEscape(s string) string {
+	if !strings.Contains(s, `"`) {
+		return s
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '"' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// htmlLabel returns the HTML-like label of pkg, lines of which are those of
+// the plain label, separated by \n: the import path in bold, then the
+// module and version of the package, the synopsis of its package comment
+// with -doc and the other lines of the plain label, and last its badges.
+func htmlLabel(pkg *node, plain string) string {
+	lines := strings.Split(plain, `\n`)
+	var b strings.Builder
+	b.WriteString(`<<table border="0" cellborder="0" cellspacing="0">`)
+	row := func(format, text string) {
+		fmt.Fprintf(&b, "<tr><td>"+format+"</td></tr>", text)
+	}
+	row("<b>%s</b>", html.EscapeString(lines[0]))
+	if m := packageModule(pkg.ImportPath); m != nil && !pkg.Goroot {
+		mod := m.Path
+		if v := m.resolvedVersion(); v != "" {
+			mod += "@" + v
+		}
+		row(`<font point-size="10">%s</font>`, html.EscapeString(mod))
+	}
+	if pkg.Doc != "" {
+		row(`<font point-size="10"><i>%s</i></font>`, html.EscapeString(pkg.Doc))
+	}
+	for _, line := range lines[1:] {
+		row(`<font point-size="10">%s</font>`, html.EscapeString(strings.Replace(line, `\"`, `"`, -1)))
+	}
+	var badges []string
+	if pkg.CgoFiles > 0 {
+		badges = append(badges, "cgo")
+	}
+	if isInternal(pkg.ImportPath) || isGorootVendored(pkg) {
+		badges = append(badges, "internal")
+	}
+	if *markGenerated {
+		if gen, total := generatedFiles(pkg); gen*2 > total {
+			badges = append(badges, "generated")
+		}
+	}
+	if len(badges) > 0 {
+		row(`<font point-size="9" color="gray30">[%s]</font>`, strings.Join(badges, "] ["))
+	}
+	b.WriteString("</table>>")
+	return b.String()
+}
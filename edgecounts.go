@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An edgeCount counts the files of a package behind one of its imports, for
+// -edge-counts.
+type edgeCount struct {
+	files, tests, blank, dot int
+}
+
+// edgeCounts returns the counts of the files of pkg importing each of its
+// imports, by the import path the graph resolves it to.
+func edgeCounts(pkg *node) map[string]*edgeCount {
+	counts := make(map[string]*edgeCount)
+	for _, f := range packageFiles(pkg) {
+		for _, imp := range f.Imports {
+			path := resolvedImport(pkg.Dir, imp.Path)
+			c := counts[path]
+			if c == nil {
+				c = &edgeCount{}
+				counts[path] = c
+			}
+			if f.Test {
+				c.tests++
+			} else {
+				c.files++
+			}
+			switch imp.Name {
+			case "_":
+				c.blank++
+			case ".":
+				c.dot++
+			}
+		}
+	}
+	return counts
+}
+
+// String describes c as an edge label, such as "3 files, 1 test".
+func (c *edgeCount) String() string {
+	var parts []string
+	add := func(n int, one, many string) {
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+one)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, many))
+		}
+	}
+	add(c.files, "file", "files")
+	add(c.tests, "test", "tests")
+	add(c.blank, "blank", "blank")
+	add(c.dot, "dot import", "dot imports")
+	return strings.Join(parts, ", ")
+}
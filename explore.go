@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// An explorer browses a graph as a tree of the imports, or importers, of a
+// current package, in which packages can be expanded and collapsed.
+type explorer struct {
+	graph     *jsonGraph
+	imports   map[string][]string
+	importers map[string][]string
+
+	current   string
+	history   []string
+	reverse   bool
+	expanded  map[string]bool
+	lines     []treeLine
+	found     []string
+	searching bool
+}
+
+// A treeLine is a package shown in the tree, at depth below the current
+// package, reached from the package of the line parent.
+type treeLine struct {
+	name   string
+	depth  int
+	parent int
+}
+
+// runExplore implements the explore subcommand: it computes the graph of the
+// other flags and arguments and browses it with commands read from stdin.
+func runExplore(cwd string) {
+	g, err := runGraph(cwd, rerunArgs("-format=json", "-o=", "-watch=false", "-compare-ref=", "-check="))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(g.Packages) == 0 {
+		log.Fatal("the graph is empty")
+	}
+	e := newExplorer(g)
+	e.current = graphRoot(g)
+	e.expanded[e.current] = true
+	e.explore(os.Stdin, os.Stdout)
+}
+
+// graphRoot returns the first package of g that no other package imports,
+// or the first package if there is none.
+func graphRoot(g *jsonGraph) string {
+	imported := make(map[string]bool)
+	for _, e := range g.Edges {
+		if e.Kind == "" {
+			imported[e.To] = true
+		}
+	}
+	for _, p := range g.Packages {
+		if !imported[p.ImportPath] {
+			return p.ImportPath
+		}
+	}
+	return g.Packages[0].ImportPath
+}
+
+func newExplorer(g *jsonGraph) *explorer {
+	e := &explorer{graph: g, expanded: make(map[string]bool)}
+	e.imports, e.importers = g.Adjacency()
+	return e
+}
+
+const exploreHelp = `commands:
+  N        make package N the current one
+  +N, -N   expand or collapse package N
+  r        switch between imports and importers
+  b        go back to the previous package
+  /text    list the packages containing text, to pick by number
+  export f write the packages and edges shown to the dot file f
+  q        quit
+`
+
+// explore reads commands from r until it ends or q, writing the tree after
+// each to w.
+func (e *explorer) explore(r io.Reader, w io.Writer) {
+	s := bufio.NewScanner(r)
+	e.show(w)
+	for {
+		fmt.Fprint(w, "> ")
+		if !s.Scan() {
+			fmt.Fprintln(w)
+			return
+		}
+		cmd := strings.TrimSpace(s.Text())
+		switch {
+		case cmd == "":
+			continue
+		case cmd == "q":
+			return
+		case cmd == "?" || cmd == "h":
+			fmt.Fprint(w, exploreHelp)
+			continue
+		case cmd == "r":
+			e.reverse = !e.reverse
+		case cmd == "b":
+			if len(e.history) == 0 {
+				fmt.Fprintln(w, "no previous package")
+				continue
+			}
+			e.current = e.history[len(e.history)-1]
+			e.history = e.history[:len(e.history)-1]
+		case strings.HasPrefix(cmd, "/"):
+			e.search(w, cmd[1:])
+			continue
+		case strings.HasPrefix(cmd, "export "):
+			path := strings.TrimSpace(strings.TrimPrefix(cmd, "export "))
+			if err := e.export(path); err != nil {
+				fmt.Fprintf(w, "failed to export: %s\n", err)
+			} else {
+				fmt.Fprintf(w, "wrote %s\n", path)
+			}
+			continue
+		case cmd[0] == '+' || cmd[0] == '-':
+			name, ok := e.pick(w, cmd[1:])
+			if !ok {
+				continue
+			}
+			e.expanded[name] = cmd[0] == '+'
+		default:
+			name, ok := e.pick(w, cmd)
+			if !ok {
+				continue
+			}
+			if name != e.current {
+				e.history = append(e.history, e.current)
+				e.current = name
+				e.expanded[name] = true
+			}
+		}
+		e.searching = false
+		e.show(w)
+	}
+}
+
+// pick returns the package numbered n in the tree or, after a search, in its
+// results.
+func (e *explorer) pick(w io.Writer, n string) (string, bool) {
+	i, err := strconv.Atoi(strings.TrimSpace(n))
+	if e.searching {
+		if err == nil && i >= 1 && i <= len(e.found) {
+			return e.found[i-1], true
+		}
+	} else if err == nil && i >= 1 && i <= len(e.lines) {
+		return e.lines[i-1].name, true
+	}
+	fmt.Fprintf(w, "no package %s, ? lists the commands\n", n)
+	return "", false
+}
+
+func (e *explorer) next(name string) []string {
+	if e.reverse {
+		return e.importers[name]
+	}
+	return e.imports[name]
+}
+
+// show writes the tree of the current package to w, numbering its lines.
+func (e *explorer) show(w io.Writer) {
+	e.lines = nil
+	var walk func(name string, depth, parent int, path map[string]bool)
+	walk = func(name string, depth, parent int, path map[string]bool) {
+		e.lines = append(e.lines, treeLine{name, depth, parent})
+		if !e.expanded[name] || path[name] {
+			return
+		}
+		path[name] = true
+		self := len(e.lines) - 1
+		for _, n := range e.next(name) {
+			walk(n, depth+1, self, path)
+		}
+		delete(path, name)
+	}
+	walk(e.current, 0, -1, make(map[string]bool))
+
+	what := "imports"
+	if e.reverse {
+		what = "importers"
+	}
+	fmt.Fprintf(w, "%s of %s:\n", what, e.current)
+	for i, l := range e.lines {
+		mark := " "
+		if n := len(e.next(l.name)); n > 0 {
+			mark = "+"
+			if e.expanded[l.name] {
+				mark = "-"
+			}
+		}
+		fmt.Fprintf(w, "%4d %s%s %s (%d)\n", i+1, strings.Repeat("  ", l.depth), mark, l.name, len(e.next(l.name)))
+	}
+}
+
+// search lists the packages whose import path contains text.
+func (e *explorer) search(w io.Writer, text string) {
+	e.found = nil
+	for _, p := range e.graph.Packages {
+		if strings.Contains(p.ImportPath, text) {
+			e.found = append(e.found, p.ImportPath)
+		}
+	}
+	if len(e.found) == 0 {
+		fmt.Fprintln(w, "no packages found")
+		return
+	}
+	e.searching = true
+	for i, name := range e.found {
+		fmt.Fprintf(w, "%4d %s\n", i+1, name)
+	}
+}
+
+// export writes the packages and edges of the tree shown to the file at
+// path in dot format.
+func (e *explorer) export(path string) error {
+	info := make(map[string]jsonPackage)
+	for _, p := range e.graph.Packages {
+		info[p.ImportPath] = p
+	}
+	g := graph.New()
+	seen := make(map[string]bool)
+	edges := make(map[diffEdge]bool)
+	for _, l := range e.lines {
+		if !seen[l.name] {
+			seen[l.name] = true
+			g.Packages = append(g.Packages, info[l.name])
+		}
+		if l.parent < 0 {
+			continue
+		}
+		edge := diffEdge{e.lines[l.parent].name, l.name}
+		if e.reverse {
+			edge = diffEdge{l.name, e.lines[l.parent].name}
+		}
+		if !edges[edge] {
+			edges[edge] = true
+			g.Edges = append(g.Edges, jsonEdge{From: edge.From, To: edge.To})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeGraphDot(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// failures holds, with -k or -missing, the import paths that could not be
+// loaded and why.
+var failures = make(map[string]error)
+
+// failureImporters returns the sorted scanned packages importing path.
+func failureImporters(path string) []string {
+	var importers []string
+	for name, pkg := range pkgs {
+		for _, imp := range getImports(pkg) {
+			if imp == path {
+				importers = append(importers, name)
+				break
+			}
+		}
+	}
+	sort.Strings(importers)
+	return importers
+}
+
+// missingImports returns the imports of pkg that could not be loaded. Like
+// edges, there are none for packages in Goroot unless -d is set.
+func missingImports(pkg *node) []string {
+	if pkg.Goroot && !*delveGoroot {
+		return nil
+	}
+	var missing []string
+	for _, imp := range getImports(pkg) {
+		if failures[imp] != nil {
+			missing = append(missing, imp)
+		}
+	}
+	return missing
+}
+
+// missingAttrs returns the attributes of the red node of the package path
+// that could not be loaded, with the error msg as its tooltip.
+func missingAttrs(path, msg string) attrs {
+	return attrs{
+		{"label", path},
+		{"style", "filled,dashed"},
+		{"color", "red"},
+		{"fillcolor", "mistyrose"},
+		{"tooltip", dotEscape(msg)},
+	}
+}
+
+// reportFailures writes the packages that could not be loaded, and the
+// packages importing them, to w.
+func reportFailures(w io.Writer) {
+	var paths []string
+	for path := range failures {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintf(w, "%d packages could not be loaded:\n", len(paths))
+	for _, path := range paths {
+		fmt.Fprintf(w, "\t%s", path)
+		if importers := failureImporters(path); len(importers) > 0 {
+			fmt.Fprintf(w, " (imported by %s)", strings.Join(importers, ", "))
+		}
+		fmt.Fprintf(w, ": %s\n", failures[path])
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// writeFileGraph writes, instead of the package graph, the graph of the
+// files of each of roots in the -format, each file a node named by the
+// import path of its package and its name, and an edge from every file to
+// each other file of its package declaring package-level identifiers it
+// refers to, which the edge lists. Methods, which are referred to through
+// their receivers, aren't followed, as the files aren't type-checked.
+func writeFileGraph(cwd string, roots []string) {
+	if render.Lookup(*outputFormat) == nil {
+		log.Fatalf("-files needs a format registered with package render, one of %s", strings.Join(render.Formats(), ", "))
+	}
+	g := graph.New()
+	for _, root := range roots {
+		pkg, err := importPackage(loaderPath(root, cwd), cwd)
+		if err != nil {
+			log.Fatalf("failed to import %s: %s", root, err)
+		}
+		if err := addFileGraph(g, root, pkg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	g.Sort()
+
+	out := newOutput(*outputPath)
+	if err := render.Write(out, *outputFormat, g); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputFormat, err)
+	}
+	if err := out.close(); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputPath, err)
+	}
+}
+
+// addFileGraph adds the files of pkg, imported as name, and the references
+// between them to g.
+func addFileGraph(g *graph.Graph, name string, pkg *build.Package) error {
+	names := append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...)
+	if internalTests() {
+		names = append(names, pkg.TestGoFiles...)
+	}
+	sort.Strings(names)
+
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	declaredIn := make(map[string]string)
+	for _, file := range names {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, file), nil, 0)
+		if err != nil {
+			return err
+		}
+		files[file] = f
+		for _, decl := range f.Decls {
+			for _, id := range declaredNames(decl) {
+				if id != "_" && id != "init" {
+					declaredIn[id] = file
+				}
+			}
+		}
+	}
+
+	for _, file := range names {
+		from := path.Join(name, file)
+		g.Packages = append(g.Packages, graph.Package{ImportPath: from, Dir: pkg.Dir, Cgo: fileImportsC(files[file])})
+
+		symbols := make(map[string]map[string]bool)
+		positions := make(map[string][]string)
+		for _, id := range files[file].Unresolved {
+			to := declaredIn[id.Name]
+			if to == "" || to == file {
+				continue
+			}
+			if symbols[to] == nil {
+				symbols[to] = make(map[string]bool)
+			}
+			if !symbols[to][id.Name] {
+				symbols[to][id.Name] = true
+				if *showPositions {
+					positions[to] = append(positions[to], fset.Position(id.Pos()).String())
+				}
+			}
+		}
+		for to, ids := range symbols {
+			e := graph.Edge{From: from, To: path.Join(name, to), Positions: positions[to]}
+			for id := range ids {
+				e.Symbols = append(e.Symbols, id)
+			}
+			sort.Strings(e.Symbols)
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	return nil
+}
+
+// declaredNames returns the names of the package-level identifiers decl
+// declares, methods excluded.
+func declaredNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					names = append(names, id.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// fileImportsC reports whether f is a cgo file.
+func fileImportsC(f *ast.File) bool {
+	for _, spec := range f.Imports {
+		if spec.Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
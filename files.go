@@ -0,0 +1,102 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// goFile holds what godepgraph learns from parsing the header of a Go source
+// file.
+type goFile struct {
+	Name      string
+	Test      bool
+	Generated bool
+	Imports   []fileImport
+}
+
+// fileImport is a single import spec.
+type fileImport struct {
+	Path string
+	Name string // the local name, if the import is renamed
+	Pos  token.Position
+}
+
+var (
+	fileset     = token.NewFileSet()
+	parsedFiles = make(map[string][]*goFile)
+
+	generatedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+)
+
+// packageFiles parses the import declarations of the source files of pkg,
+// including test files when tests are included. Results are cached. Files
+// that fail to parse are logged and skipped.
+func packageFiles(pkg *node) []*goFile {
+	if files, ok := parsedFiles[pkg.ImportPath]; ok {
+		return files
+	}
+	names := pkg.GoFiles
+	nsrc := len(names) - pkg.TestGoFiles
+
+	var files []*goFile
+	for i, name := range names {
+		f, err := parseGoFile(filepath.Join(pkg.Dir, name))
+		if err != nil {
+			log.Printf("failed to parse %s: %s", name, err)
+			continue
+		}
+		f.Test = i >= nsrc
+		files = append(files, f)
+	}
+	parsedFiles[pkg.ImportPath] = files
+	return files
+}
+
+func parseGoFile(path string) (*goFile, error) {
+	af, err := parser.ParseFile(fileset, path, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	f := &goFile{Name: filepath.Base(path)}
+	for _, cg := range af.Comments {
+		if cg.Pos() > af.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if generatedRe.MatchString(c.Text) {
+				f.Generated = true
+			}
+		}
+	}
+	for _, spec := range af.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		imp := fileImport{Path: p, Pos: fileset.Position(spec.Pos())}
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+		f.Imports = append(f.Imports, imp)
+	}
+	return f, nil
+}
+
+// generatedFiles returns the number of generated files among the non-test
+// source files of pkg, and the total number of those files.
+func generatedFiles(pkg *node) (generated, total int) {
+	for _, f := range packageFiles(pkg) {
+		if f.Test {
+			continue
+		}
+		total++
+		if f.Generated {
+			generated++
+		}
+	}
+	return generated, total
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/kisielk/godepgraph/pattern"
+)
+
+// onlyGraph removes the packages that have none of the prefixes and match
+// none of the globs. Unlike ignored packages, the removed ones are still
+// scanned, so the kept packages they lead to remain in the graph.
+func onlyGraph(prefixes []string, globs []*regexp.Regexp) {
+	for name := range pkgs {
+		if !hasPrefixes(name, prefixes) && !matchesAny(name, globs) {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// splitGlobs separates the globs among patterns, compiled, from the plain
+// patterns.
+func splitGlobs(patterns []string) (plain []string, globs []*regexp.Regexp, err error) {
+	for _, p := range patterns {
+		if !pattern.IsGlob(p) {
+			plain = append(plain, p)
+			continue
+		}
+		re, err := pattern.Regexp(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		globs = append(globs, re)
+	}
+	return plain, globs, nil
+}
+
+// focusGraph removes the packages that neither import one of the packages
+// in focus, directly or indirectly, nor are imported by one, leaving the
+// focus packages with their dependencies and dependents.
+func focusGraph(focus []string) {
+	rev := importers()
+	deps := reachable(focus, drawnImports)
+	dependents := reachable(focus, func(name string) []string {
+		return rev[name]
+	})
+	for name := range pkgs {
+		if !deps[name] && !dependents[name] {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// aroundGraph removes the packages more than hops imports away from
+// center, following imports in either direction, leaving the neighborhood
+// of center.
+func aroundGraph(center string, hops int) {
+	rev := importers()
+	dist := map[string]int{center: 0}
+	queue := []string{center}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if dist[name] == hops {
+			continue
+		}
+		for _, next := range append(drawnImports(name), rev[name]...) {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[name] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	for name := range pkgs {
+		if _, ok := dist[name]; !ok {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// fromGraph removes the packages from doesn't reach, leaving its subtree.
+func fromGraph(from string) {
+	deps := reachable([]string{from}, drawnImports)
+	for name := range pkgs {
+		if !deps[name] {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// pruneLeafPackages removes the packages that import no package of the graph,
+// but roots, then those left importing none, passes times.
+func pruneLeafPackages(roots []string, passes int) {
+	for i := 0; i < passes; i++ {
+		var leaves []string
+		for name, pkg := range pkgs {
+			if !isIgnored(pkg) && len(edgeImports(pkg)) == 0 && !containsString(roots, name) {
+				leaves = append(leaves, name)
+			}
+		}
+		if len(leaves) == 0 {
+			return
+		}
+		for _, name := range leaves {
+			delete(pkgs, name)
+		}
+	}
+}
+
+// testOnlyGraph removes the packages the code of roots reaches without
+// going through the imports of tests but those whose tests import the
+// others, leaving the dependencies only the tests bring in and where they
+// come in.
+func testOnlyGraph(roots []string) {
+	code := reachable(roots, func(name string) []string {
+		pkg := pkgs[name]
+		if pkg == nil || pkg.XTest {
+			return nil
+		}
+		var imports []string
+		for _, imp := range drawnImports(name) {
+			if !isTestImport(pkg, imp) {
+				imports = append(imports, imp)
+			}
+		}
+		return imports
+	})
+	entries := make(map[string]bool)
+	for name := range code {
+		for _, imp := range drawnImports(name) {
+			if !code[imp] {
+				entries[name] = true
+			}
+		}
+	}
+	for name := range pkgs {
+		if code[name] && !entries[name] {
+			delete(pkgs, name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// gephiBatch is the most events pushed to Gephi in a request, and
+// gephiInterval the longest they wait to be pushed, so that the graph grows
+// as it is discovered without a request for every package.
+const (
+	gephiBatch    = 200
+	gephiInterval = 500 * time.Millisecond
+)
+
+// gephiWriter pushes the packages and edges of a graph to a workspace of a
+// running Gephi through the HTTP API of its graph streaming plugin, as
+// add-node and add-edge events.
+type gephiWriter struct {
+	url    string
+	buf    bytes.Buffer
+	events int
+	last   time.Time
+}
+
+// newGephiWriter returns a gephiWriter pushing to the workspace at the URL
+// workspace, such as http://localhost:8080/workspace1.
+func newGephiWriter(workspace string) (*gephiWriter, error) {
+	u, err := url.Parse(workspace)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("operation", "updateGraph")
+	u.RawQuery = q.Encode()
+	return &gephiWriter{url: u.String()}, nil
+}
+
+func (g *gephiWriter) Begin() error {
+	g.last = time.Now()
+	return nil
+}
+
+// gephiColors are the colors of the packages in Gephi, those of dot output.
+var gephiColors = map[string][3]float64{
+	"missing": {1, 0.89, 0.88},
+	"tool":    {0.86, 0.86, 0.86},
+	"std":     {0.6, 0.98, 0.6},
+	"cgo":     {1, 0.73, 0.06},
+	"":        {0.69, 0.93, 0.93},
+}
+
+func (g *gephiWriter) Node(p graph.Package) error {
+	kind := ""
+	switch {
+	case p.Missing:
+		kind = "missing"
+	case p.Tool:
+		kind = "tool"
+	case p.Goroot:
+		kind = "std"
+	case p.Cgo:
+		kind = "cgo"
+	}
+	c := gephiColors[kind]
+	return g.event("an", p.ImportPath, map[string]interface{}{
+		"label": p.ImportPath,
+		"r":     c[0],
+		"g":     c[1],
+		"b":     c[2],
+	})
+}
+
+func (g *gephiWriter) Edge(e graph.Edge) error {
+	attrs := map[string]interface{}{
+		"source":   e.From,
+		"target":   e.To,
+		"directed": true,
+	}
+	id := e.From + " -> " + e.To
+	if e.Kind != "" {
+		id += " " + e.Kind
+		attrs["label"] = e.Kind
+	}
+	return g.event("ae", id, attrs)
+}
+
+func (g *gephiWriter) End() error {
+	return g.flush()
+}
+
+// event buffers the event of type typ of the node or edge id with attrs,
+// pushing the buffered events once there are enough of them or they have
+// waited long enough.
+func (g *gephiWriter) event(typ, id string, attrs map[string]interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{typ: map[string]interface{}{id: attrs}})
+	if err != nil {
+		return err
+	}
+	g.buf.Write(b)
+	g.buf.WriteString("\r\n")
+	g.events++
+	if g.events >= gephiBatch || time.Since(g.last) >= gephiInterval {
+		return g.flush()
+	}
+	return nil
+}
+
+// flush pushes the buffered events.
+func (g *gephiWriter) flush() error {
+	g.last = time.Now()
+	if g.events == 0 {
+		return nil
+	}
+	resp, err := http.Post(g.url, "application/json", &g.buf)
+	g.buf.Reset()
+	g.events = 0
+	if err != nil {
+		return fmt.Errorf("gephi: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("gephi: %s", resp.Status)
+	}
+	return nil
+}
+
+// teeWriter gives the graph to each of its writers in turn.
+type teeWriter []render.Writer
+
+func (t teeWriter) Begin() error {
+	for _, w := range t {
+		if err := w.Begin(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeWriter) Node(p graph.Package) error {
+	for _, w := range t {
+		if err := w.Node(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeWriter) Edge(e graph.Edge) error {
+	for _, w := range t {
+		if err := w.Edge(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeWriter) End() error {
+	for _, w := range t {
+		if err := w.End(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
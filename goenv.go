@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// goCmd is the go command run to resolve packages and modules.
+var goCmd = "go"
+
+var goEnvVars = make(map[string]string)
+
+// goEnvVar returns the value of the go environment variable name as the go
+// command sees it, including defaults and settings made with go env -w.
+func goEnvVar(name string) string {
+	if v, ok := goEnvVars[name]; ok {
+		return v
+	}
+	v := os.Getenv(name)
+	cmd := exec.Command(goCmd, "env", name)
+	cmd.Env = goEnv()
+	if out, err := cmd.Output(); err == nil {
+		v = strings.TrimSpace(string(out))
+	}
+	goEnvVars[name] = v
+	return v
+}
+
+// setGoEnv sets the go environment variable name for the rest of the run.
+func setGoEnv(name, value string) {
+	os.Setenv(name, value)
+	goEnvVars[name] = value
+}
+
+// useToolchain makes the go command name, a path or a command on $PATH such
+// as a go1.xx shim from golang.org/dl, and its GOROOT resolve packages for
+// the rest of the run. Release tags follow the toolchain's Go version, and
+// GOTOOLCHAIN=local keeps go.mod toolchain lines from switching to another
+// one.
+func useToolchain(name string) error {
+	bin, err := exec.LookPath(name)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command(bin, "env", "GOROOT", "GOVERSION").Output()
+	if err != nil {
+		return fmt.Errorf("%s env: %s", name, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return fmt.Errorf("%s env: unexpected output %q", name, out)
+	}
+	goroot, version := strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1])
+
+	// go/build runs the go command of its GOROOT, so use that one too rather
+	// than a shim that would start it on every invocation.
+	goCmd = filepath.Join(goroot, "bin", "go")
+	buildContext.GOROOT = goroot
+	setGoEnv("GOROOT", goroot)
+	setGoEnv("GOTOOLCHAIN", "local")
+	if minor, ok := goMinor(version); ok {
+		buildContext.ReleaseTags = releaseTags(minor)
+	}
+	return nil
+}
+
+// goMinor returns the minor version of the Go release version, such as 21
+// for go1.21 or go1.21.3.
+func goMinor(version string) (int, bool) {
+	var minor int
+	if _, err := fmt.Sscanf(version, "go1.%d", &minor); err != nil {
+		return 0, false
+	}
+	return minor, true
+}
+
+// releaseTags returns the release tags of Go 1.minor, go1.1 to go1.minor.
+func releaseTags(minor int) []string {
+	var tags []string
+	for i := 1; i <= minor; i++ {
+		tags = append(tags, fmt.Sprintf("go1.%d", i))
+	}
+	return tags
+}
+
+// useRelease makes the build constraints satisfied by the release tags of
+// the Go release version, go1.N, rather than the toolchain's, to preview
+// the graph under another release before upgrading. The go command only
+// knows its own release tags and is given the newer ones as build tags by
+// goTags, so releases older than its own need -loader build.
+func useRelease(version string) error {
+	minor, ok := goMinor(version)
+	if !ok || version != fmt.Sprintf("go1.%d", minor) {
+		return fmt.Errorf("invalid Go release %q, want go1.N", version)
+	}
+	if own, ok := goMinor(goEnvVar("GOVERSION")); ok && minor < own && *loaderName != "build" {
+		return fmt.Errorf("%s is older than the go command's go1.%d, which -loader %s can't undo; use -loader build", version, own, *loaderName)
+	}
+	buildContext.ReleaseTags = releaseTags(minor)
+	return nil
+}
+
+// useExperiments sets GOEXPERIMENT to the comma-separated list of
+// experiments for the rest of the run, and the goexperiment.name tags of
+// go/build alike: set for the experiments listed, and cleared for those
+// disabled as noname.
+func useExperiments(list string) {
+	setGoEnv("GOEXPERIMENT", list)
+	tags := append([]string(nil), buildContext.ToolTags...)
+	for _, exp := range strings.Split(list, ",") {
+		exp = strings.TrimSpace(exp)
+		if exp == "" {
+			continue
+		}
+		name, on := strings.TrimPrefix(exp, "no"), !strings.HasPrefix(exp, "no")
+		tag := "goexperiment." + name
+		for i := 0; i < len(tags); i++ {
+			if tags[i] == tag {
+				tags = append(tags[:i], tags[i+1:]...)
+				i--
+			}
+		}
+		if on {
+			tags = append(tags, tag)
+		}
+	}
+	buildContext.ToolTags = tags
+}
+
+// goTags returns the build tags to give the go command: those of the build
+// context, and with -release the release tags newer than its own.
+func goTags() []string {
+	tags := buildContext.BuildTags
+	if *goRelease == "" {
+		return tags
+	}
+	own, _ := goMinor(goEnvVar("GOVERSION"))
+	for _, tag := range buildContext.ReleaseTags {
+		if minor, _ := goMinor(tag); minor > own {
+			tags = append(tags[:len(tags):len(tags)], tag)
+		}
+	}
+	return tags
+}
+
+// modFlag returns the value of the -mod flag set in GOFLAGS, or the empty
+// string if it isn't set.
+func modFlag() string {
+	mode := ""
+	for _, f := range strings.Fields(goEnvVar("GOFLAGS")) {
+		f = strings.TrimLeft(f, "-")
+		if strings.HasPrefix(f, "mod=") {
+			mode = strings.TrimPrefix(f, "mod=")
+		}
+	}
+	return mode
+}
+
+// withModFlag returns GOFLAGS with its -mod flag, if any, replaced by
+// -mod=mode, so that the user's other flags still apply to go commands that
+// need a particular module mode.
+func withModFlag(mode string) string {
+	var flags []string
+	for _, f := range strings.Fields(goEnvVar("GOFLAGS")) {
+		if !strings.HasPrefix(strings.TrimLeft(f, "-"), "mod=") {
+			flags = append(flags, f)
+		}
+	}
+	return strings.Join(append(flags, "-mod="+mode), " ")
+}
+
+// isPrivate reports whether the module path matches GOPRIVATE, and is thus
+// fetched directly and not checked against the checksum database.
+func isPrivate(modPath string) bool {
+	return matchGlobs(goEnvVar("GOPRIVATE"), modPath)
+}
+
+// matchGlobs reports whether any of the comma-separated glob patterns in
+// globs matches a prefix of target, the way the go command matches
+// GOPRIVATE, GONOPROXY and GONOSUMDB: each pattern is matched against as
+// many leading path elements of target as it has.
+func matchGlobs(globs, target string) bool {
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		n := strings.Count(glob, "/")
+		prefix := target
+		for i := 0; i < len(target); i++ {
+			if target[i] == '/' {
+				if n == 0 {
+					prefix = target[:i]
+					break
+				}
+				n--
+			}
+		}
+		if n > 0 {
+			// The pattern has more elements than target.
+			continue
+		}
+		if ok, _ := path.Match(glob, prefix); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// modFile is the subset of a go.mod file that godepgraph cares about.
+type modFile struct {
+	Path     string
+	Dir      string
+	Go       string
+	Require  []modRequire
+	Replace  []modReplace
+	Excluded []modRequire
+}
+
+type modRequire struct {
+	Path     string
+	Version  string
+	Indirect bool
+}
+
+type modReplace struct {
+	Old, OldVersion string
+	New, NewVersion string
+}
+
+// findGoMod walks up from dir looking for a go.mod file and returns its path,
+// or the empty string if none is found.
+func findGoMod(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		p := filepath.Join(dir, "go.mod")
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func readGoMod(path string) (*modFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mf, err := parseGoMod(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	mf.Dir = filepath.Dir(path)
+	return mf, nil
+}
+
+// parseGoMod parses the module, go, require, replace and exclude directives
+// of a go.mod file. Other directives are accepted and ignored.
+func parseGoMod(r io.Reader) (*modFile, error) {
+	mf := &modFile{}
+	var block string
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		indirect := false
+		if i := strings.Index(line, "//"); i >= 0 {
+			indirect = strings.TrimSpace(line[i+2:]) == "indirect"
+			line = line[:i]
+		}
+		fields, err := modFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		verb := block
+		if block == "" {
+			verb, fields = fields[0], fields[1:]
+			if len(fields) == 1 && fields[0] == "(" {
+				block = verb
+				continue
+			}
+		} else if fields[0] == ")" {
+			block = ""
+			continue
+		}
+
+		switch verb {
+		case "module":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("line %d: malformed module directive", lineno)
+			}
+			mf.Path = fields[0]
+		case "go":
+			if len(fields) == 1 {
+				mf.Go = fields[0]
+			}
+		case "require", "exclude":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed %s directive", lineno, verb)
+			}
+			req := modRequire{Path: fields[0], Version: fields[1], Indirect: indirect}
+			if verb == "require" {
+				mf.Require = append(mf.Require, req)
+			} else {
+				mf.Excluded = append(mf.Excluded, req)
+			}
+		case "replace":
+			rep, err := parseReplace(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineno, err)
+			}
+			mf.Replace = append(mf.Replace, rep)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if mf.Path == "" {
+		return nil, fmt.Errorf("no module directive")
+	}
+	return mf, nil
+}
+
+func parseReplace(fields []string) (modReplace, error) {
+	arrow := -1
+	for i, f := range fields {
+		if f == "=>" {
+			arrow = i
+		}
+	}
+	lhs, rhs := fields, []string(nil)
+	if arrow >= 0 {
+		lhs, rhs = fields[:arrow], fields[arrow+1:]
+	}
+	if len(lhs) < 1 || len(lhs) > 2 || len(rhs) < 1 || len(rhs) > 2 {
+		return modReplace{}, fmt.Errorf("malformed replace directive")
+	}
+	rep := modReplace{Old: lhs[0], New: rhs[0]}
+	if len(lhs) == 2 {
+		rep.OldVersion = lhs[1]
+	}
+	if len(rhs) == 2 {
+		rep.NewVersion = rhs[1]
+	}
+	return rep, nil
+}
+
+// modFields splits a go.mod line into fields, unquoting quoted strings.
+func modFields(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		if line == "" {
+			return fields, nil
+		}
+		if line[0] == '"' || line[0] == '`' {
+			end := strings.IndexByte(line[1:], line[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			f, err := strconv.Unquote(line[:end+2])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t\r")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
+
+// moduleFor returns the path of the module in mods that provides importPath,
+// using the longest matching module path, or the empty string.
+func moduleFor(importPath string, mods []string) string {
+	best := ""
+	for _, m := range mods {
+		if len(m) > len(best) && (importPath == m || strings.HasPrefix(importPath, m+"/")) {
+			best = m
+		}
+	}
+	return best
+}
+
+// checkGoMod compares the modules reached by the scanned packages with the
+// requirements listed in mf and writes the discrepancies to w. Packages of
+// the modules in local, such as the other modules of a workspace, are never
+// reported as missing.
+func checkGoMod(w io.Writer, mf *modFile, local []string) {
+	required := make([]string, 0, len(mf.Require)+1+len(local))
+	for _, req := range mf.Require {
+		required = append(required, req.Path)
+	}
+	required = append(required, mf.Path)
+	required = append(required, local...)
+
+	reached := make(map[string]bool)
+	missing := make(map[string][]string)
+	for _, pkg := range pkgs {
+		if pkg.Goroot {
+			continue
+		}
+		paths := append([]string{pkg.ImportPath}, getImports(pkg)...)
+		for _, imp := range paths {
+			if isGorootPath(imp) || imp == "C" || ignored[imp] {
+				continue
+			}
+			if mod := moduleFor(imp, required); mod != "" {
+				reached[mod] = true
+			} else if imp != pkg.ImportPath {
+				missing[imp] = append(missing[imp], pkg.ImportPath)
+			}
+		}
+	}
+
+	var unused []string
+	for _, req := range mf.Require {
+		if reached[req.Path] {
+			continue
+		}
+		s := req.Path + " " + req.Version
+		if req.Indirect {
+			s += " // indirect"
+		}
+		unused = append(unused, s)
+	}
+	sort.Strings(unused)
+
+	missingKeys := []string{}
+	for k := range missing {
+		missingKeys = append(missingKeys, k)
+	}
+	sort.Strings(missingKeys)
+
+	fmt.Fprintf(w, "go.mod: %s\n", filepath.Join(mf.Dir, "go.mod"))
+	if len(unused) == 0 && len(missingKeys) == 0 {
+		fmt.Fprintln(w, "no discrepancies found")
+		return
+	}
+	if len(unused) > 0 {
+		fmt.Fprintln(w, "required but never reached:")
+		for _, s := range unused {
+			fmt.Fprintf(w, "\t%s\n", s)
+		}
+	}
+	if len(missingKeys) > 0 {
+		fmt.Fprintln(w, "imported but not provided by any required module:")
+		for _, imp := range missingKeys {
+			importers := missing[imp]
+			sort.Strings(importers)
+			fmt.Fprintf(w, "\t%s (imported by %s)\n", imp, strings.Join(importers, ", "))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A sumEntry is a module version with hashes in go.sum files.
+type sumEntry struct {
+	Path, Version string
+}
+
+// readGoSums reads the go.sum files paths, skipping those that don't exist,
+// and returns the hashes of the contents of the module versions in them and
+// whether each module version has its go.mod hash.
+func readGoSums(paths []string) (zips map[sumEntry][]string, mods map[sumEntry]bool, err error) {
+	zips = make(map[sumEntry][]string)
+	mods = make(map[sumEntry]bool)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		s := bufio.NewScanner(f)
+		for lineno := 1; s.Scan(); lineno++ {
+			fields := strings.Fields(s.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) != 3 {
+				f.Close()
+				return nil, nil, fmt.Errorf("%s:%d: malformed line", path, lineno)
+			}
+			if v := strings.TrimSuffix(fields[1], "/go.mod"); v != fields[1] {
+				mods[sumEntry{fields[0], v}] = true
+				continue
+			}
+			e := sumEntry{fields[0], fields[1]}
+			if !containsString(zips[e], fields[2]) {
+				zips[e] = append(zips[e], fields[2])
+			}
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return zips, mods, nil
+}
+
+// goSumFiles returns the go.sum files of the main module of the go.mod file
+// gomod, or in a workspace those of its modules and its go.work.sum.
+func goSumFiles(gomod string) []string {
+	if workspace == nil {
+		return []string{filepath.Join(filepath.Dir(gomod), "go.sum")}
+	}
+	var paths []string
+	for _, mf := range workspace.modFiles() {
+		paths = append(paths, filepath.Join(mf.Dir, "go.sum"))
+	}
+	return append(paths, workspace.Path+".sum")
+}
+
+// checkGoSum compares the module versions providing the packages of pkgKeys
+// with the hashes of the go.sum files sums and writes the discrepancies to
+// w: the module versions reached without a hash of their contents, those
+// with contents hashed that the graph doesn't reach, and those with
+// conflicting hashes. The main modules and the modules replaced by
+// directories have no hashes and are left out.
+func checkGoSum(w io.Writer, sums []string, pkgKeys []string) error {
+	zips, mods, err := readGoSums(sums)
+	if err != nil {
+		return err
+	}
+
+	reached := make(map[sumEntry][]string)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		m := packageModule(name)
+		if isIgnored(pkg) || pkg.Goroot || m == nil || m.Main {
+			continue
+		}
+		e := sumEntry{m.Path, m.Version}
+		if m.Replace != nil {
+			e = sumEntry{m.Replace.Path, m.Replace.Version}
+		}
+		if e.Version != "" {
+			reached[e] = append(reached[e], name)
+		}
+	}
+
+	var missingSums, unreached, conflicting []string
+	for e, names := range reached {
+		if len(zips[e]) == 0 {
+			s := e.Path + " " + e.Version + " (providing " + strings.Join(names, ", ") + ")"
+			if !mods[e] {
+				s += ", nor its go.mod"
+			}
+			missingSums = append(missingSums, s)
+		}
+	}
+	for e, hashes := range zips {
+		if _, ok := reached[e]; !ok {
+			unreached = append(unreached, e.Path+" "+e.Version)
+		}
+		if len(hashes) > 1 {
+			conflicting = append(conflicting, e.Path+" "+e.Version+" "+strings.Join(hashes, " "))
+		}
+	}
+	sort.Strings(missingSums)
+	sort.Strings(unreached)
+	sort.Strings(conflicting)
+
+	fmt.Fprintf(w, "go.sum: %s\n", strings.Join(sums, ", "))
+	if len(missingSums) == 0 && len(unreached) == 0 && len(conflicting) == 0 {
+		fmt.Fprintln(w, "no discrepancies found")
+		return nil
+	}
+	if len(missingSums) > 0 {
+		fmt.Fprintln(w, "reached but missing from go.sum:")
+		for _, s := range missingSums {
+			fmt.Fprintf(w, "\t%s\n", s)
+		}
+	}
+	if len(unreached) > 0 {
+		fmt.Fprintln(w, "in go.sum but never reached:")
+		for _, s := range unreached {
+			fmt.Fprintf(w, "\t%s\n", s)
+		}
+	}
+	if len(conflicting) > 0 {
+		fmt.Fprintln(w, "conflicting hashes:")
+		for _, s := range conflicting {
+			fmt.Fprintf(w, "\t%s\n", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package graph is the model of the dependency graphs drawn by godepgraph,
+// with a loader building them from source through go/build. Walk, on which
+// Load and the command are built, reports the packages and imports it
+// discovers as it goes instead, for analyses that don't need the whole
+// graph.
+//
+// A Graph is what godepgraph -format json writes, so graphs saved by the
+// command can be read back with Read. The encoding is described by the JSON
+// Schema in schema.json, for version SchemaVersion. Within a version fields
+// are only ever added; renaming, removing or changing the meaning of one
+// increments it.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON encoding of graphs written by
+// this package.
+const SchemaVersion = 1
+
+// A Graph is a set of packages and the imports between them.
+type Graph struct {
+	// SchemaVersion is the version of the encoding of the graph. Graphs
+	// written before it was recorded have none, and are of version 1.
+	SchemaVersion int `json:",omitempty"`
+	Packages      []Package
+	Edges         []Edge
+}
+
+// New returns an empty graph of the current SchemaVersion.
+func New() *Graph {
+	return &Graph{SchemaVersion: SchemaVersion, Packages: []Package{}, Edges: []Edge{}}
+}
+
+// A Package is a node of a Graph.
+type Package struct {
+	ImportPath string
+	Dir        string `json:",omitempty"`
+	// Doc is the synopsis of the package comment, in the graphs recording
+	// it.
+	Doc string `json:",omitempty"`
+	// Goroot is set for packages of the standard library.
+	Goroot bool `json:",omitempty"`
+	// Cgo is set for packages with cgo files.
+	Cgo bool `json:",omitempty"`
+	// Module, Version and Replace describe the module of the package, in
+	// module mode.
+	Module  string `json:",omitempty"`
+	Version string `json:",omitempty"`
+	Replace string `json:",omitempty"`
+	// Private is set for packages of modules matching GOPRIVATE.
+	Private bool `json:",omitempty"`
+	// Origin is where the go command resolves the module of the package
+	// from: the URL of a proxy, direct, replace or vendor, and Unverified
+	// is set if it isn't checked against the checksum database, in the
+	// graphs recording them.
+	Origin     string `json:",omitempty"`
+	Unverified bool   `json:",omitempty"`
+	// Tool is set for the developer tooling, the packages only reached
+	// through the imports of tools.go files, in the graphs marking it.
+	Tool bool `json:",omitempty"`
+	// Owners are the owners a CODEOWNERS file assigns the package, in the
+	// graphs recording them.
+	Owners []string `json:",omitempty"`
+	// Churn is the number of commits changing the package in the period
+	// of the graphs recording it.
+	Churn int `json:",omitempty"`
+	// CompileTime is the time the package took to compile in a fresh
+	// build, in the graphs recording it.
+	CompileTime time.Duration `json:",omitempty"`
+	// CodeSize is the number of bytes of machine code the package adds to
+	// an executable, in the graphs recording it.
+	CodeSize int64 `json:",omitempty"`
+	// Embeds are the file patterns of the //go:embed directives of the
+	// package, in the graphs recording them.
+	Embeds []string `json:",omitempty"`
+	// Annotations are the attributes, such as owner, status, severity and
+	// notes, an annotations file gives the package, in the graphs recording
+	// them.
+	Annotations map[string]string `json:",omitempty"`
+	// Missing is set for imports that couldn't be loaded, because of Error.
+	Missing bool   `json:",omitempty"`
+	Error   string `json:",omitempty"`
+}
+
+// An Edge is the import of To by From.
+type Edge struct {
+	From string
+	To   string
+	// Positions lists the import specs behind the edge as file:line:column.
+	Positions []string `json:",omitempty"`
+	// Test is set for imports only the tests of From make, in graphs of
+	// tests.
+	Test bool `json:",omitempty"`
+	// Denied is why the import is forbidden by the policy of the graph, if
+	// it is.
+	Denied string `json:",omitempty"`
+	// Symbols are the identifiers of To that From refers to, sorted, in
+	// the graphs recording them. In type graphs they are the fields and
+	// methods of From through which it refers to To, and in implements
+	// edges the types of From implementing interfaces of To.
+	Symbols []string `json:",omitempty"`
+	// Kind is empty for imports, and "implements" for the edges from a
+	// package to one defining interfaces its types implement, which need
+	// not be an import.
+	Kind string `json:",omitempty"`
+}
+
+// Read reads a graph encoded as JSON from r. It fails for graphs of a
+// SchemaVersion newer than this package knows, whose fields may have changed
+// meaning.
+func Read(r io.Reader) (*Graph, error) {
+	var g Graph
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	if g.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("graph of schema version %d, newer than the supported version %d", g.SchemaVersion, SchemaVersion)
+	}
+	if g.SchemaVersion == 0 {
+		g.SchemaVersion = 1
+	}
+	return &g, nil
+}
+
+// Adjacency returns the sorted imports and importers of each package of g,
+// leaving out the edges of other Kinds.
+func (g *Graph) Adjacency() (imports, importers map[string][]string) {
+	imports = make(map[string][]string)
+	importers = make(map[string][]string)
+	for _, e := range g.Edges {
+		if e.Kind != "" {
+			continue
+		}
+		imports[e.From] = append(imports[e.From], e.To)
+		importers[e.To] = append(importers[e.To], e.From)
+	}
+	for _, m := range []map[string][]string{imports, importers} {
+		for _, names := range m {
+			sort.Strings(names)
+		}
+	}
+	return imports, importers
+}
+
+// Sort sorts the packages of g by import path and its edges by the import
+// paths of their ends.
+func (g *Graph) Sort() {
+	sort.Slice(g.Packages, func(i, j int) bool {
+		return g.Packages[i].ImportPath < g.Packages[j].ImportPath
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		ei, ej := g.Edges[i], g.Edges[j]
+		if ei.From != ej.From {
+			return ei.From < ej.From
+		}
+		return ei.To < ej.To
+	})
+}
@@ -0,0 +1,343 @@
+package graph
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options control how Walk follows the imports of its roots.
+type Options struct {
+	// Context resolves packages; nil means build.Default.
+	Context *build.Context
+	// Import loads the package path as imported from the directory srcDir,
+	// for programs loading packages their own way, as godepgraph does with
+	// -loader and -cache; nil imports it with Context. It is called from the
+	// Jobs goroutines loading packages, and the other functions of Options
+	// and those of the Visitor only from the goroutine of Walk.
+	Import func(path, srcDir string) (*build.Package, error)
+	// Resolve returns the import path the import imp of the package in the
+	// directory dir names, such as that of a vendored copy or the one of
+	// the directory of a relative import; nil finds it with Context.
+	Resolve func(dir, imp string) string
+	// Jobs is the number of packages loaded at once; below 1 means 1.
+	Jobs int
+	// IgnoreStdlib leaves the packages of the standard library out of the
+	// graph, and DelveGoroot follows their imports.
+	IgnoreStdlib bool
+	DelveGoroot  bool
+	// IncludeTests follows the imports of test files too.
+	IncludeTests bool
+	// Ignore reports whether to leave the package out of the graph, along
+	// with whatever only it imports. It may be nil.
+	Ignore func(importPath string) bool
+	// KeepGoing reports packages that fail to load to the Missing function
+	// of the Visitor instead of failing.
+	KeepGoing bool
+}
+
+// A Visitor receives the packages and imports discovered by Walk. Any of
+// its functions may be nil, and an error returned by one stops the walk.
+type Visitor struct {
+	// Package is called once for every package of the graph, as it is
+	// loaded.
+	Package func(pkg *build.Package) error
+	// Imports returns the resolved import paths to follow from pkg, after
+	// Package is called with it, for programs choosing them themselves, as
+	// godepgraph does for -t and -tools; nil follows its imports, and those
+	// of its tests with IncludeTests, resolved from its directory.
+	Imports func(pkg *build.Package) []string
+	// Import is called for every import by from of a package of the graph,
+	// once that package has been visited, or has failed to load.
+	Import func(from *build.Package, to string) error
+	// Missing is called, with KeepGoing, once for every package that
+	// failed to load.
+	Missing func(path string, err error) error
+}
+
+// A walker holds the state of Walk: only the outcome of every resolved
+// import path, not the packages, and the path every import resolved to.
+// waiting holds the packages importing those still being loaded.
+type walker struct {
+	opts     Options
+	ctxt     *build.Context
+	v        Visitor
+	dir      string
+	resolved map[[2]string]string
+	queued   map[string]bool
+	visited  map[string]bool
+	skipped  map[string]bool
+	failed   map[string]bool
+	waiting  map[string][]*build.Package
+	queue    []string
+}
+
+// Walk visits the packages imported, directly or not, by the roots, and
+// their imports. Relative roots are resolved from dir, and the others are
+// walked as given; the packages are loaded by their resolved import paths,
+// from dir, Jobs at a time.
+func Walk(dir string, roots []string, opts Options, v Visitor) error {
+	w := &walker{
+		opts:     opts,
+		ctxt:     opts.Context,
+		v:        v,
+		dir:      dir,
+		resolved: make(map[[2]string]string),
+		queued:   make(map[string]bool),
+		visited:  make(map[string]bool),
+		skipped:  make(map[string]bool),
+		failed:   make(map[string]bool),
+		waiting:  make(map[string][]*build.Package),
+	}
+	if w.ctxt == nil {
+		w.ctxt = &build.Default
+	}
+	for _, root := range roots {
+		if build.IsLocalImport(root) {
+			root = w.resolve(dir, root)
+		}
+		w.add(root)
+	}
+
+	type loaded struct {
+		path string
+		pkg  *build.Package
+		err  error
+	}
+	workers := opts.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan string)
+	// A worker holds one result at most, so none is left blocked when the
+	// walk stops early.
+	results := make(chan loaded, workers)
+	defer close(jobs)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for path := range jobs {
+				pkg, err := w.load(path)
+				results <- loaded{path, pkg, err}
+			}
+		}()
+	}
+	running := 0
+	for running > 0 || len(w.queue) > 0 {
+		// A nil channel disables the case while there is nothing to start.
+		var send chan string
+		var next string
+		if len(w.queue) > 0 {
+			send, next = jobs, w.queue[0]
+		}
+		select {
+		case send <- next:
+			w.queue = w.queue[1:]
+			running++
+		case r := <-results:
+			running--
+			if err := w.visit(r.path, r.pkg, r.err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Load returns the graph of the packages imported, directly or not, by the
+// roots, as Walk finds them.
+func Load(dir string, roots []string, opts Options) (*Graph, error) {
+	g := New()
+	err := Walk(dir, roots, opts, Visitor{
+		Package: func(pkg *build.Package) error {
+			g.Packages = append(g.Packages, Package{
+				ImportPath: pkg.ImportPath,
+				Dir:        pkg.Dir,
+				Goroot:     pkg.Goroot,
+				Cgo:        len(pkg.CgoFiles) > 0,
+			})
+			return nil
+		},
+		Import: func(from *build.Package, to string) error {
+			g.Edges = append(g.Edges, Edge{From: from.ImportPath, To: to})
+			return nil
+		},
+		Missing: func(path string, err error) error {
+			g.Packages = append(g.Packages, Package{ImportPath: path, Missing: true, Error: strings.TrimSpace(err.Error())})
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.Sort()
+	return g, nil
+}
+
+// add queues the resolved import path to be loaded, unless it already was
+// or is left out.
+func (w *walker) add(path string) {
+	if w.queued[path] || w.skipped[path] {
+		return
+	}
+	if path == "C" || (w.opts.Ignore != nil && w.opts.Ignore(path)) || (w.opts.IgnoreStdlib && InGoroot(w.ctxt, path)) {
+		w.skipped[path] = true
+		return
+	}
+	w.queued[path] = true
+	w.queue = append(w.queue, path)
+}
+
+// visit reports the package of the resolved import path, as it loaded, to
+// the Visitor, with the imports waiting for it, and queues its own.
+func (w *walker) visit(path string, pkg *build.Package, err error) error {
+	if err != nil {
+		if !w.opts.KeepGoing {
+			return fmt.Errorf("failed to import %s: %s", path, err)
+		}
+		w.failed[path] = true
+		if w.v.Missing != nil {
+			if err := w.v.Missing(path, err); err != nil {
+				return err
+			}
+		}
+		return w.imported(path)
+	}
+	if build.IsLocalImport(pkg.ImportPath) {
+		pkg.ImportPath = path
+	}
+	if pkg.ImportPath != path && w.visited[pkg.ImportPath] {
+		// Another import path resolved to the same package.
+		w.visited[path] = true
+		return w.imported(path)
+	}
+	if (w.opts.Ignore != nil && w.opts.Ignore(pkg.ImportPath)) || (pkg.Goroot && w.opts.IgnoreStdlib) {
+		w.skipped[path] = true
+		delete(w.waiting, path)
+		return nil
+	}
+	w.visited[path] = true
+	w.visited[pkg.ImportPath] = true
+	if w.v.Package != nil {
+		if err := w.v.Package(pkg); err != nil {
+			return err
+		}
+	}
+	if err := w.imported(path); err != nil {
+		return err
+	}
+	if pkg.Goroot && !w.opts.DelveGoroot {
+		return nil
+	}
+	var imports []string
+	if w.v.Imports != nil {
+		imports = w.v.Imports(pkg)
+	} else {
+		imports = w.imports(pkg)
+	}
+	for _, imp := range imports {
+		if imp == pkg.ImportPath {
+			continue
+		}
+		w.add(imp)
+		switch {
+		case w.visited[imp] || w.failed[imp]:
+			if w.v.Import != nil {
+				if err := w.v.Import(pkg, imp); err != nil {
+					return err
+				}
+			}
+		case !w.skipped[imp]:
+			w.waiting[imp] = append(w.waiting[imp], pkg)
+		}
+	}
+	return nil
+}
+
+// imported reports the imports of the resolved import path that were
+// waiting for it to be visited or to fail.
+func (w *walker) imported(path string) error {
+	from := w.waiting[path]
+	delete(w.waiting, path)
+	if w.v.Import == nil {
+		return nil
+	}
+	for _, pkg := range from {
+		if err := w.v.Import(pkg, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load loads the package of the resolved import path from the directory of
+// the walk.
+func (w *walker) load(path string) (*build.Package, error) {
+	if w.opts.Import != nil {
+		return w.opts.Import(path, w.dir)
+	}
+	if strings.HasPrefix(path, "_/") {
+		// A package outside GOROOT and GOPATH, named by its directory.
+		return w.ctxt.ImportDir(filepath.FromSlash(path[1:]), 0)
+	}
+	return w.ctxt.Import(path, w.dir, 0)
+}
+
+// resolve returns the import path the import imp of the package in dir
+// resolves to, remembering it for the other packages of dir.
+func (w *walker) resolve(dir, imp string) string {
+	if imp == "C" {
+		return imp
+	}
+	key := [2]string{dir, imp}
+	if path, ok := w.resolved[key]; ok {
+		return path
+	}
+	path := imp
+	if w.opts.Resolve != nil {
+		path = w.opts.Resolve(dir, imp)
+	} else if pkg, err := w.ctxt.Import(imp, dir, build.FindOnly); err == nil {
+		path = pkg.ImportPath
+		if build.IsLocalImport(path) {
+			path = "_" + filepath.ToSlash(pkg.Dir)
+		}
+	}
+	w.resolved[key] = path
+	return path
+}
+
+// InGoroot reports whether path names a package of the standard library of
+// ctxt, by finding its directory in GOROOT rather than importing it, so that
+// the standard library can be left out without loading it.
+func InGoroot(ctxt *build.Context, path string) bool {
+	first := path
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		first = path[:i]
+	}
+	if strings.Contains(first, ".") || path == "C" || build.IsLocalImport(path) {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(ctxt.GOROOT, "src", filepath.FromSlash(path)))
+	return err == nil && fi.IsDir()
+}
+
+// imports returns the distinct imports of pkg, but itself, resolved from
+// its directory.
+func (w *walker) imports(pkg *build.Package) []string {
+	all := pkg.Imports
+	if w.opts.IncludeTests {
+		all = append(append(append([]string{}, all...), pkg.TestImports...), pkg.XTestImports...)
+	}
+	var imports []string
+	found := make(map[string]bool)
+	for _, imp := range all {
+		imp = w.resolve(pkg.Dir, imp)
+		if imp == pkg.ImportPath || found[imp] {
+			continue
+		}
+		found[imp] = true
+		imports = append(imports, imp)
+	}
+	return imports
+}
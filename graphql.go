@@ -0,0 +1,720 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// The serve subcommand answers GraphQL queries at /graphql, against this
+// schema:
+const graphqlSchema = `type Query {
+	nodes(hideStdlib: Boolean = false): [Package!]!
+	node(importPath: String!): Package
+	edges: [Edge!]!
+	paths(from: String!, to: String!, limit: Int = 10): [[String!]!]!
+	reverseDeps(importPath: String!, transitive: Boolean = false): [Package!]!
+}
+
+type Package {
+	importPath: String!
+	dir: String
+	goroot: Boolean!
+	cgo: Boolean!
+	module: String
+	version: String
+	replace: String
+	private: Boolean!
+	missing: Boolean!
+	error: String
+	imports: [Package!]!
+	importers: [Package!]!
+}
+
+type Edge {
+	from: Package!
+	to: Package!
+	denied: String
+	positions: [String!]!
+	symbols: [String!]!
+	kind: String
+}
+`
+
+// Only queries are supported, made of fields with aliases, arguments,
+// variables and nested selections; fragments and directives aren't.
+
+// A gqlField is a field of a selection set.
+type gqlField struct {
+	alias, name string
+	args        map[string]interface{}
+	selection   []gqlField
+}
+
+// A gqlVariable is a reference to a variable in an argument.
+type gqlVariable string
+
+type gqlParser struct {
+	src  string
+	pos  int
+	vars map[string]interface{}
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace, commas and comments, which GraphQL ignores.
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ',' || unicode.IsSpace(rune(c)):
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() byte {
+	p.skip()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *gqlParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || unicode.IsLetter(rune(c)) || (p.pos > start && unicode.IsDigit(rune(c))) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a name")
+	}
+	return p.src[start:p.pos], nil
+}
+
+// document parses a query: an optional operation type, name and variable
+// definitions, which are only checked for syntax, and a selection set.
+func (p *gqlParser) document() ([]gqlField, error) {
+	if p.peek() != '{' {
+		op, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if op != "query" {
+			return nil, p.errorf("only queries are supported, not %s", op)
+		}
+		if c := p.peek(); c != '{' && c != '(' {
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek() == '(' {
+			if err := p.variableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, p.errorf("unexpected text after the query")
+	}
+	return sel, nil
+}
+
+func (p *gqlParser) variableDefinitions() error {
+	p.pos++
+	for p.peek() != ')' {
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.pos++
+			v, err := p.value()
+			if err != nil {
+				return err
+			}
+			if _, ok := p.vars[name]; !ok {
+				p.vars[name] = v
+			}
+		}
+	}
+	p.pos++
+	return nil
+}
+
+func (p *gqlParser) typeRef() error {
+	if p.peek() == '[' {
+		p.pos++
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peek() == '!' {
+		p.pos++
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var fields []gqlField
+	for p.peek() != '}' {
+		if p.peek() == 0 {
+			return nil, p.errorf("unterminated selection set")
+		}
+		f, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.pos++
+	return fields, nil
+}
+
+func (p *gqlParser) field() (gqlField, error) {
+	var f gqlField
+	name, err := p.name()
+	if err != nil {
+		return f, err
+	}
+	f.alias, f.name = name, name
+	if p.peek() == ':' {
+		p.pos++
+		if f.name, err = p.name(); err != nil {
+			return f, err
+		}
+	}
+	if p.peek() == '(' {
+		p.pos++
+		f.args = make(map[string]interface{})
+		for p.peek() != ')' {
+			arg, err := p.name()
+			if err != nil {
+				return f, err
+			}
+			if err := p.expect(':'); err != nil {
+				return f, err
+			}
+			if f.args[arg], err = p.value(); err != nil {
+				return f, err
+			}
+		}
+		p.pos++
+	}
+	if p.peek() == '{' {
+		if f.selection, err = p.selectionSet(); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '$':
+		p.pos++
+		name, err := p.name()
+		return gqlVariable(name), err
+	case c == '"':
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		p.pos++
+		var s string
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+			return nil, p.errorf("malformed string: %s", err)
+		}
+		return s, nil
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for p.peek() != ']' {
+			if p.peek() == 0 {
+				return nil, p.errorf("unterminated list")
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.pos++
+		return list, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9'; p.pos++ {
+		}
+		n, err := strconv.Atoi(p.src[start:p.pos])
+		if err != nil {
+			return nil, p.errorf("malformed integer")
+		}
+		return float64(n), nil
+	}
+	word, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return nil, p.errorf("unsupported value %s", word)
+}
+
+// A gqlObject is a JSON object keeping its keys in selection order.
+type gqlObject []gqlMember
+
+type gqlMember struct {
+	key   string
+	value interface{}
+}
+
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// A gqlResolver resolves the fields of an object type.
+type gqlResolver interface {
+	typeName() string
+	resolve(f gqlField, args gqlArgs) (interface{}, error)
+}
+
+// gqlArgs are the arguments of a field, with variables substituted.
+type gqlArgs map[string]interface{}
+
+func (a gqlArgs) stringArg(name string, required bool) (string, error) {
+	switch v := a[name].(type) {
+	case string:
+		return v, nil
+	case nil:
+		if required {
+			return "", fmt.Errorf("argument %s is required", name)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("argument %s must be a String", name)
+}
+
+func (a gqlArgs) boolArg(name string) (bool, error) {
+	switch v := a[name].(type) {
+	case bool:
+		return v, nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("argument %s must be a Boolean", name)
+}
+
+func (a gqlArgs) intArg(name string, def int) (int, error) {
+	switch v := a[name].(type) {
+	case float64:
+		return int(v), nil
+	case nil:
+		return def, nil
+	}
+	return 0, fmt.Errorf("argument %s must be an Int", name)
+}
+
+// A gqlQuery executes a query against a graph.
+type gqlQuery struct {
+	graph     *jsonGraph
+	vars      map[string]interface{}
+	packages  map[string]jsonPackage
+	imports   map[string][]string
+	importers map[string][]string
+}
+
+func newGQLQuery(g *jsonGraph, vars map[string]interface{}) *gqlQuery {
+	q := &gqlQuery{graph: g, vars: vars, packages: make(map[string]jsonPackage)}
+	for _, p := range g.Packages {
+		q.packages[p.ImportPath] = p
+	}
+	q.imports, q.importers = g.Adjacency()
+	return q
+}
+
+// execute resolves the selection of fields on r.
+func (q *gqlQuery) execute(r gqlResolver, selection []gqlField) (gqlObject, error) {
+	var o gqlObject
+	for _, f := range selection {
+		args := make(gqlArgs)
+		for k, v := range f.args {
+			if name, ok := v.(gqlVariable); ok {
+				v = q.vars[string(name)]
+			}
+			args[k] = v
+		}
+		var v interface{}
+		var err error
+		if f.name == "__typename" {
+			v = r.typeName()
+		} else if v, err = r.resolve(f, args); err != nil {
+			return nil, fmt.Errorf("%s: %s", f.alias, err)
+		}
+		if v, err = q.complete(f, v); err != nil {
+			return nil, err
+		}
+		o = append(o, gqlMember{f.alias, v})
+	}
+	return o, nil
+}
+
+// complete resolves the selection of f on the objects in v.
+func (q *gqlQuery) complete(f gqlField, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case gqlResolver:
+		if f.selection == nil {
+			return nil, fmt.Errorf("%s: a selection of fields is required", f.alias)
+		}
+		return q.execute(v, f.selection)
+	case []gqlResolver:
+		list := []interface{}{}
+		for _, r := range v {
+			o, err := q.complete(f, r)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+		}
+		return list, nil
+	}
+	if f.selection != nil {
+		return nil, fmt.Errorf("%s: a scalar has no fields", f.alias)
+	}
+	return v, nil
+}
+
+func (q *gqlQuery) packageList(names []string) []gqlResolver {
+	list := []gqlResolver{}
+	for _, name := range names {
+		list = append(list, gqlPackage{q, q.packages[name]})
+	}
+	return list
+}
+
+// gqlRoot resolves the fields of Query.
+type gqlRoot struct{ q *gqlQuery }
+
+func (gqlRoot) typeName() string { return "Query" }
+
+func (r gqlRoot) resolve(f gqlField, args gqlArgs) (interface{}, error) {
+	q := r.q
+	switch f.name {
+	case "nodes":
+		hide, err := args.boolArg("hideStdlib")
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, p := range q.graph.Packages {
+			if !hide || !p.Goroot {
+				names = append(names, p.ImportPath)
+			}
+		}
+		return q.packageList(names), nil
+	case "node":
+		path, err := args.stringArg("importPath", true)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := q.packages[path]; ok {
+			return gqlPackage{q, p}, nil
+		}
+		return nil, nil
+	case "edges":
+		list := []gqlResolver{}
+		for _, e := range q.graph.Edges {
+			list = append(list, gqlEdge{q, e})
+		}
+		return list, nil
+	case "paths":
+		from, err := args.stringArg("from", true)
+		if err != nil {
+			return nil, err
+		}
+		to, err := args.stringArg("to", true)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := args.intArg("limit", 10)
+		if err != nil {
+			return nil, err
+		}
+		return q.paths(from, to, limit), nil
+	case "reverseDeps":
+		path, err := args.stringArg("importPath", true)
+		if err != nil {
+			return nil, err
+		}
+		transitive, err := args.boolArg("transitive")
+		if err != nil {
+			return nil, err
+		}
+		if !transitive {
+			return q.packageList(q.importers[path]), nil
+		}
+		seen := reachable(q.importers[path], func(name string) []string {
+			return q.importers[name]
+		})
+		delete(seen, path)
+		var names []string
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return q.packageList(names), nil
+	}
+	return nil, fmt.Errorf("no field %s on Query", f.name)
+}
+
+// paths returns up to limit chains of imports from from to to. Only packages
+// reaching to are followed, so that every step leads to a chain.
+func (q *gqlQuery) paths(from, to string, limit int) [][]string {
+	reaching := reachable([]string{to}, func(name string) []string {
+		return q.importers[name]
+	})
+	paths := [][]string{}
+	var path []string
+	onPath := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		if len(paths) >= limit || onPath[name] || !reaching[name] {
+			return
+		}
+		path = append(path, name)
+		onPath[name] = true
+		if name == to {
+			paths = append(paths, append([]string{}, path...))
+		} else {
+			for _, imp := range q.imports[name] {
+				walk(imp)
+			}
+		}
+		onPath[name] = false
+		path = path[:len(path)-1]
+	}
+	walk(from)
+	return paths
+}
+
+// gqlPackage resolves the fields of Package.
+type gqlPackage struct {
+	q *gqlQuery
+	p jsonPackage
+}
+
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (gqlPackage) typeName() string { return "Package" }
+
+func (r gqlPackage) resolve(f gqlField, args gqlArgs) (interface{}, error) {
+	p := r.p
+	switch f.name {
+	case "importPath":
+		return p.ImportPath, nil
+	case "dir":
+		return optional(p.Dir), nil
+	case "goroot":
+		return p.Goroot, nil
+	case "cgo":
+		return p.Cgo, nil
+	case "module":
+		return optional(p.Module), nil
+	case "version":
+		return optional(p.Version), nil
+	case "replace":
+		return optional(p.Replace), nil
+	case "private":
+		return p.Private, nil
+	case "missing":
+		return p.Missing, nil
+	case "error":
+		return optional(p.Error), nil
+	case "imports":
+		return r.q.packageList(r.q.imports[p.ImportPath]), nil
+	case "importers":
+		return r.q.packageList(r.q.importers[p.ImportPath]), nil
+	}
+	return nil, fmt.Errorf("no field %s on Package", f.name)
+}
+
+// gqlEdge resolves the fields of Edge.
+type gqlEdge struct {
+	q *gqlQuery
+	e jsonEdge
+}
+
+func (gqlEdge) typeName() string { return "Edge" }
+
+func (r gqlEdge) resolve(f gqlField, args gqlArgs) (interface{}, error) {
+	switch f.name {
+	case "from":
+		return gqlPackage{r.q, r.q.packages[r.e.From]}, nil
+	case "to":
+		return gqlPackage{r.q, r.q.packages[r.e.To]}, nil
+	case "denied":
+		return optional(r.e.Denied), nil
+	case "positions":
+		if r.e.Positions == nil {
+			return []string{}, nil
+		}
+		return r.e.Positions, nil
+	case "symbols":
+		if r.e.Symbols == nil {
+			return []string{}, nil
+		}
+		return r.e.Symbols, nil
+	case "kind":
+		return optional(r.e.Kind), nil
+	}
+	return nil, fmt.Errorf("no field %s on Edge", f.name)
+}
+
+// runGraphQL parses and executes query against g.
+func runGraphQL(g *jsonGraph, query string, vars map[string]interface{}) (gqlObject, error) {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	p := &gqlParser{src: query, vars: vars}
+	selection, err := p.document()
+	if err != nil {
+		return nil, err
+	}
+	q := newGQLQuery(g, vars)
+	return q.execute(gqlRoot{q}, selection)
+}
+
+// serveGraphQL answers GraphQL queries, given as the query parameter of a
+// GET or as a JSON body with query and variables in a POST. A GET without a
+// query returns the schema.
+func (s *graphServer) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		if req.Query == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprint(w, graphqlSchema)
+			return
+		}
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "malformed variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "malformed request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "use GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	g := s.graph
+	s.mu.Unlock()
+	type gqlError struct {
+		Message string `json:"message"`
+	}
+	var resp struct {
+		Data   gqlObject  `json:"data,omitempty"`
+		Errors []gqlError `json:"errors,omitempty"`
+	}
+	data, err := runGraphQL(g, req.Query, req.Variables)
+	if err != nil {
+		resp.Errors = []gqlError{{err.Error()}}
+	} else {
+		resp.Data = data
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
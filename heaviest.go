@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// lineCounts memoizes the lines of Go code of every package, by import path.
+var lineCounts = make(map[string]int)
+
+// packageLines returns the number of lines of the non-test Go files of pkg.
+func packageLines(pkg *node) int {
+	if n, ok := lineCounts[pkg.ImportPath]; ok {
+		return n
+	}
+	n := 0
+	for _, name := range pkg.GoFiles[:len(pkg.GoFiles)-pkg.TestGoFiles] {
+		data, err := ioutil.ReadFile(filepath.Join(pkg.Dir, name))
+		if err != nil {
+			continue
+		}
+		n += bytes.Count(data, []byte("\n"))
+	}
+	lineCounts[pkg.ImportPath] = n
+	return n
+}
+
+// A heavyDependency is an external module, or without module information an
+// external package, with the packages and lines of Go code it pulls in.
+type heavyDependency struct {
+	name            string
+	packages, lines int
+}
+
+// heaviestDependencies returns the n external dependencies of the graph
+// pulling in the most lines of Go code, counting their own packages and
+// those reached from them outside the standard library, heaviest first.
+func heaviestDependencies(pkgKeys, roots []string, n int) []heavyDependency {
+	groups := make(map[string][]string)
+	for _, name := range externalPackages(pkgKeys, roots) {
+		group := name
+		if m := packageModule(name); m != nil {
+			group = m.Path
+		}
+		groups[group] = append(groups[group], name)
+	}
+	var deps []heavyDependency
+	for group, members := range groups {
+		d := heavyDependency{name: group}
+		for name := range reachable(members, drawnImports) {
+			if pkg := pkgs[name]; pkg != nil && !pkg.Goroot {
+				d.packages++
+				d.lines += packageLines(pkg)
+			}
+		}
+		deps = append(deps, d)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].lines != deps[j].lines {
+			return deps[i].lines > deps[j].lines
+		}
+		if deps[i].packages != deps[j].packages {
+			return deps[i].packages > deps[j].packages
+		}
+		return deps[i].name < deps[j].name
+	})
+	if len(deps) > n {
+		deps = deps[:n]
+	}
+	return deps
+}
+
+// reportHeaviest writes the n heaviest external dependencies of the graph
+// to w, with the share of the lines of Go code of the graph outside the
+// standard library each pulls in.
+func reportHeaviest(w io.Writer, pkgKeys, roots []string, n int) {
+	deps := heaviestDependencies(pkgKeys, roots, n)
+	if len(deps) == 0 {
+		return
+	}
+	total := 0
+	for _, name := range pkgKeys {
+		if pkg := pkgs[name]; !isIgnored(pkg) && !pkg.Goroot {
+			total += packageLines(pkg)
+		}
+	}
+	fmt.Fprintln(w, "heaviest dependencies:")
+	for _, d := range deps {
+		share := 0
+		if total > 0 {
+			share = d.lines * 100 / total
+		}
+		fmt.Fprintf(w, "\t%s: %d packages, %d lines (%d%% of the graph)\n", d.name, d.packages, d.lines, share)
+	}
+}
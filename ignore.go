@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/kisielk/godepgraph/pattern"
+)
+
+// ignoreFileName is the name of the file of ignore patterns looked for in
+// the working directory.
+const ignoreFileName = ".godepgraphignore"
+
+// readIgnoreFile adds the patterns in the ignore file at path to the ignored
+// packages and prefixes. The file lists patterns separated by whitespace;
+// blank lines and lines starting with # are skipped. A missing file is not
+// an error.
+func readIgnoreFile(path string) error {
+	patterns, err := readRootsFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, p := range patterns {
+		if err := addIgnorePattern(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addIgnorePattern ignores the packages matching p: the import paths matched
+// by a glob, such as one ending in /... for a package and the packages below
+// it, or exactly one package.
+func addIgnorePattern(p string) error {
+	if !pattern.IsGlob(p) {
+		ignored[p] = true
+		return nil
+	}
+	re, err := pattern.Regexp(p)
+	if err != nil {
+		return err
+	}
+	ignoredRegexps = append(ignoredRegexps, re)
+	return nil
+}
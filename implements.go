@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"sort"
+)
+
+// implementsKind is the graph.Edge Kind of -implements edges.
+const implementsKind = "implements"
+
+// implementations holds, with -implements, the types of every package that
+// implement the interfaces of another, as "T implements I", sorted, by
+// package and interface package.
+var implementations map[string]map[string][]string
+
+// computeImplements records in implementations, for every package of the
+// graph outside the standard library, which of its exported types, or
+// pointers to them, implement the exported interfaces of the other such
+// packages. Empty interfaces, which everything implements, and generic
+// types are left out.
+func computeImplements(pkgKeys []string) {
+	implementations = make(map[string]map[string][]string)
+	si := newSymbolImporter()
+	type namedType struct {
+		pkg string
+		obj *types.TypeName
+	}
+	var ifaces, concrete []namedType
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		cp, err := si.check(pkg)
+		if err != nil {
+			log.Printf("failed to type-check %s: %s", name, err)
+			continue
+		}
+		scope := cp.pkg.Scope()
+		for _, id := range scope.Names() {
+			obj, ok := scope.Lookup(id).(*types.TypeName)
+			if !ok || !obj.Exported() || obj.IsAlias() {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				if iface.NumMethods() > 0 && iface.IsMethodSet() {
+					ifaces = append(ifaces, namedType{name, obj})
+				}
+			} else {
+				concrete = append(concrete, namedType{name, obj})
+			}
+		}
+	}
+
+	for _, c := range concrete {
+		for _, i := range ifaces {
+			if c.pkg == i.pkg {
+				continue
+			}
+			iface := i.obj.Type().Underlying().(*types.Interface)
+			if !types.Implements(c.obj.Type(), iface) && !types.Implements(types.NewPointer(c.obj.Type()), iface) {
+				continue
+			}
+			if implementations[c.pkg] == nil {
+				implementations[c.pkg] = make(map[string][]string)
+			}
+			impl := fmt.Sprintf("%s implements %s", c.obj.Name(), i.obj.Name())
+			implementations[c.pkg][i.pkg] = append(implementations[c.pkg][i.pkg], impl)
+		}
+	}
+	for _, byPkg := range implementations {
+		for _, impls := range byPkg {
+			sort.Strings(impls)
+		}
+	}
+}
+
+// implementedPackages returns the packages defining interfaces the types of
+// the package name implement, sorted.
+func implementedPackages(name string) []string {
+	var paths []string
+	for path := range implementations[name] {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
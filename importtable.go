@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// importTableColumns are the columns of the -import-table, which sorts by
+// any of them.
+var importTableColumns = []string{"package", "std", "internal", "external", "total"}
+
+// An importCount is a row of the -import-table: the direct imports of a
+// package, by where they come from.
+type importCount struct {
+	name                         string
+	std, internal, external, all int
+}
+
+// column returns the count of c in the column name, one of
+// importTableColumns but package.
+func (c importCount) column(name string) int {
+	switch name {
+	case "std":
+		return c.std
+	case "internal":
+		return c.internal
+	case "external":
+		return c.external
+	}
+	return c.all
+}
+
+// importCounts returns the direct imports of every package of pkgKeys
+// outside the standard library, sorted by the column by, the counts from
+// the largest and the package names alphabetically. Imports are counted
+// whether or not they are drawn, and those of the standard library whether
+// or not it is ignored.
+func importCounts(pkgKeys, roots []string, by string) []importCount {
+	var counts []importCount
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		c := importCount{name: name}
+		for _, imp := range getImports(pkg) {
+			switch {
+			case imp == "C":
+				continue
+			case isGorootPath(imp):
+				c.std++
+			case isExternal(imp, roots):
+				c.external++
+			default:
+				c.internal++
+			}
+			c.all++
+		}
+		counts = append(counts, c)
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if by != "package" {
+			if ci, cj := counts[i].column(by), counts[j].column(by); ci != cj {
+				return ci > cj
+			}
+		}
+		return counts[i].name < counts[j].name
+	})
+	return counts
+}
+
+// writeImportTable writes the table of the direct imports of every package
+// of pkgKeys outside the standard library to w, sorted by the column by.
+func writeImportTable(w io.Writer, pkgKeys, roots []string, by string) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "package\tstd\tinternal\texternal\ttotal")
+	for _, c := range importCounts(pkgKeys, roots, by) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", c.name, c.std, c.internal, c.external, c.all)
+	}
+	tw.Flush()
+}
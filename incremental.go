@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// incrementalConflict returns the name of the first flag set that needs
+// more of every package than a saved graph records, or "". The test edges
+// of a saved graph don't tell the imports both the tests and the other
+// files of a package make, so the flags including tests are among them.
+func incrementalConflict() string {
+	var name string
+	flag.Visit(func(f *flag.Flag) {
+		switch flagName(f.Name) {
+		case "aliases", "blank", "generated", "positions", "asm", "licenses", "deny-license", "binary", "from-list", "monorepo", "V", "symbols", "types", "callgraph", "implements", "xtest", "embed", "tools", "heaviest", "edge-counts", "bazel", "move", "anonymize", "t", "tests", "test-only", "affected-tests":
+			if name == "" {
+				name = f.Name
+			}
+		}
+	})
+	return name
+}
+
+// loadIncremental loads the graph of roots into pkgs from the graph saved
+// by an earlier run with the same flags at -incremental, resolving again
+// only the packages in the -changed directories, their importers and the
+// packages that were missing, and then the new imports these have. The
+// packages no longer reached from roots are dropped.
+func loadIncremental(cwd string, roots []string) error {
+	if name := incrementalConflict(); name != "" {
+		return errors.New("-incremental can't be combined with -" + name)
+	}
+	f, err := os.Open(*incrementalGraph)
+	if err != nil {
+		return err
+	}
+	base, err := graph.Read(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	changed := make(map[string]bool)
+	for _, dir := range changedList.items() {
+		if abs, err := filepath.Abs(dir); err == nil {
+			changed[abs] = true
+		}
+	}
+	imports, importers := base.Adjacency()
+	stale := make(map[string]bool)
+	for _, p := range base.Packages {
+		if p.Missing {
+			stale[p.ImportPath] = true
+		} else if changed[p.Dir] {
+			stale[p.ImportPath] = true
+			for _, imp := range importers[p.ImportPath] {
+				stale[imp] = true
+			}
+		}
+	}
+
+	var reload []string
+	for _, p := range base.Packages {
+		if stale[p.ImportPath] {
+			reload = append(reload, p.ImportPath)
+			continue
+		}
+		n := &node{ImportPath: intern(p.ImportPath), Dir: p.Dir, Goroot: p.Goroot, Doc: p.Doc}
+		if p.Cgo {
+			n.CgoFiles = 1
+		}
+		for _, imp := range imports[p.ImportPath] {
+			n.Imports = append(n.Imports, intern(imp))
+		}
+		pkgs[n.ImportPath] = n
+	}
+	if err := processPackages(cwd, append(reload, roots...)); err != nil {
+		return err
+	}
+
+	reached := reachable(roots, func(name string) []string {
+		if pkg := pkgs[name]; pkg != nil && (!pkg.Goroot || *delveGoroot) {
+			return getImports(pkg)
+		}
+		return nil
+	})
+	for name := range pkgs {
+		if !reached[name] {
+			delete(pkgs, name)
+		}
+	}
+	for name := range failures {
+		if !reached[name] {
+			delete(failures, name)
+		}
+	}
+	return nil
+}
+
+// changedDirs returns the sorted directories of the files whose
+// modification times differ between before and after, as watchedFiles
+// returns them. It returns false if a go.mod or go.sum file changed, which
+// may change the resolution of any package.
+func changedDirs(before, after map[string]time.Time) ([]string, bool) {
+	seen := make(map[string]bool)
+	var dirs []string
+	check := func(path string) bool {
+		if a, b := before[path], after[path]; a.Equal(b) {
+			return true
+		}
+		if base := filepath.Base(path); base == "go.mod" || base == "go.sum" {
+			return false
+		}
+		if dir := filepath.Dir(path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+		return true
+	}
+	for path := range before {
+		if !check(path) {
+			return nil, false
+		}
+	}
+	for path := range after {
+		if !check(path) {
+			return nil, false
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, true
+}
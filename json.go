@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// The JSON output is the model of package graph.
+type (
+	jsonGraph   = graph.Graph
+	jsonPackage = graph.Package
+	jsonEdge    = graph.Edge
+)
+
+// writeJSON writes the graph of the named packages to w as a JSON document.
+func writeJSON(w io.Writer, pkgKeys []string) error {
+	return writeIndentedJSON(w, jsonGraphOf(pkgKeys))
+}
+
+// jsonGraphOf returns the graph of the named packages in the model of
+// package graph.
+func jsonGraphOf(pkgKeys []string) *jsonGraph {
+	g := graph.New()
+	tools := toolPackages(rootPaths)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		jp := jsonPackage{
+			ImportPath: name,
+			Dir:        pkg.Dir,
+			Goroot:     pkg.Goroot,
+			Cgo:        pkg.CgoFiles > 0,
+			Doc:        pkg.Doc,
+			Embeds:     pkg.EmbedPatterns,
+			Tool:       tools[name],
+		}
+		jp.Annotations = annotationsOf(name)
+		if *anonymizeKey != "" {
+			jp.Dir = ""
+		}
+		if *churnPeriod != "" {
+			jp.Churn = churnOf(pkg)
+		}
+		jp.CompileTime = compileTimes[name]
+		jp.CodeSize = codeSizes[name]
+		if owner := packageOwners[name]; owner != "" {
+			jp.Owners = strings.Fields(owner)
+		}
+		if m := packageModule(name); m != nil {
+			jp.Module = m.Path
+			jp.Version = m.resolvedVersion()
+			jp.Replace = m.replacement()
+			jp.Private = !m.Main && isPrivate(m.Path)
+			if *showOrigins && !m.Main {
+				var checked bool
+				jp.Origin, checked = moduleOrigin(m)
+				jp.Unverified = !checked
+			}
+		}
+		g.Packages = append(g.Packages, jp)
+		for _, imp := range edgeImports(pkg) {
+			e := jsonEdge{From: name, To: imp}
+			e.Denied = edgeDenial(name, imp)
+			e.Test = isTestImport(pkg, imp)
+			e.Symbols = edgeSymbols[[2]string{name, imp}]
+			if *showPositions {
+				for _, pos := range importPositions(pkg, imp) {
+					e.Positions = append(e.Positions, pos.String())
+				}
+			}
+			g.Edges = append(g.Edges, e)
+		}
+		for _, path := range implementedPackages(name) {
+			g.Edges = append(g.Edges, jsonEdge{From: name, To: path, Kind: implementsKind, Symbols: implementations[name][path]})
+		}
+		if *showMissing {
+			for _, imp := range missingImports(pkg) {
+				g.Edges = append(g.Edges, jsonEdge{From: name, To: imp})
+			}
+		}
+	}
+	if *showMissing {
+		var missing []string
+		for path := range failures {
+			missing = append(missing, path)
+		}
+		sort.Strings(missing)
+		for _, path := range missing {
+			g.Packages = append(g.Packages, jsonPackage{ImportPath: path, Missing: true, Error: failures[path].Error()})
+		}
+	}
+
+	return g
+}
+
+// writeIndentedJSON writes v to w as JSON indented with tabs.
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kisielk/godepgraph/pattern"
+)
+
+// A layer is a named set of packages of a layered architecture.
+type layer struct {
+	Name     string
+	Patterns []pattern.Pattern
+}
+
+// layerRules declares the layers of an architecture and which layers each
+// may depend on. Packages belong to the first layer with a matching pattern;
+// packages outside every layer are not constrained.
+type layerRules struct {
+	Layers []layer
+	Allow  map[string]map[string]bool
+}
+
+// layers holds the rules read from the -layers file.
+var layers *layerRules
+
+// readLayers reads a layers file. Each line declares a layer and the
+// prefixes or globs of its packages, or the layers a layer may import:
+//
+//	layer domain github.com/acme/app/domain/...
+//	layer app github.com/acme/app/service/ github.com/acme/app/api/
+//	layer infra github.com/acme/app/infra/...
+//	allow app => domain
+//	allow infra => domain app
+//
+// Blank lines and lines starting with # are ignored.
+func readLayers(path string) (*layerRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lr := &layerRules{Allow: make(map[string]map[string]bool)}
+	declared := make(map[string]bool)
+	type allowLine struct {
+		lineno   int
+		from, to string
+	}
+	var allows []allowLine
+	s := bufio.NewScanner(f)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "layer":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("%s:%d: want layer name pattern...", path, lineno)
+			}
+			name := fields[1]
+			if declared[name] {
+				return nil, fmt.Errorf("%s:%d: layer %s declared twice", path, lineno, name)
+			}
+			declared[name] = true
+			l := layer{Name: name}
+			for _, p := range fields[2:] {
+				pp, err := pattern.Parse(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %s", path, lineno, err)
+				}
+				l.Patterns = append(l.Patterns, pp)
+			}
+			lr.Layers = append(lr.Layers, l)
+		case "allow":
+			if len(fields) < 4 || fields[2] != "=>" {
+				return nil, fmt.Errorf("%s:%d: want allow name => name...", path, lineno)
+			}
+			for _, to := range fields[3:] {
+				allows = append(allows, allowLine{lineno, fields[1], to})
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown directive %s", path, lineno, fields[0])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range allows {
+		for _, name := range []string{a.from, a.to} {
+			if !declared[name] {
+				return nil, fmt.Errorf("%s:%d: undeclared layer %s", path, a.lineno, name)
+			}
+		}
+		if lr.Allow[a.from] == nil {
+			lr.Allow[a.from] = make(map[string]bool)
+		}
+		lr.Allow[a.from][a.to] = true
+	}
+	return lr, nil
+}
+
+// layerOf returns the name of the layer of the package path, or the empty
+// string if it belongs to none.
+func (lr *layerRules) layerOf(path string) string {
+	for _, l := range lr.Layers {
+		for _, p := range l.Patterns {
+			if p.Match(path) {
+				return l.Name
+			}
+		}
+	}
+	return ""
+}
+
+// violation returns the layers of pkg and imp if the import of imp by pkg
+// crosses from one layer to another that it may not depend on.
+func (lr *layerRules) violation(pkg, imp string) (from, to string, ok bool) {
+	from, to = lr.layerOf(pkg), lr.layerOf(imp)
+	if from == "" || to == "" || from == to || lr.Allow[from][to] {
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// A layerEdge is an import crossing from one layer to another that it may
+// not depend on.
+type layerEdge struct {
+	From, To           string
+	FromLayer, ToLayer string
+}
+
+// layerEdges returns the drawn edges violating the -layers rules.
+func layerEdges(pkgKeys []string) []layerEdge {
+	if layers == nil {
+		return nil
+	}
+	var edges []layerEdge
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			if from, to, ok := layers.violation(name, imp); ok {
+				edges = append(edges, layerEdge{name, imp, from, to})
+			}
+		}
+	}
+	return edges
+}
+
+// checkLayers reports the drawn edges violating the -layers rules.
+func checkLayers(pkgKeys []string) []violation {
+	var vs []violation
+	for _, e := range layerEdges(pkgKeys) {
+		vs = append(vs, violation{"layers", fmt.Sprintf("%s -> %s\t%s=>%s", e.From, e.To, e.FromLayer, e.ToLayer)})
+	}
+	return vs
+}
+
+// reportLayers writes the edges violating the layers to w and returns their
+// number.
+func reportLayers(w io.Writer, pkgKeys []string) int {
+	edges := layerEdges(pkgKeys)
+	for _, e := range edges {
+		fmt.Fprintf(w, "layer violation: %s -> %s: layer %s may not import layer %s\n", e.From, e.To, e.FromLayer, e.ToLayer)
+	}
+	return len(edges)
+}
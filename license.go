@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// licenseRule identifies a license by phrases that all appear in its text.
+type licenseRule struct {
+	id      string
+	family  string
+	phrases []string
+	absent  []string
+}
+
+// licenseRules are checked in order; more specific rules come first.
+var licenseRules = []licenseRule{
+	{"AGPL-3.0", "copyleft", []string{"gnu affero general public license"}, nil},
+	{"LGPL-3.0", "weak-copyleft", []string{"gnu lesser general public license", "version 3"}, nil},
+	{"LGPL-2.1", "weak-copyleft", []string{"gnu lesser general public license"}, nil},
+	{"GPL-3.0", "copyleft", []string{"gnu general public license", "version 3"}, nil},
+	{"GPL-2.0", "copyleft", []string{"gnu general public license", "version 2"}, nil},
+	{"MPL-2.0", "weak-copyleft", []string{"mozilla public license", "2.0"}, nil},
+	{"EPL-2.0", "weak-copyleft", []string{"eclipse public license"}, nil},
+	{"Apache-2.0", "permissive", []string{"apache license", "version 2.0"}, nil},
+	{"MIT", "permissive", []string{"permission is hereby granted, free of charge"}, nil},
+	{"BSD-3-Clause", "permissive", []string{"redistribution and use in source and binary forms", "neither the name"}, nil},
+	{"BSD-2-Clause", "permissive", []string{"redistribution and use in source and binary forms"}, []string{"neither the name"}},
+	{"ISC", "permissive", []string{"permission to use, copy, modify, and/or distribute this software"}, nil},
+	{"Unlicense", "public-domain", []string{"this is free and unencumbered software"}, nil},
+	{"CC0-1.0", "public-domain", []string{"cc0 1.0 universal"}, nil},
+}
+
+var licenseFamilyColors = map[string]string{
+	"permissive":    "darkseagreen1",
+	"public-domain": "honeydew",
+	"weak-copyleft": "khaki1",
+	"copyleft":      "salmon",
+	"unknown":       "lightgray",
+}
+
+// license describes the license file governing a package.
+type license struct {
+	ID     string
+	Family string
+	File   string
+}
+
+var licenseCache = make(map[string]*license)
+
+// packageLicense returns the license of pkg, found by looking for a license
+// file in its directory and the directories above it up to the root of its
+// module or GOPATH entry. It returns nil for standard library packages and
+// for packages without a license file.
+func packageLicense(pkg *node) *license {
+	if pkg.Goroot || pkg.Dir == "" {
+		return nil
+	}
+	stop := ""
+	if pkg.Root != "" {
+		stop = filepath.Join(pkg.Root, "src")
+	}
+	dir := pkg.Dir
+	var visited []string
+	var lic *license
+	for {
+		if l, ok := licenseCache[dir]; ok {
+			lic = l
+			break
+		}
+		visited = append(visited, dir)
+		if lic = findLicense(dir); lic != nil {
+			break
+		}
+		if dir == stop || isModuleRoot(dir) {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for _, d := range visited {
+		licenseCache[d] = lic
+	}
+	return lic
+}
+
+func isModuleRoot(dir string) bool {
+	if strings.Contains(filepath.Base(dir), "@") {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// findLicense looks for a license file directly in dir.
+func findLicense(dir string) *license {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, fi := range entries {
+		name := strings.ToUpper(fi.Name())
+		if fi.IsDir() || !(strings.HasPrefix(name, "LICENSE") || strings.HasPrefix(name, "LICENCE") || strings.HasPrefix(name, "COPYING")) {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		id, family := classifyLicense(string(text))
+		return &license{ID: id, Family: family, File: path}
+	}
+	return nil
+}
+
+func classifyLicense(text string) (id, family string) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+rules:
+	for _, r := range licenseRules {
+		for _, p := range r.phrases {
+			if !strings.Contains(text, p) {
+				continue rules
+			}
+		}
+		for _, p := range r.absent {
+			if strings.Contains(text, p) {
+				continue rules
+			}
+		}
+		return r.id, r.family
+	}
+	return "unknown", "unknown"
+}
+
+// isDeniedLicense reports whether id matches one of the denied licenses.
+// A denied entry matches the license with the same identifier and, when it
+// has no version, every version of it: "GPL" denies GPL-2.0 and GPL-3.0.
+func isDeniedLicense(id string, denied []string) bool {
+	for _, d := range denied {
+		if strings.EqualFold(id, d) || strings.HasPrefix(strings.ToLower(id), strings.ToLower(d)+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// decorateLicense fills a node with the color of its license family.
+func decorateLicense(a *attrs, pkg *node) {
+	if pkg.Goroot {
+		return
+	}
+	lic := packageLicense(pkg)
+	id, family := "none", "unknown"
+	if lic != nil {
+		id, family = lic.ID, lic.Family
+	}
+	a.set("fillcolor", licenseFamilyColors[family])
+	a.appendAttr("tooltip", "license: "+id, `\n`)
+}
+
+// reportLicenses writes the license of every scanned non-standard package to
+// w and returns the packages whose license is denied.
+func reportLicenses(w io.Writer, pkgKeys []string, denied []string) []string {
+	var violations []string
+	fmt.Fprintln(w, "licenses:")
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		lic := packageLicense(pkg)
+		id := "none"
+		if lic != nil {
+			id = lic.ID
+		}
+		fmt.Fprintf(w, "\t%s: %s\n", name, id)
+		if isDeniedLicense(id, denied) {
+			violations = append(violations, fmt.Sprintf("%s: denied license %s", name, id))
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// packageURL returns the URL of the source of pkg the node of pkg links to
+// with -links, or "" if there is none to link to.
+func packageURL(pkg *node) string {
+	switch *linkTemplate {
+	case "file":
+		return fileURL(pkg.Dir)
+	case "auto":
+		return autoURL(pkg)
+	}
+	module, version, dir := linkFields(pkg)
+	return strings.NewReplacer(
+		"{import}", pkg.ImportPath,
+		"{module}", module,
+		"{version}", version,
+		"{dir}", dir,
+		"{path}", filepath.ToSlash(pkg.Dir),
+	).Replace(*linkTemplate)
+}
+
+// linkFields returns the module of pkg, its version and the directory of
+// pkg within it, for the -links templates. The standard library is module
+// std, and outside module mode the repository of the package, as guessed
+// from its import path, stands in for its module.
+func linkFields(pkg *node) (module, version, dir string) {
+	switch m := packageModule(pkg.ImportPath); {
+	case pkg.Goroot:
+		module = "std"
+	case m != nil:
+		module, version = m.Path, m.resolvedVersion()
+	default:
+		module = packageOwner(jsonPackage{ImportPath: pkg.ImportPath})
+	}
+	if module == "std" {
+		return module, version, pkg.ImportPath
+	}
+	return module, version, strings.TrimPrefix(strings.TrimPrefix(pkg.ImportPath, module), "/")
+}
+
+// autoURL returns the URL of the source of pkg for -links auto: its
+// directory on the code host of its repository at the version of its
+// module, or the page of pkg.go.dev listing its files for packages hosted
+// elsewhere and the standard library. Packages of the main modules hosted
+// elsewhere link to their directory.
+func autoURL(pkg *node) string {
+	module, version, dir := linkFields(pkg)
+	if pkg.Goroot {
+		return "https://pkg.go.dev/" + pkg.ImportPath
+	}
+	parts := strings.Split(module, "/")
+	if !codeHosts[parts[0]] || len(parts) < 3 {
+		if m := packageModule(pkg.ImportPath); m == nil || m.Main {
+			return fileURL(pkg.Dir)
+		}
+		if version != "" {
+			return "https://pkg.go.dev/" + pkg.ImportPath + "@" + version
+		}
+		return "https://pkg.go.dev/" + pkg.ImportPath
+	}
+	repo := strings.Join(parts[:3], "/")
+	// The major version suffix of the module names no directory of the
+	// repositories using major branches.
+	base, _ := majorVersion(module)
+	sub := strings.TrimPrefix(base, repo)
+	file := strings.TrimPrefix(path.Join(sub, dir), "/")
+	ref := sourceRef(version)
+	switch parts[0] {
+	case "gitlab.com":
+		return "https://" + repo + "/-/tree/" + ref + "/" + file
+	case "bitbucket.org":
+		return "https://" + repo + "/src/" + ref + "/" + file
+	}
+	return "https://" + repo + "/tree/" + ref + "/" + file
+}
+
+// sourceRef returns the git reference of the module version v: the commit
+// of a pseudo-version, the tag of a release and HEAD for the main module.
+func sourceRef(v string) string {
+	v = strings.TrimSuffix(v, "+incompatible")
+	switch {
+	case v == "" || v == "(devel)":
+		return "HEAD"
+	case isPseudoVersion(v):
+		return v[strings.LastIndex(v, "-")+1:]
+	}
+	return v
+}
+
+// fileURL returns the file URL of the directory dir, or "" if it is not
+// known.
+func fileURL(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	p := filepath.ToSlash(dir)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeList writes the import paths of the packages of pkgKeys to w, one a
+// line in sorted order, for -format list. With -list-sections they are
+// split into sections of the roots' own packages, the external ones and
+// those of the standard library, each headed by a comment line.
+func writeList(w io.Writer, pkgKeys []string) error {
+	if !*listSections {
+		for _, name := range pkgKeys {
+			if !isIgnored(pkgs[name]) {
+				if _, err := fmt.Fprintln(w, name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	var internal, external, std []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		switch {
+		case isIgnored(pkg):
+		case pkg.Goroot:
+			std = append(std, name)
+		case isExternal(name, rootPaths):
+			external = append(external, name)
+		default:
+			internal = append(internal, name)
+		}
+	}
+	first := true
+	for _, s := range []struct {
+		name  string
+		paths []string
+	}{{"internal", internal}, {"external", external}, {"stdlib", std}} {
+		if len(s.paths) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "# %s\n", s.name)
+		for _, name := range s.paths {
+			if _, err := fmt.Fprintln(w, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCount writes, for -format count, the number of dependencies of the
+// roots in the graph, of those external and, with -baseline, of those
+// external ones missing from the baseline to w, each on a line of its name
+// and the number, for scripts.
+func writeCount(w io.Writer, pkgKeys []string) error {
+	deps := 0
+	for _, name := range pkgKeys {
+		if !isIgnored(pkgs[name]) && !containsString(rootPaths, name) {
+			deps++
+		}
+	}
+	fmt.Fprintf(w, "deps %d\n", deps)
+	fmt.Fprintf(w, "external %d\n", len(externalPackages(pkgKeys, rootPaths)))
+	if baseline != nil {
+		fmt.Fprintf(w, "new %d\n", len(newExternal(pkgKeys, rootPaths)))
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A loader resolves the import path pkgName, as seen from the directory
+// srcDir, to a package.
+type loader func(pkgName, srcDir string) (*build.Package, error)
+
+// loaders holds the available package loaders by name.
+var loaders = map[string]loader{
+	"build": buildLoader,
+	"list":  listLoader,
+	"deps":  depsLoader,
+}
+
+// importPackage is the loader selected with -loader.
+var importPackage = buildLoader
+
+// prefetchPackages, if set, is given every set of packages to load before
+// importPackage is called for each, to resolve them in one go.
+var prefetchPackages func(srcDir string, pkgNames []string)
+
+// pkgModules records the module of every package loaded by a loader that
+// reports modules, guarded by pkgModulesMu as loaders run in parallel.
+var (
+	pkgModules   = make(map[string]*moduleInfo)
+	pkgModulesMu sync.Mutex
+)
+
+func buildLoader(pkgName, srcDir string) (*build.Package, error) {
+	return buildContext.Import(pkgName, srcDir, build.ImportComment)
+}
+
+// listPackage is the package information printed by `go list -json`. It is
+// the same protocol golang.org/x/tools/go/packages uses to talk to the go
+// command.
+type listPackage struct {
+	Dir          string
+	ImportPath   string
+	Name         string
+	Doc          string
+	Root         string
+	Goroot       bool
+	Standard     bool
+	Module       *moduleInfo
+	GoFiles      []string
+	CgoFiles     []string
+	SFiles       []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Imports      []string
+	TestImports  []string
+	XTestImports []string
+	Deps         []string
+
+	EmbedPatterns []string
+	ImportComment string
+
+	ForTest string
+	DepOnly bool
+
+	Error *struct {
+		Err string
+	}
+}
+
+// listLoader resolves a package by asking the go command, so that module
+// resolution, replace directives, vendoring and the module cache behave
+// exactly as they do for go build.
+func listLoader(pkgName, srcDir string) (*build.Package, error) {
+	lps, err := goList(srcDir, nil, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	if len(lps) != 1 {
+		return nil, fmt.Errorf("go list %s: expected one package, got %d", pkgName, len(lps))
+	}
+	return lps[0].buildPackage()
+}
+
+// goList runs `go list -e -json` with the given flags and arguments in dir.
+func goList(dir string, flags []string, args ...string) ([]*listPackage, error) {
+	cmdArgs := append([]string{"list", "-e", "-json"}, flags...)
+	if tags := goTags(); len(tags) > 0 {
+		cmdArgs = append(cmdArgs, "-tags="+strings.Join(tags, ","))
+	}
+	cmdArgs = append(cmdArgs, "--")
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.Command(goCmd, cmdArgs...)
+	cmd.Dir = dir
+	if buildContext.Dir != "" {
+		cmd.Dir = buildContext.Dir
+	}
+	if *verbose {
+		debugf("running go %s in %s\n", strings.Join(cmdArgs, " "), cmd.Dir)
+	}
+	cmd.Env = goEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return decodeListPackages(out)
+}
+
+// decodeListPackages decodes the stream of packages printed by
+// `go list -json`.
+func decodeListPackages(out []byte) ([]*listPackage, error) {
+	var lps []*listPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		lp := new(listPackage)
+		if err := dec.Decode(lp); err != nil {
+			return nil, fmt.Errorf("go list: %s", err)
+		}
+		lps = append(lps, lp)
+	}
+	return lps, nil
+}
+
+// goEnv returns the environment for go commands, reflecting the build
+// context.
+func goEnv() []string {
+	cgo := "0"
+	if buildContext.CgoEnabled {
+		cgo = "1"
+	}
+	return append(exec.Command(goCmd).Environ(),
+		"GOOS="+buildContext.GOOS,
+		"GOARCH="+buildContext.GOARCH,
+		"CGO_ENABLED="+cgo,
+		"GOPATH="+buildContext.GOPATH,
+	)
+}
+
+// buildPackage converts lp to the go/build representation used throughout
+// godepgraph, recording its module on the way.
+func (lp *listPackage) buildPackage() (*build.Package, error) {
+	if lp.Error != nil && lp.Dir == "" {
+		return nil, errors.New(strings.TrimSpace(lp.Error.Err))
+	}
+	if lp.Error != nil && len(lp.GoFiles)+len(lp.CgoFiles) == 0 {
+		return nil, errors.New(strings.TrimSpace(lp.Error.Err))
+	}
+	if lp.Module != nil {
+		pkgModulesMu.Lock()
+		pkgModules[lp.ImportPath] = lp.Module
+		pkgModulesMu.Unlock()
+	}
+	return &build.Package{
+		Dir:           lp.Dir,
+		Name:          lp.Name,
+		Doc:           lp.Doc,
+		ImportPath:    lp.ImportPath,
+		ImportComment: lp.ImportComment,
+		Root:          lp.Root,
+		Goroot:        lp.Goroot,
+		GoFiles:       lp.GoFiles,
+		CgoFiles:      lp.CgoFiles,
+		SFiles:        lp.SFiles,
+		TestGoFiles:   lp.TestGoFiles,
+		XTestGoFiles:  lp.XTestGoFiles,
+		Imports:       lp.Imports,
+		TestImports:   lp.TestImports,
+		XTestImports:  lp.XTestImports,
+		EmbedPatterns: lp.EmbedPatterns,
+	}, nil
+}
+
+// listed caches the packages reported by the deps loader, which holds
+// listedMu while it uses it.
+var (
+	listed   map[string]*build.Package
+	listedMu sync.Mutex
+)
+
+// depsLoader lists a root package together with its entire transitive
+// closure in a single `go list -deps` invocation and serves the imports of
+// the scan from the result. With -t, test dependencies are included in the
+// same invocation and test variants are folded into their packages.
+func depsLoader(pkgName, srcDir string) (*build.Package, error) {
+	// The packages of one go list -deps call are the dependencies of the
+	// next imports, so calls are not run in parallel.
+	listedMu.Lock()
+	defer listedMu.Unlock()
+	key := listedKey(pkgName, srcDir)
+	if pkg, ok := listed[key]; ok {
+		return pkg, nil
+	}
+
+	flags := []string{"-deps"}
+	if *includeTests {
+		flags = append(flags, "-test")
+	}
+	lps, err := goList(srcDir, flags, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := addListed(lps)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) != 1 {
+		return nil, fmt.Errorf("go list %s: package not found", pkgName)
+	}
+	root := roots[0]
+	listed[key] = root
+	return root, nil
+}
+
+// prefetchDeps lists the packages pkgNames not listed yet and their
+// transitive closure with a single `go list -deps` invocation, so that the
+// deps loader can serve the whole scan from the listed cache instead of
+// listing every root on its own. Failures are left for the deps loader to
+// report, for the packages they concern.
+func prefetchDeps(srcDir string, pkgNames []string) {
+	listedMu.Lock()
+	defer listedMu.Unlock()
+	var missing []string
+	for _, name := range pkgNames {
+		if _, ok := listed[listedKey(name, srcDir)]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) < 2 {
+		return
+	}
+
+	flags := []string{"-deps"}
+	if *includeTests {
+		flags = append(flags, "-test")
+	}
+	lps, err := goList(srcDir, flags, missing...)
+	if err == nil {
+		_, err = addListed(lps)
+	}
+	if err != nil && *verbose {
+		debugf("failed to list %d packages at once, listing them one at a time: %s\n", len(missing), err)
+	}
+}
+
+// listedKey returns the import path the package pkgName, resolved from
+// srcDir, is listed by: pkgName itself unless it is a relative path.
+func listedKey(pkgName, srcDir string) string {
+	if build.IsLocalImport(pkgName) {
+		return localImportPath(filepath.Join(srcDir, filepath.FromSlash(pkgName)))
+	}
+	return pkgName
+}
+
+// addListed adds the packages printed by `go list -json` to the listed
+// cache and returns those that were not listed only as dependencies. Test
+// variants and generated test mains are skipped, as they add nothing the
+// plain packages don't already report.
+func addListed(lps []*listPackage) ([]*build.Package, error) {
+	if listed == nil {
+		listed = make(map[string]*build.Package)
+	}
+	var roots []*build.Package
+	for _, lp := range lps {
+		if lp.ForTest != "" || strings.HasSuffix(lp.ImportPath, ".test") {
+			continue
+		}
+		pkg, err := lp.buildPackage()
+		if err != nil {
+			if !lp.DepOnly {
+				return nil, err
+			}
+			continue
+		}
+		listed[pkg.ImportPath] = pkg
+		if !lp.DepOnly {
+			roots = append(roots, pkg)
+		}
+	}
+	return roots, nil
+}
+
+// readListed reads the output of `go list -deps -json` from path, or from
+// stdin if path is "-", into the listed cache and makes it the only source
+// of packages. It returns the import paths of the listed root packages.
+func readListed(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lps, err := decodeListPackages(data)
+	if err != nil {
+		return nil, err
+	}
+	rootPkgs, err := addListed(lps)
+	if err != nil {
+		return nil, err
+	}
+	importPackage = listedLoader
+
+	var roots []string
+	for _, pkg := range rootPkgs {
+		roots = append(roots, pkg.ImportPath)
+	}
+	return roots, nil
+}
+
+// listedLoader serves packages from the listed cache only.
+func listedLoader(pkgName, srcDir string) (*build.Package, error) {
+	if pkg, ok := listed[pkgName]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("package %s is not in the go list input", pkgName)
+}
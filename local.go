@@ -0,0 +1,105 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// localImportPath returns the import path the go command gives the package
+// in dir when it is named by a relative path such as ./cmd/foo: its import
+// path if dir is in GOROOT or GOPATH, and otherwise _ followed by dir, as in
+// _/home/me/proj/cmd/foo.
+func localImportPath(dir string) string {
+	for _, src := range srcDirs() {
+		if rel, ok := subdir(src, dir); ok {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return "_" + filepath.ToSlash(dir)
+}
+
+// subdir returns dir relative to root if it is below root, evaluating
+// symbolic links in both if it isn't as given, as go/build does.
+func subdir(root, dir string) (string, bool) {
+	below := func(root, dir string) (string, bool) {
+		rel, err := filepath.Rel(root, dir)
+		return rel, err == nil && rel != "." && !isParentRel(rel)
+	}
+	if rel, ok := below(root, dir); ok {
+		return rel, true
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", false
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", false
+	}
+	return below(realRoot, realDir)
+}
+
+// isLocalPath reports whether path is the import path localImportPath gives
+// a package outside GOROOT and GOPATH.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "_/")
+}
+
+// loaderPath returns the path a loader resolves the package path to from
+// srcDir: path itself, unless it is a local package outside GOROOT and
+// GOPATH, which the loaders only find by a path relative to srcDir.
+func loaderPath(path, srcDir string) string {
+	if !isLocalPath(path) {
+		return path
+	}
+	rel, err := filepath.Rel(srcDir, filepath.FromSlash(path[1:]))
+	if err != nil {
+		return path
+	}
+	return "./" + filepath.ToSlash(rel)
+}
+
+// resolveLocal replaces the relative imports of pkg, such as ../util, with
+// the import paths of the packages they name, by which they are loaded and
+// drawn.
+func resolveLocal(pkg *node) {
+	for i, imp := range pkg.Imports {
+		if !build.IsLocalImport(imp) {
+			continue
+		}
+		p := intern(localImportPath(filepath.Join(pkg.Dir, filepath.FromSlash(imp))))
+		pkg.Imports[i] = p
+		if pos, ok := pkg.ImportPos[imp]; ok {
+			delete(pkg.ImportPos, imp)
+			pkg.ImportPos[p] = append(pkg.ImportPos[p], pos...)
+		}
+	}
+}
+
+// resolvedImport returns the import path the graph resolves the import imp
+// of the package in dir to, as resolveLocal and resolveVendored do.
+func resolvedImport(dir, imp string) string {
+	if build.IsLocalImport(imp) {
+		return localImportPath(filepath.Join(dir, filepath.FromSlash(imp)))
+	}
+	if v := vendoredPath(dir, imp); v != "" {
+		return v
+	}
+	return imp
+}
+
+// dedupImports drops the imports of pkg that resolved to the same package
+// as an earlier one, such as a relative import in its code and the full
+// import path in its tests, or to the package itself.
+func dedupImports(pkg *node) {
+	seen := make(map[string]bool)
+	kept := pkg.Imports[:0]
+	for _, imp := range pkg.Imports {
+		if !seen[imp] && imp != pkg.ImportPath {
+			seen[imp] = true
+			kept = append(kept, imp)
+		}
+	}
+	pkg.Imports = kept
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	majorSuffixRe = regexp.MustCompile(`^v([0-9]+)$`)
+	gopkgInRe     = regexp.MustCompile(`^(gopkg\.in/(?:[^/]+/)?[^/.]+)\.(v[0-9]+)(?:-unstable)?(?:/|$)`)
+)
+
+// majorVersion splits the module path of the package path into the path
+// without its major version suffix and the suffix itself, e.g.
+// "github.com/foo/bar/v2/baz" yields "github.com/foo/bar" and "v2". Paths
+// without a suffix of v2 or above yield a major version of "v1". The module
+// path is taken from the loaded module information where available, and
+// otherwise inferred from the first major version element of the path.
+func majorVersion(path string) (base, major string) {
+	modPath := path
+	if m := packageModule(path); m != nil {
+		modPath = m.Path
+	}
+	if m := gopkgInRe.FindStringSubmatch(modPath); m != nil {
+		return m[1], m[2]
+	}
+	elems := strings.Split(modPath, "/")
+	for i := 1; i < len(elems); i++ {
+		m := majorSuffixRe.FindStringSubmatch(elems[i])
+		if m == nil {
+			continue
+		}
+		if n, _ := strconv.Atoi(m[1]); n >= 2 {
+			return strings.Join(elems[:i], "/"), elems[i]
+		}
+	}
+	if m := packageModule(path); m != nil {
+		return m.Path, "v1"
+	}
+	return path, "v1"
+}
+
+// majorDuplicates returns, for every module path scanned in more than one
+// major version, the sorted list of those versions.
+func majorDuplicates(pkgKeys []string) map[string][]string {
+	var bases []string
+	majors := make(map[string]map[string]bool)
+	for _, name := range pkgKeys {
+		if isIgnored(pkgs[name]) || pkgs[name].Goroot {
+			continue
+		}
+		base, major := majorVersion(name)
+		if major == "v1" {
+			continue
+		}
+		if majors[base] == nil {
+			majors[base] = make(map[string]bool)
+			bases = append(bases, base)
+		}
+		majors[base][major] = true
+	}
+	// Without module information a v1 package is only recognizable by
+	// living under the base path of a newer major version.
+	for _, name := range pkgKeys {
+		if isIgnored(pkgs[name]) || pkgs[name].Goroot {
+			continue
+		}
+		base, major := majorVersion(name)
+		if major != "v1" {
+			continue
+		}
+		if packageModule(name) == nil {
+			base = moduleFor(name, bases)
+		}
+		if majors[base] != nil {
+			majors[base]["v1"] = true
+		}
+	}
+
+	dups := make(map[string][]string)
+	for base, set := range majors {
+		if len(set) < 2 {
+			continue
+		}
+		var vs []string
+		for v := range set {
+			vs = append(vs, v)
+		}
+		sort.Slice(vs, func(i, j int) bool {
+			a, _ := strconv.Atoi(vs[i][1:])
+			b, _ := strconv.Atoi(vs[j][1:])
+			return a < b
+		})
+		dups[base] = vs
+	}
+	return dups
+}
+
+// decorateMajor draws packages of v2+ modules as boxes and outlines those of
+// modules present in several major versions in red.
+func decorateMajor(a *attrs, path string, dups map[string][]string) {
+	base, major := majorVersion(path)
+	if major != "v1" {
+		a.set("shape", "box")
+		a.addStyle("rounded")
+		a.appendAttr("tooltip", "major version "+major, `\n`)
+	}
+	if vs := dups[base]; len(vs) > 0 {
+		if a.get("fillcolor") == "" {
+			a.set("fillcolor", a.get("color"))
+		}
+		a.set("color", "red")
+		a.set("penwidth", "2")
+		a.appendAttr("tooltip", base+" is used as "+strings.Join(vs, ", "), `\n`)
+	}
+}
+
+// reportMajorDuplicates writes the modules used in several major versions
+// to w.
+func reportMajorDuplicates(w io.Writer, dups map[string][]string) {
+	if len(dups) == 0 {
+		return
+	}
+	bases := []string{}
+	for b := range dups {
+		bases = append(bases, b)
+	}
+	sort.Strings(bases)
+	fmt.Fprintln(w, "modules used in several major versions:")
+	for _, b := range bases {
+		fmt.Fprintf(w, "\t%s: %s\n", b, strings.Join(dups[b], ", "))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// runMerge implements the merge subcommand: `merge a.json b.json...` combines
+// graphs written by -format json, such as those of several services, into one
+// with every package and edge once, written as JSON or with -format dot in
+// dot format.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	format := fs.String("format", "json", "output format: json or dot")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: godepgraph merge [-format json|dot] graph.json...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "json", "dot":
+	default:
+		log.Fatalf("unsupported merge format %q, want json or dot", *format)
+	}
+
+	var graphs []*jsonGraph
+	for _, path := range fs.Args() {
+		g, err := readJSONGraph(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		graphs = append(graphs, g)
+	}
+	g := mergeGraphs(graphs)
+	var err error
+	if *format == "dot" {
+		err = writeGraphDot(os.Stdout, g)
+	} else {
+		err = writeIndentedJSON(os.Stdout, g)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// mergeGraphs returns the union of graphs, sorted. Of the fields of a package
+// or edge found in several graphs, the first set is kept, except the
+// positions of edges, which are combined.
+func mergeGraphs(graphs []*jsonGraph) *jsonGraph {
+	packages := make(map[string]*jsonPackage)
+	edges := make(map[[3]string]*jsonEdge)
+	for _, g := range graphs {
+		for _, p := range g.Packages {
+			if q := packages[p.ImportPath]; q != nil {
+				mergePackage(q, p)
+			} else {
+				p := p
+				packages[p.ImportPath] = &p
+			}
+		}
+		for _, e := range g.Edges {
+			k := [3]string{e.From, e.To, e.Kind}
+			if f := edges[k]; f != nil {
+				if f.Denied == "" {
+					f.Denied = e.Denied
+				}
+				// An import of the tests in one graph may be one of the
+				// code in another.
+				f.Test = f.Test && e.Test
+				f.Positions = mergeStrings(f.Positions, e.Positions)
+				f.Symbols = mergeStrings(f.Symbols, e.Symbols)
+			} else {
+				e := e
+				edges[k] = &e
+			}
+		}
+	}
+
+	merged := graph.New()
+	for _, p := range packages {
+		merged.Packages = append(merged.Packages, *p)
+	}
+	for _, e := range edges {
+		merged.Edges = append(merged.Edges, *e)
+	}
+	merged.Sort()
+	return merged
+}
+
+// mergePackage sets the fields of dst that are unset from src.
+func mergePackage(dst *jsonPackage, src jsonPackage) {
+	for _, f := range [][2]*string{
+		{&dst.Dir, &src.Dir},
+		{&dst.Doc, &src.Doc},
+		{&dst.Module, &src.Module},
+		{&dst.Version, &src.Version},
+		{&dst.Replace, &src.Replace},
+		{&dst.Origin, &src.Origin},
+		{&dst.Error, &src.Error},
+	} {
+		if *f[0] == "" {
+			*f[0] = *f[1]
+		}
+	}
+	dst.Goroot = dst.Goroot || src.Goroot
+	dst.Cgo = dst.Cgo || src.Cgo
+	dst.Private = dst.Private || src.Private
+	dst.Unverified = dst.Unverified || src.Unverified
+	// Tooling in one graph may be a dependency of the code in another.
+	dst.Tool = dst.Tool && src.Tool
+	dst.Embeds = mergeStrings(dst.Embeds, src.Embeds)
+	if dst.CompileTime == 0 {
+		dst.CompileTime = src.CompileTime
+	}
+	if dst.CodeSize == 0 {
+		dst.CodeSize = src.CodeSize
+	}
+	if dst.Owners == nil {
+		dst.Owners = src.Owners
+	}
+	for k, v := range src.Annotations {
+		if _, ok := dst.Annotations[k]; !ok {
+			if dst.Annotations == nil {
+				dst.Annotations = make(map[string]string)
+			}
+			dst.Annotations[k] = v
+		}
+	}
+	// A package is missing only if no graph could load it.
+	if dst.Missing && !src.Missing {
+		dst.Missing, dst.Error = false, ""
+	}
+}
+
+// mergeStrings appends the elements of b missing from a to a.
+func mergeStrings(a, b []string) []string {
+	have := make(map[string]bool)
+	for _, s := range a {
+		have[s] = true
+	}
+	for _, s := range b {
+		if !have[s] {
+			a = append(a, s)
+			have[s] = true
+		}
+	}
+	return a
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// writeMetrics writes gauges describing g to w in the Prometheus text
+// exposition format.
+func writeMetrics(w io.Writer, g *jsonGraph) {
+	imports, importers := g.Adjacency()
+	// The modules of the roots, which nothing imports, are the graph's own.
+	own := make(map[string]bool)
+	for _, p := range g.Packages {
+		if len(importers[p.ImportPath]) == 0 {
+			own[packageOwner(p)] = true
+		}
+	}
+	external := 0
+	perOwner := make(map[string]int)
+	for _, p := range g.Packages {
+		owner := packageOwner(p)
+		perOwner[owner]++
+		if !p.Goroot && !own[owner] {
+			external++
+		}
+	}
+
+	gauge(w, "godepgraph_packages", "Number of packages in the graph.", len(g.Packages))
+	gauge(w, "godepgraph_external_packages", "Number of packages outside the standard library and the modules of the roots.", external)
+	gauge(w, "godepgraph_edges", "Number of imports in the graph.", len(g.Edges))
+	gauge(w, "godepgraph_max_depth", "Number of imports of the longest chain from a root.", maxDepth(g, imports))
+	gauge(w, "godepgraph_cycles", "Number of import cycles, as strongly connected components.", countCycles(g, imports))
+
+	fmt.Fprintln(w, "# HELP godepgraph_prefix_packages Number of packages per module, or repository outside module mode.")
+	fmt.Fprintln(w, "# TYPE godepgraph_prefix_packages gauge")
+	var owners []string
+	for owner := range perOwner {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		fmt.Fprintf(w, "godepgraph_prefix_packages{prefix=%q} %d\n", owner, perOwner[owner])
+	}
+}
+
+func gauge(w io.Writer, name, help string, value int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+// codeHosts are the hosts whose repositories are named by the two path
+// elements after the host.
+var codeHosts = map[string]bool{
+	"github.com":    true,
+	"gitlab.com":    true,
+	"bitbucket.org": true,
+}
+
+// packageOwner returns what p is grouped by in the metrics: std for the
+// standard library, its module in module mode, and otherwise its
+// repository, guessed from its import path: the first three elements on a
+// code host, two if it starts with another domain name and one if not.
+func packageOwner(p jsonPackage) string {
+	switch {
+	case p.Goroot:
+		return "std"
+	case p.Module != "":
+		return p.Module
+	}
+	parts := strings.Split(p.ImportPath, "/")
+	n := 1
+	if codeHosts[parts[0]] {
+		n = 3
+	} else if strings.Contains(parts[0], ".") {
+		n = 2
+	}
+	if len(parts) > n {
+		parts = parts[:n]
+	}
+	return strings.Join(parts, "/")
+}
+
+// maxDepth returns the number of imports of the longest chain of g, not
+// following imports closing a cycle.
+func maxDepth(g *jsonGraph, imports map[string][]string) int {
+	length := make(map[string]int)
+	onStack := make(map[string]bool)
+	var walk func(name string) int
+	walk = func(name string) int {
+		if n, ok := length[name]; ok {
+			return n
+		}
+		onStack[name] = true
+		best := 0
+		for _, imp := range imports[name] {
+			if !onStack[imp] {
+				if n := walk(imp) + 1; n > best {
+					best = n
+				}
+			}
+		}
+		onStack[name] = false
+		length[name] = best
+		return best
+	}
+	max := 0
+	for _, p := range g.Packages {
+		if n := walk(p.ImportPath); n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// countCycles returns the number of strongly connected components of g
+// with more than one package.
+func countCycles(g *jsonGraph, imports map[string][]string) int {
+	// Tarjan's algorithm, as in cycles.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	next, n := 0, 0
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = next
+		low[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, imp := range imports[name] {
+			if _, ok := index[imp]; !ok {
+				connect(imp)
+				if low[imp] < low[name] {
+					low[name] = low[imp]
+				}
+			} else if onStack[imp] && index[imp] < low[name] {
+				low[name] = index[imp]
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		size := 0
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			size++
+			if top == name {
+				break
+			}
+		}
+		if size > 1 {
+			n++
+		}
+	}
+	for _, p := range g.Packages {
+		if _, ok := index[p.ImportPath]; !ok {
+			connect(p.ImportPath)
+		}
+	}
+	return n
+}
+
+// serveMetrics serves the metrics of the graph returned by current.
+func serveMetrics(current func() (*jsonGraph, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := current()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, g)
+	}
+}
+
+// listenMetrics serves the metrics of the graph returned by current at
+// /metrics on addr in the background.
+func listenMetrics(addr string, current func() (*jsonGraph, error)) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", serveMetrics(current))
+	log.Printf("serving metrics on %s", addr)
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
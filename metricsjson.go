@@ -0,0 +1,63 @@
+package main
+
+import "io"
+
+// A packageMetrics holds the metrics of a package written by -format
+// metrics-json. Depth is the number of imports of the shortest chain from
+// the roots, left out for packages they don't reach, and Closure the number
+// of packages the package imports directly or indirectly.
+type packageMetrics struct {
+	ImportPath string `json:"importPath"`
+	Module     string `json:"module,omitempty"`
+	FanIn      int    `json:"fanIn"`
+	FanOut     int    `json:"fanOut"`
+	Depth      *int   `json:"depth,omitempty"`
+	Closure    int    `json:"closure"`
+}
+
+// writeMetricsJSON writes the metrics of every package of the graph of
+// pkgKeys to w as a JSON document, without the edges, sorted by import
+// path.
+func writeMetricsJSON(w io.Writer, pkgKeys []string) error {
+	g := jsonGraphOf(pkgKeys)
+	g.Sort()
+	imports, importers := g.Adjacency()
+	next := func(name string) []string { return imports[name] }
+
+	depth := make(map[string]int)
+	var queue []string
+	for _, root := range rootPaths {
+		if _, ok := depth[root]; !ok {
+			depth[root] = 0
+			queue = append(queue, root)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, imp := range imports[name] {
+			if _, ok := depth[imp]; !ok {
+				depth[imp] = depth[name] + 1
+				queue = append(queue, imp)
+			}
+		}
+	}
+
+	metrics := []packageMetrics{}
+	for _, p := range g.Packages {
+		m := packageMetrics{
+			ImportPath: p.ImportPath,
+			Module:     p.Module,
+			FanIn:      len(importers[p.ImportPath]),
+			FanOut:     len(imports[p.ImportPath]),
+			Closure:    len(reachable([]string{p.ImportPath}, next)) - 1,
+		}
+		if d, ok := depth[p.ImportPath]; ok {
+			m.Depth = &d
+		}
+		metrics = append(metrics, m)
+	}
+	return writeIndentedJSON(w, struct {
+		Packages []packageMetrics `json:"packages"`
+	}{metrics})
+}
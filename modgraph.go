@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// modRequirement is an edge of the module requirement graph.
+type modRequirement struct {
+	From, To string
+}
+
+// loadModGraph runs `go mod graph` in dir and returns the requirements of the
+// selected version of every module in the build list. Modules are identified
+// by path alone.
+func loadModGraph(dir string) ([]modRequirement, error) {
+	cmd := exec.Command(goCmd, "mod", "graph")
+	cmd.Dir = dir
+	cmd.Env = goEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go mod graph: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	seen := make(map[modRequirement]bool)
+	var reqs []modRequirement
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		from, fromVersion := splitModVersion(fields[0])
+		to, _ := splitModVersion(fields[1])
+		if m := modules[from]; m == nil || (!m.Main && m.Version != fromVersion) {
+			// Requirements of versions that lost minimal version
+			// selection don't contribute to the build.
+			continue
+		}
+		r := modRequirement{from, to}
+		if !seen[r] && from != to {
+			seen[r] = true
+			reqs = append(reqs, r)
+		}
+	}
+	return reqs, s.Err()
+}
+
+func splitModVersion(s string) (path, version string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// writeModuleClusters groups the rendered packages of pkgKeys into one
+// cluster per module, each holding a module node, and connects the module
+// nodes with the requirement edges in reqs.
+func writeModuleClusters(w io.Writer, pkgKeys []string, reqs []modRequirement) {
+	members := make(map[string][]string)
+	var mods []string
+	for _, name := range pkgKeys {
+		if isIgnored(pkgs[name]) {
+			continue
+		}
+		m := packageModule(name)
+		if m == nil {
+			continue
+		}
+		if members[m.Path] == nil {
+			mods = append(mods, m.Path)
+		}
+		members[m.Path] = append(members[m.Path], name)
+	}
+	sort.Strings(mods)
+
+	for i, mod := range mods {
+		m := modules[mod]
+		label := mod
+		if v := m.resolvedVersion(); v != "" && !m.Main {
+			label += "@" + v
+		}
+		fmt.Fprintf(w, "subgraph cluster_%d {\n", i)
+		fmt.Fprintf(w, "graph [%s];\n", attrs{{"label", label}, {"style", "rounded"}, {"color", "gray50"}})
+		fmt.Fprintf(w, "_%d [%s];\n", getId("module "+mod), attrs{{"label", label}, {"shape", "folder"}, {"style", "filled"}, {"color", "gray85"}})
+		for _, name := range members[mod] {
+			fmt.Fprintf(w, "_%d;\n", getId(name))
+		}
+		fmt.Fprintln(w, "}")
+	}
+
+	for _, r := range reqs {
+		if members[r.From] == nil || members[r.To] == nil {
+			continue
+		}
+		fmt.Fprintf(w, "_%d -> _%d [%s];\n", getId("module "+r.From), getId("module "+r.To), attrs{{"style", "dashed"}, {"color", "gray50"}})
+	}
+}
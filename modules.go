@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// moduleInfo is the module information printed by `go list -m -json`.
+type moduleInfo struct {
+	Path      string
+	Version   string
+	Replace   *moduleInfo
+	Time      *time.Time
+	Main      bool
+	Indirect  bool
+	Dir       string
+	GoMod     string
+	GoVersion string
+	// Update is the latest version of the module, with -outdated.
+	Update *moduleInfo
+	// Major is the latest version of the highest major version of the
+	// module above its own, with -outdated.
+	Major *moduleInfo
+}
+
+var (
+	modules     map[string]*moduleInfo
+	modulePaths []string
+
+	pseudoVersionRe = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+)
+
+// needModules reports whether any of the requested features depends on
+// module information.
+func needModules() bool {
+	return *showVersions || *showReplaced || *showPseudo || *moduleClusters || *showOutdated || *checkSum || *showOrigins || *vendorPreview
+}
+
+// loadModules lists the modules in the build list of the main module
+// containing dir. Modules already loaded, e.g. from another module of a
+// monorepo, are kept. In vendor mode, where the go command can't list the
+// build list, the vendored modules are read from vendor/modules.txt.
+func loadModules(dir string) error {
+	if workspace == nil {
+		if gomod := findGoMod(dir); gomod != "" && vendorMode(gomod) {
+			return loadVendorModules(gomod)
+		}
+	}
+
+	// The go command asks the module proxy for the latest versions.
+	ms, err := listModules(dir, *showOutdated)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		addModule(m)
+	}
+	if *showOutdated {
+		return lookupMajors(dir, ms)
+	}
+	return nil
+}
+
+// listModules returns the modules of the build list of the main module
+// containing dir, with their latest versions if update is set.
+func listModules(dir string, update bool) ([]*moduleInfo, error) {
+	args := []string{"list", "-m", "-json"}
+	if update {
+		args = append(args, "-u")
+	}
+	cmd := exec.Command(goCmd, append(args, "all")...)
+	cmd.Dir = dir
+	cmd.Env = goEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var ms []*moduleInfo
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		m := new(moduleInfo)
+		if err := dec.Decode(m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list -m: %s", err)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// addModule adds m to the loaded modules unless it is already known. Main
+// modules replace other entries for the same path.
+func addModule(m *moduleInfo) {
+	if modules == nil {
+		modules = make(map[string]*moduleInfo)
+	}
+	if old, ok := modules[m.Path]; ok && (old.Main || !m.Main) {
+		return
+	}
+	if _, ok := modules[m.Path]; !ok {
+		modulePaths = append(modulePaths, m.Path)
+	}
+	modules[m.Path] = m
+}
+
+// vendorMode reports whether the go command resolves the packages of the
+// module of the go.mod file gomod from its vendor directory: either because
+// GOFLAGS says -mod=vendor or, without a -mod flag, because the module has
+// a vendor/modules.txt file and declares go 1.14 or later.
+func vendorMode(gomod string) bool {
+	if mode := modFlag(); mode != "" {
+		return mode == "vendor"
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(gomod), "vendor", "modules.txt")); err != nil {
+		return false
+	}
+	mf, err := readGoMod(gomod)
+	if err != nil {
+		return false
+	}
+	var major, minor int
+	fmt.Sscanf(mf.Go, "%d.%d", &major, &minor)
+	return major > 1 || major == 1 && minor >= 14
+}
+
+// loadVendorModules loads the main module of the go.mod file gomod and the
+// modules listed in its vendor/modules.txt.
+func loadVendorModules(gomod string) error {
+	mf, err := readGoMod(gomod)
+	if err != nil {
+		return err
+	}
+	addModule(&moduleInfo{Path: mf.Path, Main: true, Dir: mf.Dir, GoMod: gomod, GoVersion: mf.Go})
+	modulesVendored = true
+
+	path := filepath.Join(mf.Dir, "vendor", "modules.txt")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Module lines are "# path version", optionally followed by
+		// "=> replacement [version]"; "## " lines annotate them.
+		if !strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line[2:])
+		if len(fields) == 0 {
+			continue
+		}
+		m := &moduleInfo{Path: fields[0]}
+		fields = fields[1:]
+		if len(fields) > 0 && fields[0] != "=>" {
+			m.Version, fields = fields[0], fields[1:]
+		}
+		if len(fields) >= 2 && fields[0] == "=>" {
+			m.Replace = &moduleInfo{Path: fields[1]}
+			if len(fields) >= 3 {
+				m.Replace.Version = fields[2]
+			}
+		}
+		if m.Version == "" && m.Replace == nil {
+			return fmt.Errorf("%s: malformed module line %q", path, line)
+		}
+		addModule(m)
+	}
+	return nil
+}
+
+// packageModule returns the module providing the package path, or nil if
+// modules are not loaded or no module in the build list provides it.
+func packageModule(path string) *moduleInfo {
+	if m := pkgModules[path]; m != nil {
+		if mm := modules[m.Path]; mm != nil {
+			return mm
+		}
+		return m
+	}
+	if modules == nil {
+		return nil
+	}
+	return modules[moduleFor(path, modulePaths)]
+}
+
+// resolvedVersion returns the version of m that is actually used, taking
+// replacements into account. It is empty for the main module and for
+// modules replaced by a directory.
+func (m *moduleInfo) resolvedVersion() string {
+	if m.Replace != nil {
+		return m.Replace.Version
+	}
+	return m.Version
+}
+
+// versionedLabel returns the label of the package path, with the resolved
+// version of its module appended for packages outside the main module.
+func versionedLabel(path string) string {
+	m := packageModule(path)
+	if m == nil || m.Main {
+		return path
+	}
+	if v := m.resolvedVersion(); v != "" {
+		return path + "@" + v
+	}
+	return path
+}
+
+// replacement describes what the module m is replaced with, or returns the
+// empty string if it is not replaced.
+func (m *moduleInfo) replacement() string {
+	if m.Replace == nil {
+		return ""
+	}
+	if m.Replace.Version == "" {
+		return m.Replace.Path
+	}
+	return m.Replace.Path + " " + m.Replace.Version
+}
+
+// decorateReplaced annotates packages of replaced modules with their
+// replacement. Modules replaced by a local directory are also dashed.
+func decorateReplaced(a *attrs, path string) {
+	m := packageModule(path)
+	if m == nil || m.Replace == nil {
+		return
+	}
+	if a.get("fillcolor") == "" {
+		a.set("fillcolor", a.get("color"))
+	}
+	a.set("color", "purple")
+	a.set("penwidth", "2")
+	if m.Replace.Version == "" {
+		a.addStyle("dashed")
+	}
+	a.appendAttr("label", "=> "+m.replacement(), `\n`)
+}
+
+// isPseudoVersion reports whether v is a pseudo-version referring to an
+// untagged commit.
+func isPseudoVersion(v string) bool {
+	return pseudoVersionRe.MatchString(v)
+}
+
+// decoratePseudo marks packages of modules resolved to a pseudo-version.
+func decoratePseudo(a *attrs, path string) {
+	m := packageModule(path)
+	if m == nil || m.Main || !isPseudoVersion(m.resolvedVersion()) {
+		return
+	}
+	a.addStyle("bold")
+	a.set("fontcolor", "darkorange3")
+	a.appendAttr("tooltip", "pseudo-version "+m.resolvedVersion(), `\n`)
+}
+
+// reportPseudo writes the modules of the scanned packages that are resolved
+// to a pseudo-version to w.
+func reportPseudo(w io.Writer, pkgKeys []string) {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, name := range pkgKeys {
+		m := packageModule(name)
+		if m == nil || m.Main || seen[m.Path] || isIgnored(pkgs[name]) {
+			continue
+		}
+		seen[m.Path] = true
+		if v := m.resolvedVersion(); isPseudoVersion(v) {
+			lines = append(lines, "\t"+m.Path+" "+v)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(w, "modules pinned to pseudo-versions:")
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// discoverModules returns the directories below root, including root
+// itself, that contain a go.mod file. Like the go tool, it skips vendor and
+// testdata directories and those starting with . or _.
+func discoverModules(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			name := fi.Name()
+			if path != root && (name == "vendor" || name == "testdata" || name == "node_modules" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.Name() == "go.mod" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs, err
+}
+
+// loadMonorepo scans every module found below dir, resolving the packages of
+// each module in the context of its own go.mod, and loads the module
+// information and requirement graph of all of them. It returns the root
+// packages of all modules.
+func loadMonorepo(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	modDirs, err := discoverModules(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := buildContext.Dir
+	defer func() { buildContext.Dir = saved }()
+
+	var roots []string
+	for _, modDir := range modDirs {
+		buildContext.Dir = modDir
+		modRoots, err := expandRoots(modDir, []string{modDir + string(filepath.Separator) + "..."})
+		if err != nil {
+			// A module without packages, e.g. one holding only tools.
+			debugf("skipping module in %s: %s\n", modDir, err)
+			continue
+		}
+		if err := processPackages(modDir, modRoots); err != nil {
+			return nil, err
+		}
+		roots = append(roots, modRoots...)
+
+		if err := loadModules(modDir); err != nil {
+			return nil, err
+		}
+		reqs, err := loadModGraph(modDir)
+		if err != nil {
+			return nil, err
+		}
+		modReqs = append(modReqs, reqs...)
+	}
+	return roots, nil
+}
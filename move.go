@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// A moveRule moves the package From, and the packages below it, to To.
+type moveRule struct {
+	From, To string
+}
+
+// moveRules are the -move rules, of which the first matching a package
+// moves it.
+var moveRules []moveRule
+
+// moveProblems holds, with -move, why each drawn edge of the moved graph
+// that would break the build is flagged, by importer and import.
+var moveProblems map[[2]string]string
+
+// parseMoveRule parses a -move rule, old=>new.
+func parseMoveRule(s string) (moveRule, error) {
+	i := strings.Index(s, "=>")
+	if i < 0 {
+		return moveRule{}, fmt.Errorf("malformed rule %q, want old=>new", s)
+	}
+	r := moveRule{strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+2:])}
+	if r.From == "" || r.To == "" {
+		return moveRule{}, fmt.Errorf("malformed rule %q, want old=>new", s)
+	}
+	return r, nil
+}
+
+// movedPath returns the import path of the package path after the moves.
+func movedPath(path string) string {
+	for _, r := range moveRules {
+		if hasPathPrefix(path, r.From) {
+			return r.To + path[len(r.From):]
+		}
+	}
+	return path
+}
+
+// moveGraph renames the packages of the graph after the -move rules and
+// returns roots renamed alike, recording in moveProblems the edges that
+// would be part of an import cycle none of the imports they replace were
+// part of, and those that would import an internal package from outside the
+// tree it is visible to. Packages moved to the same path merge into one
+// node, with the imports of all of them.
+func moveGraph(roots []string) []string {
+	var keys []string
+	for name := range pkgs {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	for _, r := range moveRules {
+		found := false
+		for _, name := range keys {
+			if hasPathPrefix(name, r.From) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Fatalf("-move package %s is not in the graph", r.From)
+		}
+	}
+	wasCycle := make(map[[2]string]bool)
+	for _, comp := range strongComponents(keys) {
+		in := make(map[string]bool)
+		for _, name := range comp {
+			in[name] = true
+		}
+		for _, name := range comp {
+			for _, imp := range edgeImports(pkgs[name]) {
+				if in[imp] {
+					wasCycle[[2]string{movedPath(name), movedPath(imp)}] = true
+				}
+			}
+		}
+	}
+
+	roots = mergePackages(movedPath, roots, false)
+	keys = keys[:0]
+	for name := range pkgs {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	moveProblems = make(map[[2]string]string)
+	for _, comp := range strongComponents(keys) {
+		in := make(map[string]bool)
+		for _, name := range comp {
+			in[name] = true
+		}
+		for _, name := range comp {
+			for _, imp := range edgeImports(pkgs[name]) {
+				if e := [2]string{name, imp}; in[imp] && !wasCycle[e] {
+					moveProblems[e] = "would create an import cycle"
+				}
+			}
+		}
+	}
+	for _, name := range keys {
+		if isIgnored(pkgs[name]) {
+			continue
+		}
+		for _, imp := range edgeImports(pkgs[name]) {
+			if !internalVisible(name, imp) {
+				moveProblems[[2]string{name, imp}] = "would import internal package " + imp + " from outside its tree"
+			}
+		}
+	}
+	return roots
+}
+
+// internalVisible reports whether pkg may import imp, which it may unless
+// imp is internal and pkg is outside the tree rooted at the parent of the
+// last internal element of imp. The top-level internal packages of the
+// standard library, and those it vendors, are visible to it only.
+func internalVisible(pkg, imp string) bool {
+	if n := pkgs[imp]; n != nil && isGorootVendored(n) {
+		return isGorootPath(pkg)
+	}
+	if !isInternal(imp) {
+		return true
+	}
+	elems := strings.Split(imp, "/")
+	i := len(elems) - 1
+	for elems[i] != "internal" {
+		i--
+	}
+	if i == 0 {
+		return isGorootPath(pkg)
+	}
+	parent := strings.Join(elems[:i], "/")
+	return hasPathPrefix(pkg, parent)
+}
+
+// checkMoves reports the drawn edges flagged by moveGraph.
+func checkMoves(pkgKeys []string) []violation {
+	var vs []violation
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			if reason := moveProblems[[2]string{name, imp}]; reason != "" {
+				vs = append(vs, violation{"move", fmt.Sprintf("%s -> %s\t%s", name, imp, reason)})
+			}
+		}
+	}
+	return vs
+}
+
+// reportMoves writes the edges flagged by moveGraph to w and returns their
+// number.
+func reportMoves(w io.Writer, pkgKeys []string) int {
+	vs := checkMoves(pkgKeys)
+	for _, v := range vs {
+		fmt.Fprintf(w, "moved import: %s\n", strings.Replace(v.Detail, "\t", " ", 1))
+	}
+	return len(vs)
+}
@@ -0,0 +1,118 @@
+//go:build neo4j
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// neo4jBuilt reports whether -neo4j is supported by this build.
+const neo4jBuilt = true
+
+// neo4jBatch is the number of packages or imports merged by a statement.
+const neo4jBatch = 1000
+
+// loadNeo4j loads g into the Neo4j database at the URL target over Bolt,
+// in batches of statements merging on import paths as those of -format
+// cypher do, so that loading a graph again updates it. The user and the
+// password are those of the URL, or neo4j and $NEO4J_PASSWORD, and its path
+// names the database, the default one of the server if empty. Every node
+// and relationship merged is stamped with the time of the load, and the
+// imports of the packages of g that it no longer has are deleted, keeping
+// the database current when loaded on a schedule.
+func loadNeo4j(target string, g *graph.Graph) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	user, password := "neo4j", os.Getenv("NEO4J_PASSWORD")
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+	}
+	database := strings.Trim(u.Path, "/")
+	u.User, u.Path = nil, ""
+
+	ctx := context.Background()
+	driver, err := neo4j.NewDriverWithContext(u.String(), neo4j.BasicAuth(user, password, ""))
+	if err != nil {
+		return err
+	}
+	defer driver.Close(ctx)
+	session := driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite, DatabaseName: database})
+	defer session.Close(ctx)
+
+	run := func(query string, params map[string]interface{}) error {
+		_, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (interface{}, error) {
+			res, err := tx.Run(ctx, query, params)
+			if err != nil {
+				return nil, err
+			}
+			return res.Consume(ctx)
+		})
+		return err
+	}
+	if err := run("CREATE CONSTRAINT package_import_path IF NOT EXISTS FOR (p:Package) REQUIRE p.importPath IS UNIQUE", nil); err != nil {
+		return err
+	}
+
+	stamp := time.Now().UTC().Format(time.RFC3339)
+	var rows []interface{}
+	var paths []interface{}
+	for _, p := range g.Packages {
+		rows = append(rows, map[string]interface{}{"importPath": p.ImportPath, "props": render.PackageProperties(p)})
+		paths = append(paths, p.ImportPath)
+	}
+	if err := batches(rows, func(rows []interface{}) error {
+		return run("UNWIND $rows AS row MERGE (p:Package {importPath: row.importPath}) SET p += row.props, p.updated = $stamp", map[string]interface{}{"rows": rows, "stamp": stamp})
+	}); err != nil {
+		return err
+	}
+
+	// Relationship types can't be parameters, so the edges are merged by
+	// type.
+	byType := make(map[string][]interface{})
+	for _, e := range g.Edges {
+		rel := render.EdgeRelationship(e)
+		byType[rel] = append(byType[rel], map[string]interface{}{"from": e.From, "to": e.To, "props": render.EdgeProperties(e)})
+	}
+	for rel, rows := range byType {
+		query := fmt.Sprintf("UNWIND $rows AS row MATCH (a:Package {importPath: row.from}), (b:Package {importPath: row.to}) MERGE (a)-[r:%s]->(b) SET r += row.props, r.updated = $stamp", rel)
+		if err := batches(rows, func(rows []interface{}) error {
+			return run(query, map[string]interface{}{"rows": rows, "stamp": stamp})
+		}); err != nil {
+			return err
+		}
+	}
+	return batches(paths, func(paths []interface{}) error {
+		return run("MATCH (a:Package)-[r]->(:Package) WHERE a.importPath IN $paths AND r.updated <> $stamp DELETE r", map[string]interface{}{"paths": paths, "stamp": stamp})
+	})
+}
+
+// batches calls f with the successive slices of rows of at most neo4jBatch
+// rows.
+func batches(rows []interface{}, f func(rows []interface{}) error) error {
+	for len(rows) > 0 {
+		n := neo4jBatch
+		if n > len(rows) {
+			n = len(rows)
+		}
+		if err := f(rows[:n]); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
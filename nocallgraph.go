@@ -0,0 +1,14 @@
+//go:build !callgraph
+
+package main
+
+import "log"
+
+// callGraphBuilt reports whether -callgraph is supported by this build,
+// which it is with the callgraph build tag. It is left out by default for
+// its dependency on golang.org/x/tools.
+const callGraphBuilt = false
+
+func writeCallGraph(cwd string, roots []string) {
+	log.Fatal("-callgraph needs godepgraph built with -tags callgraph")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+)
+
+// A node is what godepgraph keeps of a loaded package. The loaders return
+// whole build.Packages, with every list of files and every import position,
+// of which newNode copies only what the drawing and the analyses read, so
+// that none of them are retained for the length of the run. The fields are
+// named after those of build.Package they come from.
+type node struct {
+	ImportPath string
+	Dir        string
+	Root       string
+	Goroot     bool
+	// ImportComment is the path of the import comment of the package
+	// clause, if any.
+	ImportComment string
+	// Doc is the synopsis of the package comment, only kept with -doc.
+	Doc string
+	// XTest is set for the external test packages drawn with -xtest.
+	XTest bool
+	// CgoFiles and SFiles are the numbers of cgo and assembly files.
+	CgoFiles int
+	SFiles   int
+	// Imports are the distinct imports of the package but itself, including
+	// those of its tests with -t.
+	Imports []string
+	// GoFiles are the names of the Go files of the package, cgo files
+	// included, followed with -t by those of its tests, of which there are
+	// TestGoFiles. They are only kept for the flags parsing the source.
+	GoFiles     []string
+	TestGoFiles int
+	// TestImports are those of Imports only its tests make, and
+	// SharedTestImports those both its tests and its other files make, with
+	// -t.
+	TestImports       []string
+	SharedTestImports []string
+	// ToolImports are those of Imports only its tools.go files make, with
+	// -tools mark.
+	ToolImports []string
+	// EmbedPatterns are the patterns of the //go:embed directives of the
+	// non-test files of the package, only kept with -embed.
+	EmbedPatterns []string
+	// ImportPos holds the positions of the import specs behind each of
+	// Imports, only with -positions.
+	ImportPos map[string][]token.Position
+}
+
+// internedPaths holds one copy of every import path of the graph, which
+// recur in the imports of many packages.
+var internedPaths = make(map[string]string)
+
+func intern(path string) string {
+	if s, ok := internedPaths[path]; ok {
+		return s
+	}
+	internedPaths[path] = path
+	return path
+}
+
+// needFiles reports whether a flag parses the source files of packages.
+func needFiles() bool {
+	return *showAliases || *markBlank || *markGenerated || *showSymbols || *typeGraph || *showImplements || *heaviestDeps > 0 || *edgeCountsFlag || *affectedTests
+}
+
+// internalTests reports whether the test files of the packages themselves
+// are included, with -t but -tests external.
+func internalTests() bool {
+	return *includeTests && *testKinds != "external"
+}
+
+// externalTests reports whether the external test packages are included,
+// with -t but -tests internal.
+func externalTests() bool {
+	return *includeTests && *testKinds != "internal"
+}
+
+// newNode returns the node of pkg.
+func newNode(pkg *build.Package) *node {
+	n := &node{
+		ImportPath: intern(pkg.ImportPath),
+		Dir:        pkg.Dir,
+		Root:       pkg.Root,
+		Goroot:     pkg.Goroot,
+		CgoFiles:   len(pkg.CgoFiles),
+		SFiles:     len(pkg.SFiles),
+
+		ImportComment: pkg.ImportComment,
+	}
+	// With -xtest the external tests are a node of their own.
+	xtests := externalTests() && !*splitXTests
+	all := pkg.Imports
+	if internalTests() {
+		all = append(all[:len(all):len(all)], pkg.TestImports...)
+	}
+	if xtests {
+		all = append(all, pkg.XTestImports...)
+	}
+	n.setImports(all)
+
+	if needFiles() {
+		n.GoFiles = append(append([]string{}, pkg.GoFiles...), pkg.CgoFiles...)
+		if internalTests() {
+			n.GoFiles = append(n.GoFiles, pkg.TestGoFiles...)
+			n.TestGoFiles = len(pkg.TestGoFiles)
+		}
+		if xtests {
+			n.GoFiles = append(n.GoFiles, pkg.XTestGoFiles...)
+			n.TestGoFiles += len(pkg.XTestGoFiles)
+		}
+	}
+	if *showDoc {
+		n.Doc = pkg.Doc
+	}
+	if *showEmbeds {
+		n.EmbedPatterns = pkg.EmbedPatterns
+	}
+	if *showPositions {
+		n.ImportPos = make(map[string][]token.Position)
+		for _, imp := range n.Imports {
+			pos := pkg.ImportPos[imp]
+			if internalTests() {
+				pos = append(pos[:len(pos):len(pos)], pkg.TestImportPos[imp]...)
+			}
+			if xtests {
+				pos = append(pos[:len(pos):len(pos)], pkg.XTestImportPos[imp]...)
+			}
+			n.ImportPos[imp] = pos
+		}
+	}
+	return n
+}
+
+// newXTestNode returns, with -t and -xtest, the node of the external test
+// package of pkg, foo_test for foo, importing foo among its other imports,
+// or nil if pkg has no external tests.
+func newXTestNode(pkg *build.Package) *node {
+	if !externalTests() || !*splitXTests || len(pkg.XTestGoFiles) == 0 {
+		return nil
+	}
+	n := &node{
+		ImportPath: intern(pkg.ImportPath + "_test"),
+		Dir:        pkg.Dir,
+		Root:       pkg.Root,
+		Goroot:     pkg.Goroot,
+		XTest:      true,
+	}
+	n.setImports(pkg.XTestImports)
+	if needFiles() {
+		n.GoFiles = append([]string{}, pkg.XTestGoFiles...)
+		n.TestGoFiles = len(pkg.XTestGoFiles)
+	}
+	if *showPositions {
+		n.ImportPos = make(map[string][]token.Position)
+		for _, imp := range n.Imports {
+			n.ImportPos[imp] = pkg.XTestImportPos[imp]
+		}
+	}
+	return n
+}
+
+// setImports sets the imports of n to the distinct ones of all.
+func (n *node) setImports(all []string) {
+	found := make(map[string]bool)
+	for _, imp := range all {
+		// Don't draw a self-reference when foo_test depends on foo.
+		if imp == n.ImportPath || found[imp] {
+			continue
+		}
+		found[imp] = true
+		n.Imports = append(n.Imports, intern(imp))
+	}
+}
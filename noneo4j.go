@@ -0,0 +1,19 @@
+//go:build !neo4j
+
+package main
+
+import (
+	"log"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// neo4jBuilt reports whether -neo4j is supported by this build, which it is
+// with the neo4j build tag. It is left out by default for its dependency on
+// the Neo4j driver.
+const neo4jBuilt = false
+
+func loadNeo4j(target string, g *graph.Graph) error {
+	log.Fatal("-neo4j needs godepgraph built with -tags neo4j")
+	return nil
+}
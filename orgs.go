@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// packageOrg returns the hosting organization of the package path, by
+// which -orgs clusters it: the host and the first element after it on the
+// code hosts, as in github.com/aws, golang.org/x for the x repositories and
+// the host alone elsewhere, as in k8s.io.
+func packageOrg(path string) string {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) >= 2 && codeHosts[parts[0]]:
+		return parts[0] + "/" + parts[1]
+	case len(parts) >= 2 && parts[0] == "golang.org" && parts[1] == "x":
+		return "golang.org/x"
+	}
+	return parts[0]
+}
+
+// writeOrgClusters draws, with -orgs, the external packages of every
+// hosting organization in a cluster of their own, labeled with the
+// organization and the number of its packages.
+func writeOrgClusters(w io.Writer, pkgKeys []string) {
+	members := make(map[string][]string)
+	var orgs []string
+	for _, name := range externalPackages(pkgKeys, rootPaths) {
+		org := packageOrg(name)
+		if members[org] == nil {
+			orgs = append(orgs, org)
+		}
+		members[org] = append(members[org], name)
+	}
+	sort.Strings(orgs)
+
+	for i, org := range orgs {
+		label := fmt.Sprintf("%s (%d packages)", org, len(members[org]))
+		if len(members[org]) == 1 {
+			label = org + " (1 package)"
+		}
+		fmt.Fprintf(w, "subgraph cluster_org_%d {\n", i)
+		fmt.Fprintf(w, "graph [%s];\n", attrs{{"label", label}, {"style", "rounded,filled"}, {"color", "gray50"}, {"fillcolor", "gray97"}})
+		for _, name := range members[org] {
+			fmt.Fprintf(w, "_%d;\n", getId(name))
+		}
+		fmt.Fprintln(w, "}")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// modulesVendored is set when the modules were read from vendor/modules.txt.
+var modulesVendored bool
+
+// moduleOrigin returns where the go command resolves the module m from:
+// vendor, replace for a replacement by a directory, direct for version
+// control, or the URL of the first proxy of GOPROXY, and whether its
+// contents are checked against the checksum database. Only the environment
+// is consulted, so a module a proxy fails to serve and the go command then
+// fetches from the next entry of GOPROXY is still given the first proxy.
+func moduleOrigin(m *moduleInfo) (origin string, checked bool) {
+	path := m.Path
+	switch {
+	case modulesVendored:
+		return "vendor", false
+	case m.Replace != nil && m.Replace.Version == "":
+		return "replace", false
+	case m.Replace != nil:
+		path = m.Replace.Path
+	}
+	checked = goEnvVar("GOSUMDB") != "off" && !matchGlobs(goEnvVar("GONOSUMDB"), path)
+	if matchGlobs(goEnvVar("GONOPROXY"), path) {
+		return "direct", checked
+	}
+	proxy := goEnvVar("GOPROXY")
+	if i := strings.IndexAny(proxy, ",|"); i >= 0 {
+		proxy = proxy[:i]
+	}
+	if proxy == "" {
+		proxy = "https://proxy.golang.org"
+	}
+	return proxy, checked
+}
+
+// decorateOrigin marks the packages of the modules resolved without a proxy
+// with their origin, and draws those of the modules not checked against
+// the checksum database with diagonals.
+func decorateOrigin(a *attrs, path string) {
+	m := packageModule(path)
+	if m == nil || m.Main {
+		return
+	}
+	origin, checked := moduleOrigin(m)
+	if !strings.Contains(origin, "://") {
+		a.appendAttr("label", "["+origin+"]", `\n`)
+	}
+	if !checked {
+		a.addStyle("diagonals")
+		a.appendAttr("tooltip", "not checked against the checksum database", `\n`)
+	}
+}
+
+// reportOrigins writes the modules of the scanned packages to w with where
+// they are resolved from, the proxy or otherwise, and whether they are
+// checked against the checksum database.
+func reportOrigins(w io.Writer, pkgKeys []string) {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, name := range pkgKeys {
+		m := packageModule(name)
+		if m == nil || m.Main || seen[m.Path] || isIgnored(pkgs[name]) {
+			continue
+		}
+		seen[m.Path] = true
+		origin, checked := moduleOrigin(m)
+		if !checked {
+			origin += ", not checked against the checksum database"
+		}
+		lines = append(lines, fmt.Sprintf("\t%s %s (%s)", m.Path, m.resolvedVersion(), origin))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(w, "module origins:")
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
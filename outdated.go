@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var semverRe = regexp.MustCompile(`^v([0-9]+)\.([0-9]+)\.([0-9]+)`)
+
+// outdatedColors are the fills of the packages of modules a major, minor or
+// patch version behind with -outdated.
+var outdatedColors = map[string]string{
+	"major": "tomato",
+	"minor": "orange",
+	"patch": "khaki",
+}
+
+// lookupMajors asks the module proxy, through the go command run in dir, for
+// the latest versions of the major versions above those of the modules ms,
+// which go list -m -u doesn't report since every major version is a module
+// of its own, and records the highest of each module as its Major. The
+// modules of the next major versions are looked up together, as long as any
+// is found.
+func lookupMajors(dir string, ms []*moduleInfo) error {
+	next := make(map[string]*moduleInfo)
+	for _, m := range ms {
+		if m.Main || m.Replace != nil && m.Replace.Version == "" {
+			continue
+		}
+		if p := nextMajorPath(m.Path); p != "" {
+			next[p] = m
+		}
+	}
+	for len(next) > 0 {
+		var queries []string
+		for p := range next {
+			queries = append(queries, p+"@latest")
+		}
+		sort.Strings(queries)
+		cmd := exec.Command(goCmd, append([]string{"list", "-m", "-json", "-e"}, queries...)...)
+		cmd.Dir = dir
+		cmd.Env = goEnv()
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("go list -m: %s: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		found := make(map[string]*moduleInfo)
+		dec := json.NewDecoder(bytes.NewReader(out))
+		for {
+			var latest struct {
+				moduleInfo
+				Error *struct{ Err string }
+			}
+			if err := dec.Decode(&latest); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("go list -m: %s", err)
+			}
+			m := next[latest.Path]
+			if m == nil || latest.Error != nil || latest.Version == "" {
+				continue
+			}
+			major := latest.moduleInfo
+			m.Major = &major
+			if p := nextMajorPath(latest.Path); p != "" {
+				found[p] = m
+			}
+		}
+		next = found
+	}
+	return nil
+}
+
+// nextMajorPath returns the path of the module of the major version after
+// that of the module path, github.com/foo/bar/v3 for github.com/foo/bar/v2
+// and gopkg.in/yaml.v3 for gopkg.in/yaml.v2, if there is one.
+func nextMajorPath(path string) string {
+	base, major := majorVersion(path)
+	n, err := strconv.Atoi(strings.TrimPrefix(major, "v"))
+	if err != nil {
+		return ""
+	}
+	if n < 2 {
+		n = 1
+	}
+	if strings.HasPrefix(base, "gopkg.in/") {
+		return fmt.Sprintf("%s.v%d", base, n+1)
+	}
+	return fmt.Sprintf("%s/v%d", base, n+1)
+}
+
+// outdatedLevel returns how far behind the latest version of its module the
+// version of m is, with -outdated: "major" if a later major version has been
+// released, whether of the same module, as v1 is of v0, or of another one
+// with a /vN suffix, "minor" if a later minor version has, "patch" if only
+// another patch or prerelease has, and an empty string if none has.
+func outdatedLevel(m *moduleInfo) string {
+	if m == nil || m.Main {
+		return ""
+	}
+	if m.Replace != nil && m.Replace.Version == "" {
+		return ""
+	}
+	// The later major versions of v2 and above are modules of other paths,
+	// with another /vN suffix.
+	if m.Major != nil {
+		return "major"
+	}
+	if m.Update == nil {
+		return ""
+	}
+	return versionLevel(m.resolvedVersion(), m.Update.Version)
+}
+
+// versionLevel returns which part of the semantic version used the later
+// version latest changes first: "major", "minor" or "patch", which stands
+// for prereleases, pseudo-versions and versions that aren't semantic too.
+func versionLevel(used, latest string) string {
+	u, l := semverRe.FindStringSubmatch(used), semverRe.FindStringSubmatch(latest)
+	switch {
+	case u == nil || l == nil:
+		return "patch"
+	case u[1] != l[1]:
+		return "major"
+	case u[2] != l[2]:
+		return "minor"
+	}
+	return "patch"
+}
+
+// staleness describes how far behind the latest version of its module the
+// version of m is, as the go command learns from the module proxy with
+// -outdated: the release dates of both, the age of the version and the time
+// between them, and the latest major version above. It is empty for modules
+// that are up to date, main or replaced by directories.
+func staleness(m *moduleInfo) string {
+	if outdatedLevel(m) == "" {
+		return ""
+	}
+	version, released := m.Version, m.Time
+	if m.Replace != nil {
+		if m.Replace.Version == "" {
+			return ""
+		}
+		version, released = m.Replace.Version, m.Replace.Time
+	}
+	s := version + " " + releaseDate(released)
+	if released != nil {
+		s += fmt.Sprintf(" (%d days old)", int(time.Since(*released).Hours()/24))
+	}
+	if m.Update != nil {
+		s += fmt.Sprintf(", latest %s %s", m.Update.Version, releaseDate(m.Update.Time))
+		if released != nil && m.Update.Time != nil {
+			s += fmt.Sprintf(", %d days behind", int(m.Update.Time.Sub(*released).Hours()/24))
+		}
+	}
+	if m.Major != nil {
+		s += fmt.Sprintf(", latest major version %s %s %s", m.Major.Path, m.Major.Version, releaseDate(m.Major.Time))
+	}
+	return s
+}
+
+// releaseDate formats the release time t of a version, which may be
+// unknown.
+func releaseDate(t *time.Time) string {
+	if t == nil {
+		return "of unknown date"
+	}
+	return "of " + t.Format("2006-01-02")
+}
+
+// decorateOutdated marks the packages of modules with a newer version
+// available, filled by how far behind it they are, with the staleness of the
+// version as tooltip.
+func decorateOutdated(a *attrs, path string) {
+	m := packageModule(path)
+	level := outdatedLevel(m)
+	if level == "" {
+		return
+	}
+	a.set("peripheries", "2")
+	a.set("fontcolor", "chocolate4")
+	a.set("fillcolor", outdatedColors[level])
+	a.appendAttr("tooltip", level+" behind: "+staleness(m), `\n`)
+}
+
+// reportOutdated writes the modules of the scanned packages with a newer
+// version available, grouped by how far behind they are, and how old their
+// versions are, to w.
+func reportOutdated(w io.Writer, pkgKeys []string) {
+	seen := make(map[string]bool)
+	lines := make(map[string][]string)
+	for _, name := range pkgKeys {
+		m := packageModule(name)
+		if m == nil || seen[m.Path] || isIgnored(pkgs[name]) {
+			continue
+		}
+		seen[m.Path] = true
+		if level := outdatedLevel(m); level != "" {
+			lines[level] = append(lines[level], "\t"+m.Path+": "+staleness(m))
+		}
+	}
+	for _, level := range []string{"major", "minor", "patch"} {
+		if len(lines[level]) == 0 {
+			continue
+		}
+		sort.Strings(lines[level])
+		fmt.Fprintf(w, "modules a %s version behind:\n", level)
+		for _, l := range lines[level] {
+			fmt.Fprintln(w, l)
+		}
+	}
+}
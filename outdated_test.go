@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestOutdatedLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *moduleInfo
+		want string
+	}{
+		{"up to date", &moduleInfo{Path: "example.org/a", Version: "v1.2.3"}, ""},
+		{"main", &moduleInfo{Path: "example.org/a", Main: true, Update: &moduleInfo{Version: "v1.3.0"}}, ""},
+		{"patch", &moduleInfo{Path: "example.org/a", Version: "v1.2.3", Update: &moduleInfo{Version: "v1.2.4"}}, "patch"},
+		{"prerelease", &moduleInfo{Path: "example.org/a", Version: "v1.2.3", Update: &moduleInfo{Version: "v1.2.4-rc.1"}}, "patch"},
+		{"pseudo-version", &moduleInfo{Path: "example.org/a", Version: "v0.0.0-20200101000000-abcdefabcdef", Update: &moduleInfo{Version: "v0.0.0-20210101000000-abcdefabcdef"}}, "patch"},
+		{"minor", &moduleInfo{Path: "example.org/a", Version: "v1.2.3", Update: &moduleInfo{Version: "v1.3.0"}}, "minor"},
+		{"v0 minor", &moduleInfo{Path: "example.org/a", Version: "v0.9.1", Update: &moduleInfo{Version: "v0.10.0"}}, "minor"},
+		{"v0 to v1", &moduleInfo{Path: "example.org/a", Version: "v0.9.1", Update: &moduleInfo{Version: "v1.0.0"}}, "major"},
+		{"v0 to v1 minor", &moduleInfo{Path: "example.org/a", Version: "v0.9.1", Update: &moduleInfo{Version: "v1.9.0"}}, "major"},
+		{"major suffix", &moduleInfo{Path: "example.org/a/v2", Version: "v2.1.0", Major: &moduleInfo{Path: "example.org/a/v3", Version: "v3.0.0"}}, "major"},
+		{"major suffix and patch", &moduleInfo{Path: "example.org/a", Version: "v1.2.3", Update: &moduleInfo{Version: "v1.2.4"}, Major: &moduleInfo{Path: "example.org/a/v2", Version: "v2.0.0"}}, "major"},
+		{"replaced", &moduleInfo{Path: "example.org/a", Version: "v1.0.0", Replace: &moduleInfo{Path: "example.org/b", Version: "v1.2.3"}, Update: &moduleInfo{Version: "v1.3.0"}}, "minor"},
+		{"replaced by directory", &moduleInfo{Path: "example.org/a", Version: "v1.0.0", Replace: &moduleInfo{Dir: "../a"}, Update: &moduleInfo{Version: "v1.3.0"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := outdatedLevel(tt.m); got != tt.want {
+			t.Errorf("%s: outdatedLevel = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextMajorPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"example.org/a":    "example.org/a/v2",
+		"example.org/a/v2": "example.org/a/v3",
+		"gopkg.in/yaml.v2": "gopkg.in/yaml.v3",
+	} {
+		if got := nextMajorPath(path); got != want {
+			t.Errorf("nextMajorPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
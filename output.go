@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/render"
+)
+
+// builtinFormats are the output formats written from the loaded packages
+// rather than through package render, which has its own json.
+var builtinFormats = []string{"json", "snapshot", "hash", "list", "count", "metrics-json"}
+
+func isBuiltinFormat(name string) bool {
+	for _, f := range builtinFormats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isOutputFormat(name string) bool {
+	return isBuiltinFormat(name) || render.Lookup(name) != nil
+}
+
+// outputFormats returns the sorted values of -format, the built-in formats
+// and those registered with package render.
+func outputFormats() []string {
+	formats := append([]string{}, builtinFormats...)
+	for _, name := range render.Formats() {
+		if !isBuiltinFormat(name) {
+			formats = append(formats, name)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// renderedFormats are the extensions of -o files rendered from dot output by
+// Graphviz.
+var renderedFormats = map[string]bool{".svg": true, ".png": true, ".pdf": true}
+
+// An output is where the graph is written: stdout or, with -o, a file that
+// is replaced once the output is complete.
+type output struct {
+	io.Writer
+	path string
+	buf  bytes.Buffer
+}
+
+func newOutput(path string) *output {
+	o := &output{path: path}
+	if path == "" {
+		o.Writer = os.Stdout
+	} else {
+		o.Writer = &o.buf
+	}
+	return o
+}
+
+// close writes the output to its file, rendering dot output first if the
+// file is an image. The file is replaced by renaming, so that viewers never
+// read it half written.
+func (o *output) close() error {
+	if o.path == "" {
+		return nil
+	}
+	data := o.buf.Bytes()
+	if ext := strings.ToLower(filepath.Ext(o.path)); renderedFormats[ext] && *outputFormat == "dot" {
+		cmd := exec.Command("dot", "-T"+ext[1:])
+		cmd.Stdin = bytes.NewReader(data)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("dot: %s: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		data = out
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(o.path), "."+filepath.Base(o.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), o.path)
+}
@@ -0,0 +1,96 @@
+// Package pattern matches import paths against the patterns of godepgraph's
+// flags: import path prefixes, or globs in which * matches within a path
+// element and ... matches anything.
+package pattern
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A Pattern matches import paths by prefix or, if it is a glob, by pattern.
+type Pattern struct {
+	text string
+	re   *regexp.Regexp
+}
+
+// Parse returns the pattern s.
+func Parse(s string) (Pattern, error) {
+	p := Pattern{text: s}
+	if IsGlob(s) {
+		re, err := Regexp(s)
+		if err != nil {
+			return p, err
+		}
+		p.re = re
+	}
+	return p, nil
+}
+
+// Match reports whether path matches p.
+func (p Pattern) Match(path string) bool {
+	if p.re != nil {
+		return p.re.MatchString(path)
+	}
+	return strings.HasPrefix(path, p.text)
+}
+
+func (p Pattern) String() string {
+	return p.text
+}
+
+// IsGlob reports whether the pattern p is a glob rather than a plain
+// import path or prefix.
+func IsGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[") || strings.Contains(p, "...")
+}
+
+// Regexp compiles the path glob pattern into a regular expression
+// matching whole import paths. As in path.Match, * matches any sequence of
+// characters within a path element, ? a single one and [...] a character
+// class, while ... matches any string, slashes included, as in go package
+// patterns. Like those, a trailing /... also matches the path before it, so
+// github.com/mycorp/*/internal/... matches every internal tree one level
+// below github.com/mycorp.
+func Regexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	rest := pattern
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "/...") && len(rest) == 4:
+			b.WriteString("(/.*)?")
+			rest = ""
+		case strings.HasPrefix(rest, "..."):
+			b.WriteString(".*")
+			rest = rest[3:]
+		case rest[0] == '*':
+			b.WriteString("[^/]*")
+			rest = rest[1:]
+		case rest[0] == '?':
+			b.WriteString("[^/]")
+			rest = rest[1:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("malformed glob %q: unterminated [", pattern)
+			}
+			class := rest[1:end]
+			if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			rest = rest[end+1:]
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("malformed glob %q: %s", pattern, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A pluginBuild is a plugin or shared library of -plugin, built apart from
+// its host: its root packages, resolved in the module of its directory, and
+// the modules of the build list of that module, by path.
+type pluginBuild struct {
+	Dir     string
+	Roots   []string
+	Modules map[string]*moduleInfo
+	Paths   []string
+}
+
+// plugins holds the builds of -plugin.
+var plugins []*pluginBuild
+
+// loadPlugins loads the packages of the -plugin directories next to those
+// of the host, each resolved in the context of its own go.mod as the
+// separate build of a plugin resolves them, and returns their roots.
+// Packages the host already loaded are shared with it.
+func loadPlugins() ([]string, error) {
+	saved := buildContext.Dir
+	defer func() { buildContext.Dir = saved }()
+
+	var roots []string
+	for _, dir := range pluginDirs.items() {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		p := &pluginBuild{Dir: dir}
+		base := strings.TrimSuffix(abs, string(filepath.Separator)+"...")
+		modDir := base
+		if gomod := findGoMod(base); gomod != "" {
+			modDir = filepath.Dir(gomod)
+			buildContext.Dir = modDir
+			ms, err := listModules(modDir, false)
+			if err != nil {
+				log.Printf("warning: can't compare the modules of plugin %s: %s", dir, err)
+			}
+			p.Modules = make(map[string]*moduleInfo)
+			for _, m := range ms {
+				p.Modules[m.Path] = m
+				p.Paths = append(p.Paths, m.Path)
+			}
+		}
+		if p.Roots, err = expandRoots(modDir, []string{abs}); err != nil {
+			return nil, fmt.Errorf("plugin %s: %s", dir, err)
+		}
+		if err := processPackages(modDir, p.Roots); err != nil {
+			return nil, fmt.Errorf("plugin %s: %s", dir, err)
+		}
+		plugins = append(plugins, p)
+		roots = append(roots, p.Roots...)
+	}
+	return roots, nil
+}
+
+// moduleBuild describes the module m as it is built: its path, and its
+// version or its replacement.
+func moduleBuild(m *moduleInfo) string {
+	switch {
+	case m.Replace != nil && m.Replace.Version != "":
+		return fmt.Sprintf("%s => %s %s", m.Path, m.Replace.Path, m.Replace.Version)
+	case m.Replace != nil:
+		return fmt.Sprintf("%s => %s", m.Path, m.Replace.Dir)
+	case m.Version != "":
+		return m.Path + " " + m.Version
+	}
+	return m.Path + " in " + m.Dir
+}
+
+// pluginMismatches returns the packages of pkgKeys both the host and a
+// plugin reach that the plugin builds from another version of its module
+// than the host, with how the two build it for every such plugin. The
+// runtime refuses to load a plugin built with a different version of a
+// package of its host.
+func pluginMismatches(pkgKeys []string) map[string][]string {
+	var hostRoots []string
+	fromPlugin := make(map[string]bool)
+	for _, p := range plugins {
+		for _, root := range p.Roots {
+			fromPlugin[root] = true
+		}
+	}
+	for _, root := range rootPaths {
+		if !fromPlugin[root] {
+			hostRoots = append(hostRoots, root)
+		}
+	}
+	host := reachable(hostRoots, drawnImports)
+
+	mismatches := make(map[string][]string)
+	for _, p := range plugins {
+		if p.Modules == nil {
+			continue
+		}
+		reach := reachable(p.Roots, drawnImports)
+		for _, name := range pkgKeys {
+			pkg := pkgs[name]
+			if !reach[name] || !host[name] || isIgnored(pkg) || pkg.Goroot {
+				continue
+			}
+			hm := packageModule(name)
+			pm := p.Modules[moduleFor(name, p.Paths)]
+			if hm == nil || pm == nil {
+				continue
+			}
+			if hb, pb := moduleBuild(hm), moduleBuild(pm); hb != pb {
+				mismatches[name] = append(mismatches[name], fmt.Sprintf("host %s, plugin %s %s", hb, p.Dir, pb))
+			}
+		}
+	}
+	return mismatches
+}
+
+// decoratePlugin marks the root packages of the plugins as tabs, and the
+// packages the host and a plugin build from different versions in salmon,
+// with both versions in their tooltips.
+func decoratePlugin(a *attrs, name string, mismatches map[string][]string) {
+	for _, p := range plugins {
+		if containsString(p.Roots, name) {
+			a.set("shape", "tab")
+			a.appendAttr("tooltip", "plugin "+p.Dir, `\n`)
+		}
+	}
+	if m := mismatches[name]; len(m) > 0 {
+		a.set("fillcolor", "salmon")
+		a.set("penwidth", "2")
+		for _, why := range m {
+			a.appendAttr("tooltip", dotEscape(why), `\n`)
+		}
+	}
+}
+
+// reportPlugins writes to w the packages the host and the plugins build
+// from different versions of their modules, and returns their number.
+func reportPlugins(w io.Writer, mismatches map[string][]string) int {
+	if len(mismatches) == 0 {
+		fmt.Fprintln(w, "the plugins build the packages they share with the host from the same module versions")
+		return 0
+	}
+	var names []string
+	for name := range mismatches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "packages the plugins and the host build from different versions, which fail plugin.Open:")
+	for _, name := range names {
+		for _, why := range mismatches[name] {
+			fmt.Fprintf(w, "\t%s: %s\n", name, why)
+		}
+	}
+	return len(names)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A portProfile describes a restricted target -portable checks the graph
+// against.
+type portProfile struct {
+	// Cgo and Asm are set for targets building cgo and Go assembly
+	// outside the standard library.
+	Cgo, Asm bool
+	// Unsupported are the packages of the standard library that don't
+	// work on the target, with those below them.
+	Unsupported []string
+}
+
+// portProfiles are the targets of -portable by name. The packages listed
+// are approximations of what the targets lack, which change with their
+// releases.
+var portProfiles = map[string]portProfile{
+	"nocgo": {Asm: true},
+	"js": {Asm: true, Unsupported: []string{
+		"net/http/cgi", "os/exec", "os/signal", "os/user", "plugin",
+	}},
+	"wasip1": {Asm: true, Unsupported: []string{
+		"net/http/cgi", "os/exec", "os/signal", "os/user", "plugin", "syscall/js",
+	}},
+	"tinygo": {Cgo: true, Unsupported: []string{
+		"net/http/cgi", "os/exec", "os/user", "plugin", "runtime/pprof", "runtime/trace",
+	}},
+}
+
+// portProfileNames returns the sorted names of portProfiles.
+func portProfileNames() []string {
+	var names []string
+	for name := range portProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reasons returns why pkg itself can't be built for the target of p.
+func (p portProfile) reasons(pkg *node) []string {
+	var rs []string
+	if pkg.CgoFiles > 0 && !p.Cgo {
+		rs = append(rs, "cgo")
+	}
+	if pkg.SFiles > 0 && !p.Asm && !pkg.Goroot {
+		rs = append(rs, "assembly")
+	}
+	if pkg.Goroot {
+		for _, prefix := range p.Unsupported {
+			if hasPathPrefix(pkg.ImportPath, prefix) {
+				rs = append(rs, "unsupported")
+				break
+			}
+		}
+	}
+	return rs
+}
+
+// unportable returns, with -portable, why every package that can't be
+// built for the target, itself or through its imports, can't be.
+func unportable() map[string][]string {
+	if *portableFor == "" {
+		return nil
+	}
+	return taint(portProfiles[*portableFor].reasons)
+}
+
+// decoratePortable colors a package that can't be built for the target of
+// -portable for the reasons given, light red if the package itself can't
+// be and pink if only its imports can't.
+func decoratePortable(a *attrs, pkg *node, reasons []string) {
+	if own := portProfiles[*portableFor].reasons(pkg); len(own) > 0 {
+		a.set("color", "lightcoral")
+		a.appendAttr("tooltip", fmt.Sprintf("can't be built for %s: %s", *portableFor, strings.Join(own, ", ")), `\n`)
+		return
+	}
+	a.set("color", "mistyrose")
+	a.appendAttr("tooltip", fmt.Sprintf("imports packages that can't be built for %s: %s", *portableFor, strings.Join(reasons, ", ")), `\n`)
+}
+
+// reportPortable writes to w the packages of pkgKeys that can't be built
+// for the target of -portable themselves, those to replace to port the
+// roots, with why and the chain of imports reaching them from a root.
+func reportPortable(w io.Writer, roots, pkgKeys []string) {
+	profile := portProfiles[*portableFor]
+	var lines []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		if rs := profile.reasons(pkg); len(rs) > 0 {
+			line := fmt.Sprintf("\t%s: %s", name, strings.Join(rs, ", "))
+			if chain := importChain(roots, name); len(chain) > 1 {
+				line += ", via " + strings.Join(chain, " -> ")
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintf(w, "every package can be built for %s\n", *portableFor)
+		return
+	}
+	fmt.Fprintf(w, "packages to replace to build for %s:\n", *portableFor)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// stopProfiles finishes the profiles started by startProfiles.
+var stopProfiles = func() {}
+
+// startProfiles starts the CPU profile of -cpuprofile, and sets
+// stopProfiles to stop it and write the heap profile of -memprofile.
+func startProfiles() {
+	var cpu *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatalf("failed to create CPU profile: %s", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatalf("failed to start CPU profile: %s", err)
+		}
+		cpu = f
+	}
+	stopProfiles = func() {
+		stopProfiles = func() {}
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				log.Printf("failed to write CPU profile: %s", err)
+			}
+		}
+		if *memProfile != "" {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				log.Printf("failed to create memory profile: %s", err)
+				return
+			}
+			// Profile what is live at the end of the run.
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Printf("failed to write memory profile: %s", err)
+			}
+			f.Close()
+		}
+	}
+}
+
+// exit finishes the profiles, reports the -stats and exits with code.
+func exit(code int) {
+	stopProfiles()
+	reportStats(os.Stderr)
+	os.Exit(code)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// progressInterval limits how often the progress line is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+var (
+	progressTerminal = isTerminal(os.Stderr)
+	progressShown    bool
+	lastProgress     time.Time
+)
+
+// isTerminal reports whether f is a character device, such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progress reports that the package name is being imported: with -v as a
+// log line, and otherwise, when stderr is a terminal, by redrawing a line
+// with the number of packages resolved so far.
+func progress(name string) {
+	if *verbose {
+		debugf("importing %s\n", name)
+		return
+	}
+	if !progressTerminal || time.Since(lastProgress) < progressInterval {
+		return
+	}
+	lastProgress = time.Now()
+	progressShown = true
+	fmt.Fprintf(os.Stderr, "\r\x1b[Kresolved %d packages, importing %s", len(pkgs), name)
+}
+
+// progressDone clears the progress line once loading is complete.
+func progressDone() {
+	if *verbose {
+		debugf("resolved %d packages\n", len(pkgs))
+	}
+	clearProgress()
+}
+
+func clearProgress() {
+	if progressShown {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		progressShown = false
+	}
+}
+
+// progressClearer writes to stderr after clearing the progress line, so that
+// errors logged while loading don't run into it.
+type progressClearer struct{}
+
+func (progressClearer) Write(p []byte) (int, error) {
+	clearProgress()
+	return os.Stderr.Write(p)
+}
+
+func init() {
+	if progressTerminal {
+		log.SetOutput(progressClearer{})
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kisielk/godepgraph/pattern"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// queryExpr is the expression of the query subcommand, its first argument.
+var queryExpr string
+
+// runQuery implements the query subcommand: it computes the graph of the
+// other flags and arguments and writes the packages the query expression
+// selects, one a line, or with -format the graph of those packages and the
+// imports between them.
+func runQuery(cwd string) {
+	args := rerunArgs("-format=json", "-o=", "-watch=false", "-compare-ref=", "-check=")
+	// Drop the expression, the first of the arguments, after the -- that
+	// rerunArgs keeps in front of them.
+	i := len(args) - flag.NArg()
+	if args[i] == "--" {
+		i++
+	}
+	args = append(args[:i:i], args[i+1:]...)
+	g, err := runGraph(cwd, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	q := newQueryGraph(g)
+	sel, err := q.eval(queryExpr)
+	if err != nil {
+		log.Fatalf("invalid query: %s", err)
+	}
+
+	formatSet := false
+	flag.Visit(func(f *flag.Flag) {
+		formatSet = formatSet || f.Name == "format"
+	})
+	out := newOutput(*outputPath)
+	if formatSet {
+		sub := &jsonGraph{SchemaVersion: g.SchemaVersion, Packages: []jsonPackage{}, Edges: []jsonEdge{}}
+		for _, p := range g.Packages {
+			if sel[p.ImportPath] {
+				sub.Packages = append(sub.Packages, p)
+			}
+		}
+		for _, e := range g.Edges {
+			if sel[e.From] && sel[e.To] {
+				sub.Edges = append(sub.Edges, e)
+			}
+		}
+		err = render.Write(out, *outputFormat, sub)
+	} else {
+		err = writeSelection(out, sel)
+	}
+	if err != nil {
+		log.Fatalf("failed to write the query results: %s", err)
+	}
+	if err := out.close(); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputPath, err)
+	}
+}
+
+// writeSelection writes the packages of sel to w, one a line in sorted
+// order.
+func writeSelection(w io.Writer, sel map[string]bool) error {
+	var names []string
+	for name := range sel {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A queryGraph is a graph with what the query expressions select packages
+// by.
+type queryGraph struct {
+	g         *jsonGraph
+	packages  map[string]jsonPackage
+	imports   map[string][]string
+	importers map[string][]string
+	// own are the owners, as packageOwner has them, of the roots, the
+	// packages no other imports.
+	own   map[string]bool
+	depth map[string]int
+}
+
+func newQueryGraph(g *jsonGraph) *queryGraph {
+	q := &queryGraph{g: g, packages: make(map[string]jsonPackage), own: make(map[string]bool), depth: make(map[string]int)}
+	q.imports, q.importers = g.Adjacency()
+	var queue []string
+	for _, p := range g.Packages {
+		q.packages[p.ImportPath] = p
+		if len(q.importers[p.ImportPath]) == 0 {
+			q.own[packageOwner(p)] = true
+			q.depth[p.ImportPath] = 0
+			queue = append(queue, p.ImportPath)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, imp := range q.imports[name] {
+			if _, ok := q.depth[imp]; !ok {
+				q.depth[imp] = q.depth[name] + 1
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return q
+}
+
+// eval returns the packages selected by the expression src. Expressions
+// are sets of packages:
+//
+//	all, root, stdlib, internal, external, missing, cgo, tool
+//	match("pattern")        matching an import path prefix or glob
+//	deps(of="pkg")          reached by the imports of pkg
+//	rdeps(of="pkg")         reaching pkg
+//	imports(of="pkg")       imported by pkg directly
+//	importers(of="pkg")     importing pkg directly
+//	fanin > N, fanout, depth, closure, with >, >=, <, <=, == or !=
+//	!x, x & y, x | y and parentheses
+//
+// The of argument, which may also be given without its name, is an import
+// path, a pattern, or the trailing elements of import paths, such as
+// cmd/api.
+func (q *queryGraph) eval(src string) (map[string]bool, error) {
+	toks, err := queryTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{q: q, toks: toks}
+	sel, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	return sel, nil
+}
+
+// queryTokens splits src into identifiers, numbers, quoted strings and
+// operators.
+func queryTokens(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, src[i:j+1])
+			i = j + 1
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		case strings.HasPrefix(src[i:], ">=") || strings.HasPrefix(src[i:], "<=") || strings.HasPrefix(src[i:], "==") || strings.HasPrefix(src[i:], "!="):
+			toks = append(toks, src[i:i+2])
+			i += 2
+		case strings.IndexByte("&|!()=,<>", c) >= 0:
+			toks = append(toks, src[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+	}
+	return toks, nil
+}
+
+type queryParser struct {
+	q    *queryGraph
+	toks []string
+	pos  int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *queryParser) expect(tok string) error {
+	if t := p.next(); t != tok {
+		if t == "" {
+			return fmt.Errorf("want %q at the end", tok)
+		}
+		return fmt.Errorf("want %q, not %q", tok, t)
+	}
+	return nil
+}
+
+func (p *queryParser) or() (map[string]bool, error) {
+	sel, err := p.and()
+	for err == nil && p.peek() == "|" {
+		p.next()
+		var r map[string]bool
+		if r, err = p.and(); err == nil {
+			for name := range r {
+				sel[name] = true
+			}
+		}
+	}
+	return sel, err
+}
+
+func (p *queryParser) and() (map[string]bool, error) {
+	sel, err := p.unary()
+	for err == nil && p.peek() == "&" {
+		p.next()
+		var r map[string]bool
+		if r, err = p.unary(); err == nil {
+			for name := range sel {
+				if !r[name] {
+					delete(sel, name)
+				}
+			}
+		}
+	}
+	return sel, err
+}
+
+func (p *queryParser) unary() (map[string]bool, error) {
+	if p.peek() != "!" {
+		return p.primary()
+	}
+	p.next()
+	r, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return p.q.where(func(pkg jsonPackage) bool { return !r[pkg.ImportPath] }), nil
+}
+
+func (p *queryParser) primary() (map[string]bool, error) {
+	q := p.q
+	t := p.next()
+	switch t {
+	case "(":
+		sel, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return sel, p.expect(")")
+	case "all":
+		return q.where(func(pkg jsonPackage) bool { return true }), nil
+	case "root":
+		return q.where(func(pkg jsonPackage) bool { return len(q.importers[pkg.ImportPath]) == 0 }), nil
+	case "stdlib":
+		return q.where(func(pkg jsonPackage) bool { return pkg.Goroot }), nil
+	case "internal":
+		return q.where(func(pkg jsonPackage) bool { return !pkg.Goroot && q.own[packageOwner(pkg)] }), nil
+	case "external":
+		return q.where(func(pkg jsonPackage) bool { return !pkg.Goroot && !q.own[packageOwner(pkg)] }), nil
+	case "missing":
+		return q.where(func(pkg jsonPackage) bool { return pkg.Missing }), nil
+	case "cgo":
+		return q.where(func(pkg jsonPackage) bool { return pkg.Cgo }), nil
+	case "tool":
+		return q.where(func(pkg jsonPackage) bool { return pkg.Tool }), nil
+	case "fanin", "fanout", "depth", "closure":
+		return p.comparison(t)
+	case "match", "deps", "rdeps", "imports", "importers":
+		arg, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		if t == "match" {
+			pat, err := pattern.Parse(arg)
+			if err != nil {
+				return nil, err
+			}
+			return q.where(func(pkg jsonPackage) bool { return pkg.ImportPath == arg || pat.Match(pkg.ImportPath) }), nil
+		}
+		from := q.resolve(arg)
+		if len(from) == 0 {
+			return nil, fmt.Errorf("no package %s in the graph", arg)
+		}
+		return q.follow(t, from), nil
+	case "":
+		return nil, fmt.Errorf("unexpected end")
+	}
+	return nil, fmt.Errorf("unexpected %q", t)
+}
+
+// call parses the argument of a call, a string given as of= or alone.
+func (p *queryParser) call() (string, error) {
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	if p.peek() == "of" {
+		p.next()
+		if err := p.expect("="); err != nil {
+			return "", err
+		}
+	}
+	t := p.next()
+	s, err := strconv.Unquote(t)
+	if err != nil {
+		return "", fmt.Errorf("want a quoted string, not %q", t)
+	}
+	return s, p.expect(")")
+}
+
+// comparison parses the comparison of the metric to a number.
+func (p *queryParser) comparison(metric string) (map[string]bool, error) {
+	op := p.next()
+	n, err := strconv.Atoi(p.next())
+	if err != nil {
+		return nil, fmt.Errorf("want a number after %s %s", metric, op)
+	}
+	var cmp func(v int) bool
+	switch op {
+	case ">":
+		cmp = func(v int) bool { return v > n }
+	case ">=":
+		cmp = func(v int) bool { return v >= n }
+	case "<":
+		cmp = func(v int) bool { return v < n }
+	case "<=":
+		cmp = func(v int) bool { return v <= n }
+	case "==", "=":
+		cmp = func(v int) bool { return v == n }
+	case "!=":
+		cmp = func(v int) bool { return v != n }
+	default:
+		return nil, fmt.Errorf("want a comparison after %s, not %q", metric, op)
+	}
+	q := p.q
+	return q.where(func(pkg jsonPackage) bool {
+		name := pkg.ImportPath
+		switch metric {
+		case "fanin":
+			return cmp(len(q.importers[name]))
+		case "fanout":
+			return cmp(len(q.imports[name]))
+		case "depth":
+			d, ok := q.depth[name]
+			return ok && cmp(d)
+		}
+		return cmp(len(reachable([]string{name}, func(n string) []string { return q.imports[n] })) - 1)
+	}), nil
+}
+
+// where returns the packages of the graph for which keep is true.
+func (q *queryGraph) where(keep func(pkg jsonPackage) bool) map[string]bool {
+	sel := make(map[string]bool)
+	for _, p := range q.g.Packages {
+		if keep(p) {
+			sel[p.ImportPath] = true
+		}
+	}
+	return sel
+}
+
+// resolve returns the packages of the graph arg names: the one of the import
+// path arg, or else those matching the pattern arg, or else those whose
+// import paths end in the elements arg.
+func (q *queryGraph) resolve(arg string) []string {
+	if _, ok := q.packages[arg]; ok {
+		return []string{arg}
+	}
+	var names []string
+	if pat, err := pattern.Parse(arg); err == nil {
+		for _, p := range q.g.Packages {
+			if pat.Match(p.ImportPath) {
+				names = append(names, p.ImportPath)
+			}
+		}
+	}
+	if len(names) == 0 {
+		for _, p := range q.g.Packages {
+			if strings.HasSuffix(p.ImportPath, "/"+arg) {
+				names = append(names, p.ImportPath)
+			}
+		}
+	}
+	return names
+}
+
+// follow returns the packages reached from the packages from by the
+// function fn: deps and rdeps following the imports and the importers
+// transitively, imports and importers only once.
+func (q *queryGraph) follow(fn string, from []string) map[string]bool {
+	adj := q.imports
+	if fn == "rdeps" || fn == "importers" {
+		adj = q.importers
+	}
+	sel := make(map[string]bool)
+	if fn == "imports" || fn == "importers" {
+		for _, name := range from {
+			for _, n := range adj[name] {
+				sel[n] = true
+			}
+		}
+		return sel
+	}
+	var next []string
+	for _, name := range from {
+		next = append(next, adj[name]...)
+	}
+	return reachable(next, func(n string) []string { return adj[n] })
+}
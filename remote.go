@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// prepareRemote creates a scratch module in a temporary directory that
+// requires the module named by spec (path@version, where the version
+// defaults to latest) and downloads it through the module proxy. It returns
+// the scratch directory, from which the module's packages resolve, and the
+// module path.
+func prepareRemote(spec string) (dir, modPath string, err error) {
+	modPath, version := splitModVersion(spec)
+	if version == "" {
+		version = "latest"
+	}
+
+	dir, err = ioutil.TempDir("", "godepgraph-remote")
+	if err != nil {
+		return "", "", err
+	}
+	gomod := "module godepgraph.invalid/remote\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+
+	cmd := exec.Command(goCmd, "get", modPath+"@"+version)
+	cmd.Dir = dir
+	cmd.Env = append(goEnv(), "GOFLAGS="+withModFlag("mod"), "GOWORK=off")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return "", "", fmt.Errorf("go get %s@%s: %s: %s", modPath, version, err, strings.TrimSpace(stderr.String()))
+	}
+	return dir, modPath, nil
+}
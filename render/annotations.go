@@ -0,0 +1,74 @@
+package render
+
+import (
+	"sort"
+	"strings"
+)
+
+// annotationKeys are the annotations with a meaning to the writers, in the
+// order they are listed.
+var annotationKeys = []string{"owner", "status", "severity", "notes"}
+
+// severityColors are the fill colors of the packages annotated with a
+// severity.
+var severityColors = map[string]string{
+	"critical": "red",
+	"high":     "orangered",
+	"medium":   "orange",
+	"low":      "khaki",
+}
+
+// AnnotationLabel returns the line the annotations of a package add to its
+// label: its owner and status, if it has either.
+func AnnotationLabel(annotations map[string]string) string {
+	var parts []string
+	for _, k := range []string{"owner", "status"} {
+		if v := annotations[k]; v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AnnotationColor returns the fill color of a package annotated with a
+// known severity, or "".
+func AnnotationColor(annotations map[string]string) string {
+	return severityColors[strings.ToLower(annotations["severity"])]
+}
+
+// AnnotationStyle returns the dot style the status of a package adds to its
+// node: dashed for deprecated packages and dotted for those being migrated,
+// or "".
+func AnnotationStyle(annotations map[string]string) string {
+	switch strings.ToLower(annotations["status"]) {
+	case "deprecated":
+		return "dashed"
+	case "migrating":
+		return "dotted"
+	}
+	return ""
+}
+
+// AnnotationLines returns the annotations as "key: value" lines for
+// tooltips, those of annotationKeys first and the others by key.
+func AnnotationLines(annotations map[string]string) []string {
+	var lines []string
+	known := make(map[string]bool)
+	for _, k := range annotationKeys {
+		known[k] = true
+		if v := annotations[k]; v != "" {
+			lines = append(lines, k+": "+v)
+		}
+	}
+	var others []string
+	for k := range annotations {
+		if !known[k] {
+			others = append(others, k)
+		}
+	}
+	sort.Strings(others)
+	for _, k := range others {
+		lines = append(lines, k+": "+annotations[k])
+	}
+	return lines
+}
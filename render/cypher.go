@@ -0,0 +1,110 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// cypherWriter writes a graph as Cypher statements loading it into a graph
+// database such as Neo4j: a Package node for every package and an IMPORTS
+// relationship for every import, or IMPLEMENTS for implements edges. They
+// MERGE on the import paths, so that running them again updates the graph
+// instead of duplicating it.
+type cypherWriter struct {
+	w io.Writer
+}
+
+func (c *cypherWriter) Begin() error {
+	_, err := fmt.Fprintln(c.w, "CREATE CONSTRAINT package_import_path IF NOT EXISTS FOR (p:Package) REQUIRE p.importPath IS UNIQUE;")
+	return err
+}
+
+func (c *cypherWriter) Node(p graph.Package) error {
+	_, err := fmt.Fprintf(c.w, "MERGE (p:Package {importPath: %s}) SET p += %s;\n", cypherString(p.ImportPath), cypherMap(PackageProperties(p)))
+	return err
+}
+
+func (c *cypherWriter) Edge(e graph.Edge) error {
+	_, err := fmt.Fprintf(c.w, "MATCH (a:Package {importPath: %s}), (b:Package {importPath: %s}) MERGE (a)-[r:%s]->(b) SET r += %s;\n", cypherString(e.From), cypherString(e.To), EdgeRelationship(e), cypherMap(EdgeProperties(e)))
+	return err
+}
+
+func (c *cypherWriter) End() error {
+	return nil
+}
+
+// PackageProperties returns the properties of the Package node of p in the
+// graph databases, as -format cypher writes them. The annotations of p are
+// properties too, but for those named like the others.
+func PackageProperties(p graph.Package) map[string]interface{} {
+	props := map[string]interface{}{
+		"goroot":  p.Goroot,
+		"cgo":     p.Cgo,
+		"tool":    p.Tool,
+		"missing": p.Missing,
+	}
+	for name, v := range map[string]string{"module": p.Module, "version": p.Version, "doc": p.Doc, "error": p.Error} {
+		if v != "" {
+			props[name] = v
+		}
+	}
+	for k, v := range p.Annotations {
+		if _, ok := props[k]; !ok {
+			props[k] = v
+		}
+	}
+	return props
+}
+
+// EdgeRelationship returns the type of the relationship of e in the graph
+// databases: IMPORTS for imports and the upper-cased Kind of other edges.
+func EdgeRelationship(e graph.Edge) string {
+	if e.Kind == "" {
+		return "IMPORTS"
+	}
+	return strings.ToUpper(e.Kind)
+}
+
+// EdgeProperties returns the properties of the relationship of e in the
+// graph databases.
+func EdgeProperties(e graph.Edge) map[string]interface{} {
+	props := map[string]interface{}{"test": e.Test}
+	if e.Denied != "" {
+		props["denied"] = e.Denied
+	}
+	return props
+}
+
+// cypherMap returns the Cypher map literal of props, with sorted keys.
+func cypherMap(props map[string]interface{}) string {
+	var keys []string
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k + ": ")
+		switch v := props[k].(type) {
+		case string:
+			b.WriteString(cypherString(v))
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// cypherString returns the Cypher string literal of s.
+func cypherString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
@@ -0,0 +1,232 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// depcruiseWriter writes a graph as JSON in the result schema of
+// dependency-cruiser, so that the reporters of its ecosystem can be given Go
+// graphs: a module for every package, sourced at its import path, with a
+// dependency for every import. The imports of the standard library are its
+// core modules and those of other versioned modules its npm ones, missing
+// packages can't be resolved, and denied imports are invalid, breaking the
+// deny rule, and listed as violations in the summary. The imports within
+// cycles are circular, so the document is written at the end.
+type depcruiseWriter struct {
+	w        io.Writer
+	packages []graph.Package
+	index    map[string]int
+	edges    []graph.Edge
+}
+
+type depcruiseModule struct {
+	Source          string                `json:"source"`
+	Dependencies    []depcruiseDependency `json:"dependencies"`
+	Dependents      []string              `json:"dependents"`
+	CoreModule      bool                  `json:"coreModule"`
+	CouldNotResolve bool                  `json:"couldNotResolve"`
+	Followable      bool                  `json:"followable"`
+	Orphan          bool                  `json:"orphan"`
+	Valid           bool                  `json:"valid"`
+}
+
+type depcruiseDependency struct {
+	Resolved           string          `json:"resolved"`
+	Module             string          `json:"module"`
+	ModuleSystem       string          `json:"moduleSystem"`
+	CoreModule         bool            `json:"coreModule"`
+	CouldNotResolve    bool            `json:"couldNotResolve"`
+	Followable         bool            `json:"followable"`
+	DependencyTypes    []string        `json:"dependencyTypes"`
+	Dynamic            bool            `json:"dynamic"`
+	ExoticallyRequired bool            `json:"exoticallyRequired"`
+	Circular           bool            `json:"circular"`
+	Valid              bool            `json:"valid"`
+	Rules              []depcruiseRule `json:"rules,omitempty"`
+}
+
+type depcruiseRule struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+}
+
+type depcruiseViolation struct {
+	Type    string        `json:"type"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Rule    depcruiseRule `json:"rule"`
+	Comment string        `json:"comment,omitempty"`
+}
+
+type depcruiseSummary struct {
+	Violations               []depcruiseViolation   `json:"violations"`
+	Error                    int                    `json:"error"`
+	Warn                     int                    `json:"warn"`
+	Info                     int                    `json:"info"`
+	Ignore                   int                    `json:"ignore"`
+	TotalCruised             int                    `json:"totalCruised"`
+	TotalDependenciesCruised int                    `json:"totalDependenciesCruised"`
+	OptionsUsed              map[string]interface{} `json:"optionsUsed"`
+}
+
+func (d *depcruiseWriter) Begin() error {
+	d.index = make(map[string]int)
+	return nil
+}
+
+func (d *depcruiseWriter) Node(p graph.Package) error {
+	d.index[p.ImportPath] = len(d.packages)
+	d.packages = append(d.packages, p)
+	return nil
+}
+
+// Edge records e if it is an import and both its packages were written.
+func (d *depcruiseWriter) Edge(e graph.Edge) error {
+	if e.Kind != "" {
+		return nil
+	}
+	if _, ok := d.index[e.From]; !ok {
+		return nil
+	}
+	if _, ok := d.index[e.To]; !ok {
+		return nil
+	}
+	d.edges = append(d.edges, e)
+	return nil
+}
+
+func (d *depcruiseWriter) End() error {
+	imports := make(map[string][]string)
+	for _, e := range d.edges {
+		imports[e.From] = append(imports[e.From], e.To)
+	}
+	component := components(imports)
+
+	modules := make([]depcruiseModule, len(d.packages))
+	for i, p := range d.packages {
+		modules[i] = depcruiseModule{
+			Source:          p.ImportPath,
+			Dependencies:    []depcruiseDependency{},
+			Dependents:      []string{},
+			CoreModule:      p.Goroot,
+			CouldNotResolve: p.Missing,
+			Followable:      !p.Goroot && !p.Missing,
+			Valid:           true,
+		}
+	}
+	summary := depcruiseSummary{
+		Violations:               []depcruiseViolation{},
+		TotalCruised:             len(modules),
+		TotalDependenciesCruised: len(d.edges),
+		OptionsUsed:              map[string]interface{}{},
+	}
+	for _, e := range d.edges {
+		to := d.packages[d.index[e.To]]
+		dep := depcruiseDependency{
+			Resolved:        e.To,
+			Module:          e.To,
+			ModuleSystem:    "es6",
+			CoreModule:      to.Goroot,
+			CouldNotResolve: to.Missing,
+			Followable:      !to.Goroot && !to.Missing,
+			DependencyTypes: []string{depcruiseType(to)},
+			Circular:        e.From != e.To && component[e.From] == component[e.To],
+			Valid:           e.Denied == "",
+		}
+		if e.Denied != "" {
+			rule := depcruiseRule{Name: "deny", Severity: "error"}
+			dep.Rules = []depcruiseRule{rule}
+			summary.Violations = append(summary.Violations, depcruiseViolation{Type: "dependency", From: e.From, To: e.To, Rule: rule, Comment: e.Denied})
+			summary.Error++
+		}
+		from := &modules[d.index[e.From]]
+		from.Dependencies = append(from.Dependencies, dep)
+		deps := &modules[d.index[e.To]].Dependents
+		*deps = append(*deps, e.From)
+	}
+	for i := range modules {
+		m := &modules[i]
+		m.Orphan = len(m.Dependencies) == 0 && len(m.Dependents) == 0
+		sort.Strings(m.Dependents)
+	}
+
+	enc := json.NewEncoder(d.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Modules []depcruiseModule `json:"modules"`
+		Summary depcruiseSummary  `json:"summary"`
+	}{modules, summary})
+}
+
+// depcruiseType returns the dependency type of an import of p: core for
+// the standard library, unknown for missing packages, npm for those of
+// versioned modules and local for the rest.
+func depcruiseType(p graph.Package) string {
+	switch {
+	case p.Goroot:
+		return "core"
+	case p.Missing:
+		return "unknown"
+	case p.Version != "":
+		return "npm"
+	}
+	return "local"
+}
+
+// components returns the number of the strongly connected component of
+// imports every package is in.
+func components(imports map[string][]string) map[string]int {
+	// Tarjan's algorithm.
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	component := make(map[string]int)
+	var stack []string
+	next, n := 0, 0
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = next
+		low[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, imp := range imports[name] {
+			if _, ok := index[imp]; !ok {
+				connect(imp)
+				if low[imp] < low[name] {
+					low[name] = low[imp]
+				}
+			} else if onStack[imp] && index[imp] < low[name] {
+				low[name] = index[imp]
+			}
+		}
+		if low[name] == index[name] {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component[top] = n
+				if top == name {
+					break
+				}
+			}
+			n++
+		}
+	}
+	var names []string
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := index[name]; !ok {
+			connect(name)
+		}
+	}
+	return component
+}
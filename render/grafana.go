@@ -0,0 +1,147 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// grafanaNode and grafanaEdge are the fields of the frames of the Grafana
+// Node Graph panel, which reads the fields of the nodes by these names and
+// shows the detail__ ones in the context menu of a node.
+type grafanaNode struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	SubTitle      string `json:"subTitle,omitempty"`
+	MainStat      string `json:"mainStat"`
+	SecondaryStat string `json:"secondaryStat"`
+	Color         string `json:"color"`
+	Version       string `json:"detail__version,omitempty"`
+	Doc           string `json:"detail__doc,omitempty"`
+	Error         string `json:"detail__error,omitempty"`
+	Annotations   string `json:"detail__annotations,omitempty"`
+}
+
+type grafanaEdge struct {
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Target          string `json:"target"`
+	MainStat        string `json:"mainStat,omitempty"`
+	Color           string `json:"color,omitempty"`
+	StrokeDasharray string `json:"strokeDasharray,omitempty"`
+	Positions       string `json:"detail__positions,omitempty"`
+}
+
+// grafanaWriter writes the nodes and edges frames of the Grafana Node Graph
+// panel as a JSON document of two arrays, for a JSON data source such as
+// the Infinity plugin. The node statistics count the edges, so the document
+// is written at the end.
+type grafanaWriter struct {
+	w         io.Writer
+	nodes     []grafanaNode
+	index     map[string]int
+	edges     []grafanaEdge
+	imports   map[string]int
+	importers map[string]int
+}
+
+func (g *grafanaWriter) Begin() error {
+	g.index = make(map[string]int)
+	g.imports = make(map[string]int)
+	g.importers = make(map[string]int)
+	return nil
+}
+
+func (g *grafanaWriter) Node(p graph.Package) error {
+	n := grafanaNode{
+		ID:          p.ImportPath,
+		Title:       p.ImportPath,
+		Version:     p.Version,
+		Doc:         p.Doc,
+		Error:       p.Error,
+		Annotations: strings.Join(AnnotationLines(p.Annotations), "; "),
+	}
+	switch {
+	case p.Goroot:
+		n.SubTitle = "std"
+	case p.Module != "":
+		n.SubTitle = p.Module
+	}
+	// The colors of Dot.
+	switch {
+	case p.Missing:
+		n.Color = "red"
+	case p.Tool:
+		n.Color = "gray"
+	case p.Goroot:
+		n.Color = "green"
+	case p.Cgo:
+		n.Color = "orange"
+	default:
+		n.Color = "blue"
+	}
+	if c := AnnotationColor(p.Annotations); c != "" {
+		n.Color = c
+	}
+	g.index[p.ImportPath] = len(g.nodes)
+	g.nodes = append(g.nodes, n)
+	return nil
+}
+
+// Edge records e if both its packages were written.
+func (g *grafanaWriter) Edge(e graph.Edge) error {
+	if _, ok := g.index[e.From]; !ok {
+		return nil
+	}
+	if _, ok := g.index[e.To]; !ok {
+		return nil
+	}
+	ge := grafanaEdge{
+		ID:        e.From + " -> " + e.To,
+		Source:    e.From,
+		Target:    e.To,
+		Positions: strings.Join(e.Positions, "\n"),
+	}
+	switch {
+	case e.Kind != "":
+		ge.ID += " " + e.Kind
+		ge.MainStat = e.Kind
+		ge.Color = "purple"
+		ge.StrokeDasharray = "5 5"
+	case e.Denied != "":
+		ge.MainStat = e.Denied
+		ge.Color = "red"
+	case e.Test:
+		ge.MainStat = "test import"
+		ge.StrokeDasharray = "5 5"
+	}
+	if e.Kind == "" {
+		g.imports[e.From]++
+		g.importers[e.To]++
+	}
+	g.edges = append(g.edges, ge)
+	return nil
+}
+
+func (g *grafanaWriter) End() error {
+	for i := range g.nodes {
+		n := &g.nodes[i]
+		n.MainStat = fmt.Sprintf("%d imports", g.imports[n.ID])
+		n.SecondaryStat = fmt.Sprintf("%d importers", g.importers[n.ID])
+	}
+	if g.nodes == nil {
+		g.nodes = []grafanaNode{}
+	}
+	if g.edges == nil {
+		g.edges = []grafanaEdge{}
+	}
+	enc := json.NewEncoder(g.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Nodes []grafanaNode `json:"nodes"`
+		Edges []grafanaEdge `json:"edges"`
+	}{g.nodes, g.edges})
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// graphsonWriter writes a graph in the GraphSON 3.0 adjacency list format
+// of Apache TinkerPop, read by the Gremlin IO of graph databases such as
+// JanusGraph: a line for every package, a vertex labeled package holding
+// the properties of the Cypher nodes with its edges in and out, labeled
+// imports or after their Kind. Each vertex lists its edges, so the lines
+// are written at the end.
+type graphsonWriter struct {
+	w        io.Writer
+	vertices []graphsonVertex
+	ids      map[string]int64
+	edges    int64
+	props    int64
+}
+
+type graphsonVertex struct {
+	ID         graphsonID                          `json:"id"`
+	Label      string                              `json:"label"`
+	OutE       map[string][]graphsonEdge           `json:"outE,omitempty"`
+	InE        map[string][]graphsonEdge           `json:"inE,omitempty"`
+	Properties map[string][]graphsonVertexProperty `json:"properties"`
+}
+
+type graphsonEdge struct {
+	ID         graphsonID             `json:"id"`
+	InV        *graphsonID            `json:"inV,omitempty"`
+	OutV       *graphsonID            `json:"outV,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type graphsonVertexProperty struct {
+	ID    graphsonID  `json:"id"`
+	Value interface{} `json:"value"`
+}
+
+// A graphsonID is an id typed as a long.
+type graphsonID int64
+
+func (id graphsonID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"@type": "g:Int64", "@value": int64(id)})
+}
+
+func (g *graphsonWriter) Begin() error {
+	g.ids = make(map[string]int64)
+	return nil
+}
+
+func (g *graphsonWriter) Node(p graph.Package) error {
+	props := PackageProperties(p)
+	props["importPath"] = p.ImportPath
+	var names []string
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	v := graphsonVertex{
+		ID:         graphsonID(len(g.vertices)),
+		Label:      "package",
+		Properties: make(map[string][]graphsonVertexProperty),
+	}
+	for _, name := range names {
+		v.Properties[name] = []graphsonVertexProperty{{ID: graphsonID(g.props), Value: props[name]}}
+		g.props++
+	}
+	g.ids[p.ImportPath] = int64(len(g.vertices))
+	g.vertices = append(g.vertices, v)
+	return nil
+}
+
+// Edge records e on both its vertices, if both its packages were written.
+func (g *graphsonWriter) Edge(e graph.Edge) error {
+	from, ok := g.ids[e.From]
+	if !ok {
+		return nil
+	}
+	to, ok := g.ids[e.To]
+	if !ok {
+		return nil
+	}
+	label := strings.ToLower(EdgeRelationship(e))
+	id, in, out := graphsonID(g.edges), graphsonID(to), graphsonID(from)
+	g.edges++
+	props := EdgeProperties(e)
+	fv, tv := &g.vertices[from], &g.vertices[to]
+	if fv.OutE == nil {
+		fv.OutE = make(map[string][]graphsonEdge)
+	}
+	fv.OutE[label] = append(fv.OutE[label], graphsonEdge{ID: id, InV: &in, Properties: props})
+	if tv.InE == nil {
+		tv.InE = make(map[string][]graphsonEdge)
+	}
+	tv.InE[label] = append(tv.InE[label], graphsonEdge{ID: id, OutV: &out, Properties: props})
+	return nil
+}
+
+func (g *graphsonWriter) End() error {
+	enc := json.NewEncoder(g.w)
+	for _, v := range g.vertices {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package render writes the graphs of package graph in the output formats of
+// godepgraph. Formats are Writers, registered by name, so that new ones can
+// be added without changing how graphs are walked.
+//
+// The dot format is drawn by a DotWriter, which Dot uses as it is. It isn't
+// registered here: programs register their own drawing, built on DotWriter,
+// as godepgraph registers one adding what its flags draw.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+func init() {
+	Register("json", func(w io.Writer) Writer { return &jsonWriter{w: w} })
+	Register("grafana", func(w io.Writer) Writer { return &grafanaWriter{w: w} })
+	Register("cypher", func(w io.Writer) Writer { return &cypherWriter{w: w} })
+	Register("graphson", func(w io.Writer) Writer { return &graphsonWriter{w: w} })
+	Register("structurizr", func(w io.Writer) Writer { return &structurizrWriter{w: w} })
+	Register("dependency-cruiser", func(w io.Writer) Writer { return &depcruiseWriter{w: w} })
+}
+
+// JSON writes g to w as JSON indented with tabs, as -format json does.
+func JSON(w io.Writer, g *graph.Graph) error {
+	return Write(w, "json", g)
+}
+
+// Dot writes g to w in Graphviz dot format, coloring packages as godepgraph
+// does: the standard library green, cgo packages gold, missing packages red
+// and the rest blue. Denied edges are drawn red, with the reason as tooltip,
+// the symbols of edges recording them are their tooltip, and implements
+// edges are drawn dashed purple, and embedded file patterns are drawn as
+// notes attached to their packages.
+func Dot(w io.Writer, g *graph.Graph) error {
+	return Emit(NewDotWriter(w), g)
+}
+
+type jsonWriter struct {
+	w io.Writer
+	g graph.Graph
+}
+
+func (j *jsonWriter) Begin() error {
+	j.g = *graph.New()
+	return nil
+}
+
+func (j *jsonWriter) Node(p graph.Package) error {
+	j.g.Packages = append(j.g.Packages, p)
+	return nil
+}
+
+func (j *jsonWriter) Edge(e graph.Edge) error {
+	j.g.Edges = append(j.g.Edges, e)
+	return nil
+}
+
+func (j *jsonWriter) End() error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(j.g)
+}
+
+// A DotWriter is the Writer of the dot format. It draws packages and edges
+// with NodeAttrs and EdgeAttrs, and numbers nodes in the order it draws them,
+// unless its functions replace those.
+type DotWriter struct {
+	w     io.Writer
+	ids   map[string]int
+	drawn map[string]bool
+	nodes []graph.Package
+	edges map[string][]graph.Edge
+
+	// Grouped holds the graph back until Flush or End, to draw each package
+	// followed by the edges from it rather than in the order they're given.
+	Grouped bool
+
+	// ID returns the id of the node of the package path, or of the file
+	// pattern it embeds if pattern isn't empty.
+	ID func(path, pattern string) int
+	// NodeAttrs returns the dot attributes of the node of p, and EdgeAttrs
+	// those of the edge e, if any.
+	NodeAttrs func(p graph.Package) string
+	EdgeAttrs func(e graph.Edge) string
+}
+
+// NewDotWriter returns a DotWriter writing to w.
+func NewDotWriter(w io.Writer) *DotWriter {
+	d := &DotWriter{w: w, ids: make(map[string]int), drawn: make(map[string]bool)}
+	d.ID = func(path, pattern string) int {
+		key := path
+		if pattern != "" {
+			key += "\x00" + pattern
+		}
+		id, ok := d.ids[key]
+		if !ok {
+			id = len(d.ids)
+			d.ids[key] = id
+		}
+		return id
+	}
+	d.NodeAttrs, d.EdgeAttrs = NodeAttrs, EdgeAttrs
+	return d
+}
+
+func (d *DotWriter) Begin() error {
+	_, err := fmt.Fprintln(d.w, "digraph godep {")
+	return err
+}
+
+func (d *DotWriter) Node(p graph.Package) error {
+	d.drawn[p.ImportPath] = true
+	if d.Grouped {
+		d.nodes = append(d.nodes, p)
+		return nil
+	}
+	return d.node(p)
+}
+
+func (d *DotWriter) node(p graph.Package) error {
+	id := d.ID(p.ImportPath, "")
+	if _, err := fmt.Fprintf(d.w, "_%d [%s];\n", id, d.NodeAttrs(p)); err != nil {
+		return err
+	}
+	// Embedded files are leaves of their package, with ids of their own.
+	for _, pattern := range p.Embeds {
+		leaf := d.ID(p.ImportPath, pattern)
+		if _, err := fmt.Fprintf(d.w, "_%d [%s];\n_%d -> _%d [style=\"dotted\"];\n", leaf, EmbedAttrs(p.ImportPath, pattern), id, leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Edge draws e if both its packages were drawn.
+func (d *DotWriter) Edge(e graph.Edge) error {
+	if d.Grouped {
+		if d.edges == nil {
+			d.edges = make(map[string][]graph.Edge)
+		}
+		d.edges[e.From] = append(d.edges[e.From], e)
+		return nil
+	}
+	return d.edge(e)
+}
+
+func (d *DotWriter) edge(e graph.Edge) error {
+	if !d.drawn[e.From] || !d.drawn[e.To] {
+		return nil
+	}
+	from, to := d.ID(e.From, ""), d.ID(e.To, "")
+	var err error
+	if a := d.EdgeAttrs(e); a != "" {
+		_, err = fmt.Fprintf(d.w, "_%d -> _%d [%s];\n", from, to, a)
+	} else {
+		_, err = fmt.Fprintf(d.w, "_%d -> _%d;\n", from, to)
+	}
+	return err
+}
+
+// Flush draws the packages and edges held back by Grouped.
+func (d *DotWriter) Flush() error {
+	for _, p := range d.nodes {
+		if err := d.node(p); err != nil {
+			return err
+		}
+		for _, e := range d.edges[p.ImportPath] {
+			if err := d.edge(e); err != nil {
+				return err
+			}
+		}
+	}
+	d.nodes, d.edges = nil, nil
+	return nil
+}
+
+func (d *DotWriter) End() error {
+	if err := d.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(d.w, "}")
+	return err
+}
+
+// EdgeAttrs returns the dot attributes of e, as Dot draws it, if any.
+func EdgeAttrs(e graph.Edge) string {
+	switch {
+	case e.Kind == "implements":
+		return fmt.Sprintf(`style="dashed" color="purple" arrowhead="empty" tooltip="%s"`, Escape(strings.Join(e.Symbols, "\n")))
+	case e.Denied != "":
+		return fmt.Sprintf(`color="red" penwidth="2" tooltip="%s"`, Escape(e.Denied))
+	case e.Test:
+		return `style="dashed" tooltip="test import"`
+	case len(e.Symbols) > 0:
+		return fmt.Sprintf(`tooltip="%s"`, Escape(strings.Join(e.Symbols, "\n")))
+	}
+	return ""
+}
+
+// NodeAttrs returns the dot attributes of p, as Dot draws it. The owner and
+// status annotations of p are added to its label and all of its annotations
+// to its tooltip, its severity fills it and its status styles it.
+func NodeAttrs(p graph.Package) string {
+	style, color, fillcolor, tooltip := "filled", "paleturquoise", "", p.Doc
+	switch {
+	case p.Missing:
+		style, color, fillcolor, tooltip = "filled,dashed", "red", "mistyrose", p.Error
+	case p.Tool:
+		color, tooltip = "gainsboro", "tooling"
+	case p.Goroot:
+		color = "palegreen"
+	case p.Cgo:
+		color = "darkgoldenrod1"
+	}
+	label := p.ImportPath
+	if l := AnnotationLabel(p.Annotations); l != "" {
+		label += "\n" + l
+	}
+	if c := AnnotationColor(p.Annotations); c != "" && !p.Missing {
+		fillcolor = c
+	}
+	if s := AnnotationStyle(p.Annotations); s != "" && !p.Missing {
+		style += "," + s
+	}
+	lines := AnnotationLines(p.Annotations)
+	if tooltip != "" {
+		lines = append([]string{tooltip}, lines...)
+	}
+	a := fmt.Sprintf(`label="%s" style="%s" color="%s"`, Escape(label), style, color)
+	if fillcolor != "" {
+		a += fmt.Sprintf(` fillcolor="%s"`, fillcolor)
+	}
+	if len(lines) > 0 {
+		a += fmt.Sprintf(` tooltip="%s"`, Escape(strings.Join(lines, "\n")))
+	}
+	return a
+}
+
+// EmbedAttrs returns the dot attributes of the node of the file pattern
+// pattern embedded by the package path, as Dot draws it.
+func EmbedAttrs(path, pattern string) string {
+	return fmt.Sprintf(`label="%s" shape="note" style="filled" color="wheat" tooltip="%s"`, Escape(pattern), Escape("embedded by "+path))
+}
+
+// Escape escapes s for use in a quoted dot string.
+func Escape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// structurizrWriter writes a graph as a Structurizr DSL workspace of the C4
+// model: one software system with a container for every module, the
+// standard library one of its own, holding a component for every package,
+// and a relationship between the components for every import. Outside
+// module mode every package is a container of its own. The workspace has a
+// container view of the system and a component view of every container of
+// the modules without a version, those being worked on. Components are
+// tagged Missing or Cgo, and with their status annotation. The components are
+// grouped by container, so the workspace is written at the end.
+type structurizrWriter struct {
+	w          io.Writer
+	containers map[string][]graph.Package
+	ids        map[string]string
+	edges      []graph.Edge
+}
+
+func (s *structurizrWriter) Begin() error {
+	s.containers = make(map[string][]graph.Package)
+	s.ids = make(map[string]string)
+	return nil
+}
+
+func (s *structurizrWriter) Node(p graph.Package) error {
+	c := structurizrContainer(p)
+	s.containers[c] = append(s.containers[c], p)
+	s.ids[p.ImportPath] = ""
+	return nil
+}
+
+// Edge records e if both its packages were written.
+func (s *structurizrWriter) Edge(e graph.Edge) error {
+	if _, ok := s.ids[e.From]; !ok {
+		return nil
+	}
+	if _, ok := s.ids[e.To]; !ok {
+		return nil
+	}
+	s.edges = append(s.edges, e)
+	return nil
+}
+
+func (s *structurizrWriter) End() error {
+	var names []string
+	for name := range s.containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("workspace {\n\tmodel {\n\t\tsystem = softwareSystem \"Go packages\" {\n")
+	var views []string
+	for i, name := range names {
+		id := fmt.Sprintf("c%d", i)
+		pkgs := s.containers[name]
+		var tags []string
+		switch {
+		case name == "std":
+			tags = append(tags, "Standard Library")
+		case pkgs[0].Version != "":
+			tags = append(tags, "External")
+		default:
+			views = append(views, id)
+		}
+		fmt.Fprintf(&b, "\t\t\t%s = container %s \"\" \"Go module\" {\n", id, structurizrString(name))
+		if len(tags) > 0 {
+			fmt.Fprintf(&b, "\t\t\t\ttags %s\n", structurizrString(strings.Join(tags, ",")))
+		}
+		for j, p := range pkgs {
+			cid := fmt.Sprintf("%s_%d", id, j)
+			s.ids[p.ImportPath] = cid
+			fmt.Fprintf(&b, "\t\t\t\t%s = component %s %s \"Go package\"", cid, structurizrString(p.ImportPath), structurizrString(p.Doc))
+			var ctags []string
+			switch {
+			case p.Missing:
+				ctags = append(ctags, "Missing")
+			case p.Cgo:
+				ctags = append(ctags, "Cgo")
+			}
+			if status := p.Annotations["status"]; status != "" {
+				ctags = append(ctags, status)
+			}
+			if len(ctags) > 0 {
+				fmt.Fprintf(&b, " {\n\t\t\t\t\ttags %s\n\t\t\t\t}", structurizrString(strings.Join(ctags, ",")))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\t\t\t}\n")
+	}
+	b.WriteString("\t\t}\n")
+	for _, e := range s.edges {
+		desc := "imports"
+		if e.Kind != "" {
+			desc = e.Kind
+		}
+		fmt.Fprintf(&b, "\t\t%s -> %s %s\n", s.ids[e.From], s.ids[e.To], structurizrString(desc))
+	}
+	b.WriteString("\t}\n\tviews {\n\t\tcontainer system {\n\t\t\tinclude *\n\t\t\tautoLayout\n\t\t}\n")
+	for _, id := range views {
+		fmt.Fprintf(&b, "\t\tcomponent %s {\n\t\t\tinclude *\n\t\t\tautoLayout\n\t\t}\n", id)
+	}
+	b.WriteString("\t}\n}\n")
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+// structurizrContainer returns the name of the container of p: std for the
+// standard library, its module in module mode and its import path
+// otherwise.
+func structurizrContainer(p graph.Package) string {
+	switch {
+	case p.Goroot:
+		return "std"
+	case p.Module != "":
+		return p.Module
+	}
+	return p.ImportPath
+}
+
+// structurizrString returns the quoted Structurizr DSL string of s.
+func structurizrString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
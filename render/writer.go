@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// A Writer writes a graph in some output format. Emit calls Begin, then Node
+// for every package, then Edge for every edge and finally End. Writers may
+// also be given nodes and edges interleaved, as godepgraph -stream does, with
+// every edge after the nodes of both its packages.
+type Writer interface {
+	Begin() error
+	Node(p graph.Package) error
+	Edge(e graph.Edge) error
+	End() error
+}
+
+// A NewWriter returns a Writer writing to w.
+type NewWriter func(w io.Writer) Writer
+
+var writers = make(map[string]NewWriter)
+
+// Register makes the format available to Lookup and Write, and to the
+// -format flag of godepgraph, under name. It panics if the name is taken,
+// so it is meant to be called from init functions.
+func Register(name string, f NewWriter) {
+	if _, ok := writers[name]; ok {
+		panic("render: format " + name + " registered twice")
+	}
+	writers[name] = f
+}
+
+// Lookup returns the writer of the format registered under name, or nil.
+func Lookup(name string) NewWriter {
+	return writers[name]
+}
+
+// Formats returns the sorted names of the registered formats.
+func Formats() []string {
+	var names []string
+	for name := range writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Emit writes g with w.
+func Emit(w Writer, g *graph.Graph) error {
+	if err := w.Begin(); err != nil {
+		return err
+	}
+	for _, p := range g.Packages {
+		if err := w.Node(p); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		if err := w.Edge(e); err != nil {
+			return err
+		}
+	}
+	return w.End()
+}
+
+// Write writes g to out in the format registered under name.
+func Write(out io.Writer, name string, g *graph.Graph) error {
+	f := writers[name]
+	if f == nil {
+		return fmt.Errorf("unknown format %q", name)
+	}
+	return Emit(f(out), g)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rootReach returns, for every package of the graph, the roots reaching
+// it, in the order of roots.
+func rootReach(roots []string) map[string][]string {
+	reach := make(map[string][]string)
+	for _, root := range roots {
+		for name := range reachable([]string{root}, drawnImports) {
+			reach[name] = append(reach[name], root)
+		}
+	}
+	return reach
+}
+
+// decorateRootReach colors, with -root-colors, the node of a package
+// reached by one of several roots alone after the root, with a color of
+// the set39 scheme per root in the order of roots, and draws those shared
+// by several roots gray with a double border.
+func decorateRootReach(a *attrs, reachedBy, roots []string) {
+	if len(roots) < 2 || len(reachedBy) == 0 {
+		return
+	}
+	if len(reachedBy) == 1 {
+		for i, root := range roots {
+			if root == reachedBy[0] {
+				a.set("color", fmt.Sprintf("/set39/%d", 1+i%9))
+			}
+		}
+		a.appendAttr("tooltip", "only in "+reachedBy[0], `\n`)
+		return
+	}
+	a.set("color", "gray80")
+	a.set("peripheries", "2")
+	a.appendAttr("tooltip", "shared by "+strings.Join(reachedBy, ", "), `\n`)
+}
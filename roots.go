@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isPattern reports whether arg is a package pattern, as understood by the go
+// tool, rather than a single package.
+func isPattern(arg string) bool {
+	switch arg {
+	case "all", "std", "cmd":
+		return true
+	}
+	return strings.Contains(arg, "...")
+}
+
+// isDirArg reports whether arg names a directory, or a pattern rooted at
+// one, rather than an import path. As with the go tool, relative directories
+// must start with . or .. to be told apart from import paths.
+func isDirArg(arg string) bool {
+	return filepath.IsAbs(arg) || build.IsLocalImport(filepath.ToSlash(arg))
+}
+
+// stdRoot returns what the argument std/pattern stands for, the packages of
+// the standard library pattern matches, or std itself for std/..., and
+// whether arg is of that form, which the go tool doesn't know.
+func stdRoot(arg string) (string, bool) {
+	if !strings.HasPrefix(arg, "std/") {
+		return "", false
+	}
+	if p := strings.TrimPrefix(arg, "std/"); p != "..." {
+		return p, true
+	}
+	return "std", true
+}
+
+// inGorootPattern reports whether the pattern p can match packages of the
+// standard library: whether the directory of GOROOT it is rooted at, the
+// one holding the element its first ... is in, exists.
+func inGorootPattern(p string) bool {
+	i := strings.Index(p, "...")
+	if i < 0 {
+		return isGorootPath(p)
+	}
+	dir := path.Dir(p[:i] + "x")
+	return dir == "." || isGorootPath(dir)
+}
+
+// hasStdRoots reports whether args include std or a std/pattern argument.
+func hasStdRoots(args []string) bool {
+	for _, arg := range args {
+		if _, ok := stdRoot(arg); ok || arg == "std" {
+			return true
+		}
+	}
+	return false
+}
+
+// expandRoots expands the package patterns and directories among args,
+// resolved relative to dir, into the import paths they match, those of
+// std/pattern restricted to the standard library. Other arguments are kept
+// as given.
+func expandRoots(dir string, args []string) ([]string, error) {
+	var roots []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			roots = append(roots, p)
+		}
+	}
+	for _, arg := range args {
+		p, std := stdRoot(arg)
+		if std {
+			if p != "std" && !inGorootPattern(p) {
+				return nil, fmt.Errorf("%s matches no packages of the standard library", arg)
+			}
+			arg = p
+		}
+		if isDirArg(arg) {
+			// Make the directory absolute so that it doesn't depend on
+			// where the go command runs, e.g. in a workspace. Outside one,
+			// it runs in dir and is given the directory relative to it,
+			// the only form it accepts outside GOPATH and modules.
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(dir, arg)
+			}
+			if buildContext.Dir == "" {
+				if rel, err := filepath.Rel(dir, arg); err == nil {
+					arg = rel
+					if rel != "." && !isParentRel(rel) {
+						arg = "." + string(filepath.Separator) + rel
+					}
+				}
+			}
+		} else if !isPattern(arg) {
+			add(arg)
+			continue
+		}
+		lps, err := goList(dir, []string{"-find"}, arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %s", arg, err)
+		}
+		for _, lp := range lps {
+			if lp.Error != nil && lp.Dir == "" {
+				return nil, fmt.Errorf("failed to expand %s: %s", arg, strings.TrimSpace(lp.Error.Err))
+			}
+			if !std || lp.Standard {
+				add(lp.ImportPath)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no packages match %s", strings.Join(args, " "))
+	}
+	return roots, nil
+}
+
+// readRootsFile reads package names and patterns from the file at path, or
+// from stdin if path is "-". Roots are separated by whitespace; blank lines
+// and lines starting with # are ignored.
+func readRootsFile(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var roots []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		roots = append(roots, strings.Fields(line)...)
+	}
+	return roots, s.Err()
+}
+
+// findMains returns the import paths of the main packages in the trees
+// named by args, resolved relative to dir. Directories are searched
+// recursively; with no arguments the tree rooted at dir is searched.
+func findMains(dir string, args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var patterns []string
+	for _, arg := range args {
+		if isDirArg(arg) && !isPattern(arg) {
+			arg = filepath.Join(arg, "...")
+			if !filepath.IsAbs(arg) {
+				arg = "." + string(filepath.Separator) + arg
+			}
+		}
+		patterns = append(patterns, arg)
+	}
+
+	lps, err := goList(dir, []string{"-find"}, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var mains []string
+	for _, lp := range lps {
+		if lp.Name == "main" {
+			mains = append(mains, lp.ImportPath)
+		}
+	}
+	if len(mains) == 0 {
+		return nil, fmt.Errorf("no main packages found in %s", strings.Join(args, " "))
+	}
+	return mains, nil
+}
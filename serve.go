@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kisielk/godepgraph/graph"
+)
+
+// A graphServer serves the graph of the command line of the serve
+// subcommand, scanning it again on demand.
+type graphServer struct {
+	cwd  string
+	args []string
+
+	mu    sync.Mutex
+	graph *jsonGraph
+}
+
+// runServe implements the serve subcommand: it computes the graph of the
+// other flags and arguments and serves it on the -http address, with a page
+// for browsing it, endpoints returning it as JSON, dot and SVG, a GraphQL
+// endpoint and Prometheus metrics.
+func runServe(cwd string) {
+	s := &graphServer{
+		cwd:  cwd,
+		args: rerunArgs("-format=json", "-o=", "-watch=false", "-compare-ref=", "-check="),
+	}
+	if err := s.rescan(); err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveIndex)
+	mux.HandleFunc("/graph.json", s.serveJSON)
+	mux.HandleFunc("/graph.dot", s.serveDot)
+	mux.HandleFunc("/graph.svg", s.serveSVG)
+	mux.HandleFunc("/rescan", s.serveRescan)
+	mux.HandleFunc("/graphql", s.serveGraphQL)
+	mux.HandleFunc("/metrics", serveMetrics(s.latest))
+	log.Printf("serving the graph on %s", *httpAddr)
+	log.Fatal(http.ListenAndServe(*httpAddr, mux))
+}
+
+// rescan computes the graph again.
+func (s *graphServer) rescan() error {
+	g, err := runGraph(s.cwd, s.args)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.graph = g
+	s.mu.Unlock()
+	return nil
+}
+
+// latest returns the graph, as last scanned.
+func (s *graphServer) latest() (*jsonGraph, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graph, nil
+}
+
+// filtered returns the graph restricted by the query of r: stdlib=0 hides
+// the standard library, and focus=pkg keeps only the package and those
+// within depth imports of it, in either direction, if depth is set.
+func (s *graphServer) filtered(r *http.Request) (*jsonGraph, error) {
+	if r.FormValue("rescan") == "1" {
+		if err := s.rescan(); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	g := s.graph
+	s.mu.Unlock()
+
+	q := r.URL.Query()
+	depth := 0
+	if d := q.Get("depth"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid depth %q", d)
+		}
+		depth = n
+	}
+	return filterJSONGraph(g, q.Get("stdlib") == "0", q.Get("focus"), depth)
+}
+
+// filterJSONGraph returns the part of g outside the standard library if
+// hideStdlib is set and, if focus is set, within depth edges of focus, or
+// connected to it at all if depth is 0.
+func filterJSONGraph(g *jsonGraph, hideStdlib bool, focus string, depth int) (*jsonGraph, error) {
+	keep := make(map[string]bool)
+	for _, p := range g.Packages {
+		if !hideStdlib || !p.Goroot {
+			keep[p.ImportPath] = true
+		}
+	}
+	if focus != "" {
+		if !keep[focus] {
+			return nil, fmt.Errorf("package %s is not in the graph", focus)
+		}
+		out := make(map[string][]string)
+		in := make(map[string][]string)
+		for _, e := range g.Edges {
+			if keep[e.From] && keep[e.To] {
+				out[e.From] = append(out[e.From], e.To)
+				in[e.To] = append(in[e.To], e.From)
+			}
+		}
+		near := map[string]bool{focus: true}
+		for _, next := range []map[string][]string{out, in} {
+			frontier := []string{focus}
+			seen := map[string]bool{focus: true}
+			for hop := 0; len(frontier) > 0 && (depth == 0 || hop < depth); hop++ {
+				var following []string
+				for _, name := range frontier {
+					for _, n := range next[name] {
+						if !seen[n] {
+							seen[n] = true
+							near[n] = true
+							following = append(following, n)
+						}
+					}
+				}
+				frontier = following
+			}
+		}
+		keep = near
+	}
+
+	f := graph.New()
+	for _, p := range g.Packages {
+		if keep[p.ImportPath] {
+			f.Packages = append(f.Packages, p)
+		}
+	}
+	for _, e := range g.Edges {
+		if keep[e.From] && keep[e.To] {
+			f.Edges = append(f.Edges, e)
+		}
+	}
+	return f, nil
+}
+
+func (s *graphServer) serveJSON(w http.ResponseWriter, r *http.Request) {
+	g, err := s.filtered(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeIndentedJSON(w, g)
+}
+
+func (s *graphServer) serveDot(w http.ResponseWriter, r *http.Request) {
+	g, err := s.filtered(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	writeGraphDot(w, g)
+}
+
+func (s *graphServer) serveSVG(w http.ResponseWriter, r *http.Request) {
+	g, err := s.filtered(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var dot bytes.Buffer
+	writeGraphDot(&dot, g)
+	cmd := exec.Command("dot", "-Tsvg")
+	cmd.Stdin = &dot
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	svg, err := cmd.Output()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("dot: %s: %s", err, strings.TrimSpace(stderr.String())), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(svg)
+}
+
+func (s *graphServer) serveRescan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.rescan(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *graphServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, indexPage)
+}
+
+// indexPage browses the graph: the filters select the part of the graph
+// drawn, through /graph.svg, and listed, with the imports and importers of
+// each package, through /graph.json.
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>godepgraph</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; height: 100vh; }
+#side { width: 24em; padding: 1em; overflow: auto; border-right: 1px solid #ccc; }
+#graph { flex: 1; overflow: auto; }
+#graph img { max-width: none; }
+label { display: block; margin: 0.5em 0; }
+li { cursor: pointer; }
+li small { color: #666; }
+.error { color: firebrick; }
+</style>
+</head>
+<body>
+<div id="side">
+<label><input id="stdlib" type="checkbox"> hide the standard library</label>
+<label>focus <input id="focus" list="names" placeholder="import path"></label>
+<label>depth <input id="depth" type="range" min="0" max="10" value="0"> <span id="depthValue">all</span></label>
+<button id="rescan">Rescan</button>
+<label>search <input id="search" placeholder="filter the list"></label>
+<div id="error" class="error"></div>
+<ul id="list"></ul>
+<datalist id="names"></datalist>
+</div>
+<div id="graph"><img id="svg" alt=""></div>
+<script>
+var graph = {Packages: [], Edges: []};
+
+function query() {
+	var q = new URLSearchParams();
+	if (document.getElementById("stdlib").checked) q.set("stdlib", "0");
+	var focus = document.getElementById("focus").value;
+	if (focus) {
+		q.set("focus", focus);
+		q.set("depth", document.getElementById("depth").value);
+	}
+	return q;
+}
+
+function load(rescan) {
+	var q = query();
+	if (rescan) q.set("rescan", "1");
+	fetch("/graph.json?" + q).then(function(r) {
+		if (!r.ok) return r.text().then(function(t) { throw new Error(t); });
+		return r.json();
+	}).then(function(g) {
+		graph = g;
+		document.getElementById("error").textContent = "";
+		document.getElementById("svg").src = "/graph.svg?" + query();
+		render();
+	}).catch(function(e) {
+		document.getElementById("error").textContent = e.message;
+	});
+}
+
+function render() {
+	var imports = {}, importers = {};
+	graph.Edges.forEach(function(e) {
+		(imports[e.From] = imports[e.From] || []).push(e.To);
+		(importers[e.To] = importers[e.To] || []).push(e.From);
+	});
+	var search = document.getElementById("search").value;
+	var list = document.getElementById("list");
+	var names = document.getElementById("names");
+	list.innerHTML = "";
+	names.innerHTML = "";
+	graph.Packages.forEach(function(p) {
+		var name = p.ImportPath;
+		var opt = document.createElement("option");
+		opt.value = name;
+		names.appendChild(opt);
+		if (search && name.indexOf(search) < 0) return;
+		var li = document.createElement("li");
+		li.textContent = name + " ";
+		var info = document.createElement("small");
+		info.textContent = (imports[name] || []).length + " imports, " + (importers[name] || []).length + " importers";
+		li.appendChild(info);
+		li.onclick = function() {
+			document.getElementById("focus").value = name;
+			load(false);
+		};
+		list.appendChild(li);
+	});
+}
+
+document.getElementById("stdlib").onchange = function() { load(false); };
+document.getElementById("focus").onchange = function() { load(false); };
+document.getElementById("depth").oninput = function() {
+	document.getElementById("depthValue").textContent = this.value == 0 ? "all" : this.value;
+};
+document.getElementById("depth").onchange = function() { load(false); };
+document.getElementById("search").oninput = render;
+document.getElementById("rescan").onclick = function() { load(true); };
+load(false);
+</script>
+</body>
+</html>
+`
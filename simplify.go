@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// summaryMembers holds, with -simplify, the packages each summary node
+// stands for, by the path of the node.
+var summaryMembers map[string][]string
+
+// summaryPath returns the path of the summary node of the package path,
+// which -simplify doesn't keep: the longest kept package path is below or,
+// failing one, its organization, followed by /..., and std for the packages
+// of the standard library.
+func summaryPath(path string, kept map[string]bool) string {
+	for dir := path; ; {
+		i := strings.LastIndex(dir, "/")
+		if i <= 0 {
+			break
+		}
+		if dir = dir[:i]; kept[dir] {
+			return dir + "/..."
+		}
+	}
+	if pkgs[path].Goroot {
+		return "std"
+	}
+	return packageOrg(path) + "/..."
+}
+
+// simplifyGraph keeps the n most central packages of the graph by the
+// -centrality measure and the roots, merging each of the others into the
+// summary node of its summaryPath, with the imports of all of them, and
+// returns the roots.
+func simplifyGraph(roots []string, n int) []string {
+	var keys []string
+	for name := range pkgs {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	kept := make(map[string]bool)
+	for _, root := range roots {
+		kept[root] = true
+	}
+	for _, name := range mostCentral(centrality(keys), n) {
+		kept[name] = true
+	}
+
+	summaries := make(map[string]string)
+	summaryMembers = make(map[string][]string)
+	for _, name := range keys {
+		if kept[name] || isIgnored(pkgs[name]) {
+			continue
+		}
+		s := summaryPath(name, kept)
+		summaries[name] = s
+		summaryMembers[s] = append(summaryMembers[s], name)
+	}
+	return mergePackages(func(path string) string {
+		if s, ok := summaries[path]; ok {
+			return s
+		}
+		return path
+	}, roots, false)
+}
+
+// decorateSummary draws the summary node of -simplify standing for members
+// as a folder, noting their number in its label and listing them in its
+// tooltip.
+func decorateSummary(a *attrs, members []string) {
+	const listed = 20
+	a.set("shape", "folder")
+	if len(members) == 1 {
+		a.appendAttr("label", "(1 package)", `\n`)
+	} else {
+		a.appendAttr("label", fmt.Sprintf("(%d packages)", len(members)), `\n`)
+	}
+	tooltip := members
+	if len(members) > listed {
+		tooltip = append(members[:listed:listed], fmt.Sprintf("and %d more", len(members)-listed))
+	}
+	a.appendAttr("tooltip", strings.Join(tooltip, `\n`), `\n`)
+}
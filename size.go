@@ -0,0 +1,170 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"debug/gosym"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// codeSizes holds, with -size, the bytes of machine code every package adds
+// to the executable of -size, by import path.
+var codeSizes map[string]int64
+
+// computeCodeSizes reads the size of the functions of every package linked
+// into the Go executable at path from its symbol table into codeSizes. The
+// main package is recorded under the import path the build info gives it.
+// Data and the runtime type information aren't attributed to packages by the
+// symbol table, so only code is counted.
+func computeCodeSizes(path string) error {
+	bi, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	pclntab, text, err := readPclntab(path)
+	if err != nil {
+		return err
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(pclntab, text))
+	if err != nil {
+		return fmt.Errorf("failed to read symbol table: %s", err)
+	}
+	codeSizes = make(map[string]int64)
+	for _, fn := range table.Funcs {
+		p := symbolPackage(fn.Sym)
+		if p == "" {
+			continue
+		}
+		if p == "main" && bi.Path != "" {
+			p = bi.Path
+		}
+		codeSizes[p] += int64(fn.End - fn.Entry)
+	}
+	return nil
+}
+
+// maxCodeSize returns the largest code size of the packages of pkgKeys.
+func maxCodeSize(pkgKeys []string) int64 {
+	var max int64
+	for _, name := range pkgKeys {
+		if n := codeSizes[name]; n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// decorateCodeSize draws the label of a package adding n bytes of code, of
+// at most max, from 14 to 28 points with its share of the largest package
+// of the executable.
+func decorateCodeSize(a *attrs, n, max int64) {
+	if max == 0 {
+		return
+	}
+	a.set("fontsize", strconv.FormatInt(14+14*n/max, 10))
+	a.appendAttr("tooltip", formatSize(n)+" of code", `\n`)
+}
+
+// formatSize formats n bytes in the largest binary unit it reaches.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// reachedSize returns the bytes of code of the packages reachable from
+// roots without going through skip.
+func reachedSize(roots []string, skip string) int64 {
+	var from []string
+	for _, root := range roots {
+		if root != skip {
+			from = append(from, root)
+		}
+	}
+	var n int64
+	for name := range reachable(from, func(name string) []string {
+		var next []string
+		for _, imp := range drawnImports(name) {
+			if imp != skip {
+				next = append(next, imp)
+			}
+		}
+		return next
+	}) {
+		n += codeSizes[name]
+	}
+	return n
+}
+
+// importChain returns a shortest chain of imports from one of roots to
+// name, or none if name isn't reached.
+func importChain(roots []string, name string) []string {
+	via := make(map[string]string)
+	queue := append([]string(nil), roots...)
+	for _, root := range roots {
+		via[root] = ""
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == name {
+			var chain []string
+			for p := cur; p != ""; p = via[p] {
+				chain = append([]string{p}, chain...)
+			}
+			return chain
+		}
+		for _, imp := range drawnImports(cur) {
+			if _, ok := via[imp]; !ok {
+				via[imp] = cur
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+// reportCodeSizes writes the packages of pkgKeys that pull the most code
+// into the executable of -size, by the bytes of the packages reached from
+// roots only through them, with the chain of imports reaching them from a
+// root, to w: the imports to cut for a smaller binary.
+func reportCodeSizes(w io.Writer, roots, pkgKeys []string) {
+	total := reachedSize(roots, "")
+	type weight struct {
+		name      string
+		own, only int64
+	}
+	var weights []weight
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || containsString(roots, name) {
+			continue
+		}
+		if only := total - reachedSize(roots, name); only > 0 {
+			weights = append(weights, weight{name, codeSizes[name], only})
+		}
+	}
+	if len(weights) == 0 {
+		return
+	}
+	sort.Slice(weights, func(i, j int) bool {
+		if weights[i].only != weights[j].only {
+			return weights[i].only > weights[j].only
+		}
+		return weights[i].name < weights[j].name
+	})
+	if len(weights) > 10 {
+		weights = weights[:10]
+	}
+	fmt.Fprintf(w, "packages pulling the most code into %s, of %s:\n", *sizeBinary, formatSize(total))
+	for _, wt := range weights {
+		fmt.Fprintf(w, "\t%s: %s with what only it imports, %s itself, via %s\n", wt.name, formatSize(wt.only), formatSize(wt.own), strings.Join(importChain(roots, wt.name), " -> "))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// snapshotVersion is the version of the snapshot format, on its first line.
+const snapshotVersion = 1
+
+// graphSnapshot returns the canonical serialization of the graph of the named
+// packages: a version line, then a line for every package and every edge,
+// sorted. It holds nothing but the structure of the graph, so two graphs
+// have the same snapshot exactly when they have the same packages and edges.
+func graphSnapshot(pkgKeys []string) string {
+	var lines []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) {
+			continue
+		}
+		lines = append(lines, "package "+name)
+		for _, imp := range edgeImports(pkg) {
+			lines = append(lines, "import "+name+" "+imp)
+		}
+	}
+	sort.Strings(lines)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "godepgraph snapshot %d\n", snapshotVersion)
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// writeSnapshot writes the snapshot of the graph of the named packages to w.
+func writeSnapshot(w io.Writer, pkgKeys []string) error {
+	_, err := io.WriteString(w, graphSnapshot(pkgKeys))
+	return err
+}
+
+// writeHash writes the SHA-256 hash of the snapshot of the graph of the named
+// packages to w, in hex.
+func writeHash(w io.Writer, pkgKeys []string) error {
+	_, err := fmt.Fprintf(w, "%x\n", sha256.Sum256([]byte(graphSnapshot(pkgKeys))))
+	return err
+}
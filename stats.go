@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// A phase is a step of the run timed for -stats.
+type phase struct {
+	name string
+	took time.Duration
+}
+
+var (
+	phases    []phase
+	runStart  = time.Now()
+	statsDone bool
+
+	// cacheHits and cacheMisses count the packages the -cache served and
+	// those it had to resolve.
+	cacheHits, cacheMisses int64
+)
+
+// timePhase records the time since start as spent in the phase name.
+func timePhase(name string, start time.Time) {
+	phases = append(phases, phase{name, time.Since(start)})
+}
+
+// reportStats writes, with -stats, the time spent in every phase of the run
+// and the number of packages resolved to w.
+func reportStats(w io.Writer) {
+	if !*showStats || statsDone {
+		return
+	}
+	statsDone = true
+	for _, p := range phases {
+		fmt.Fprintf(w, "%-20s %v\n", p.name, p.took.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "%-20s %v\n", "total", time.Since(runStart).Round(time.Millisecond))
+	fmt.Fprintf(w, "%d packages, %d failed to load", len(pkgs), len(failures))
+	if *cacheDir != "" {
+		fmt.Fprintf(w, ", %d from the cache, %d resolved", atomic.LoadInt64(&cacheHits), atomic.LoadInt64(&cacheMisses))
+	}
+	fmt.Fprintln(w)
+}
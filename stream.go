@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"go/build"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// streamFlags are the flags -stream honors. The others need the whole graph
+// before anything is written.
+var streamFlags = map[string]bool{
+	"stream": true, "format": true, "o": true, "config": true, "v": true,
+	"s": true, "d": true, "t": true, "i": true, "p": true, "ignore-regex": true,
+	"k": true, "missing": true, "tags": true, "go": true, "gopath": true,
+	"goos": true, "goarch": true, "cgo": true, "work": true, "remote": true,
+	"gephi": true, "j": true,
+}
+
+// streamConflict returns the name of the first flag set that -stream can't
+// honor, or "".
+func streamConflict() string {
+	var name string
+	flag.Visit(func(f *flag.Flag) {
+		if name == "" && !streamFlags[flagName(f.Name)] {
+			name = f.Name
+		}
+	})
+	return name
+}
+
+// streamGraph writes the graph of roots in the -format as graph.Walk
+// discovers it, each package as it is loaded and each edge once both its
+// packages are written, instead of loading every package before writing
+// anything. Only import paths are kept in memory, and the output is in the
+// order of discovery rather than sorted.
+func streamGraph(cwd string, roots []string) {
+	if name := streamConflict(); name != "" {
+		log.Fatalf("-stream can't be combined with -%s", name)
+	}
+	newWriter := render.Lookup(*outputFormat)
+	if newWriter == nil {
+		log.Fatalf("-stream needs a format registered with package render, one of %s", strings.Join(render.Formats(), ", "))
+	}
+
+	out := newOutput(*outputPath)
+	w := newWriter(out)
+	if *gephiURL != "" {
+		gw, err := newGephiWriter(*gephiURL)
+		if err != nil {
+			log.Fatalf("invalid -gephi URL: %s", err)
+		}
+		w = teeWriter{w, gw}
+	}
+	drawn := make(map[string]bool)
+	opts := graph.Options{
+		Context: &buildContext,
+		Import: func(path, srcDir string) (*build.Package, error) {
+			return importPackage(loaderPath(path, srcDir), srcDir)
+		},
+		Resolve:      resolvedImport,
+		Jobs:         *loadJobs,
+		IgnoreStdlib: *ignoreStdlib,
+		DelveGoroot:  *delveGoroot,
+		IncludeTests: *includeTests,
+		Ignore:       isIgnoredPath,
+		KeepGoing:    *keepGoing || *showMissing,
+	}
+	err := w.Begin()
+	if err == nil {
+		err = graph.Walk(cwd, roots, opts, graph.Visitor{
+			Package: func(pkg *build.Package) error {
+				if *verbose {
+					debugf("importing %s\n", pkg.ImportPath)
+				}
+				drawn[pkg.ImportPath] = true
+				return w.Node(graph.Package{
+					ImportPath: pkg.ImportPath,
+					Dir:        pkg.Dir,
+					Goroot:     pkg.Goroot,
+					Cgo:        len(pkg.CgoFiles) > 0,
+				})
+			},
+			Import: func(from *build.Package, to string) error {
+				if !drawn[to] {
+					return nil
+				}
+				return w.Edge(graph.Edge{From: from.ImportPath, To: to})
+			},
+			Missing: func(path string, err error) error {
+				failures[path] = err
+				if !*showMissing {
+					return nil
+				}
+				drawn[path] = true
+				return w.Node(graph.Package{ImportPath: path, Missing: true, Error: strings.TrimSpace(err.Error())})
+			},
+		})
+	}
+	if err == nil {
+		err = w.End()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := out.close(); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputPath, err)
+	}
+	if *keepGoing && len(failures) > 0 {
+		reportFailures(os.Stderr)
+		exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeSummary writes, instead of the graph, the number of packages of the
+// graph of pkgKeys, by where they come from, of imports, of imports in its
+// longest chain and of import cycles to w.
+func writeSummary(w io.Writer, pkgKeys []string) {
+	g := jsonGraphOf(pkgKeys)
+	imports, _ := g.Adjacency()
+	std, missing := 0, 0
+	for _, p := range g.Packages {
+		switch {
+		case p.Goroot:
+			std++
+		case p.Missing:
+			missing++
+		}
+	}
+	external := len(externalPackages(pkgKeys, rootPaths))
+	edges := 0
+	for _, names := range imports {
+		edges += len(names)
+	}
+	fmt.Fprintf(w, "%-12s %d\n", "packages", len(g.Packages))
+	fmt.Fprintf(w, "%-12s %d\n", "  internal", len(g.Packages)-std-external-missing)
+	fmt.Fprintf(w, "%-12s %d\n", "  external", external)
+	fmt.Fprintf(w, "%-12s %d\n", "  stdlib", std)
+	if missing > 0 {
+		fmt.Fprintf(w, "%-12s %d\n", "  missing", missing)
+	}
+	fmt.Fprintf(w, "%-12s %d\n", "imports", edges)
+	fmt.Fprintf(w, "%-12s %d\n", "max depth", maxDepth(g, imports))
+	fmt.Fprintf(w, "%-12s %d\n", "cycles", countCycles(g, imports))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// edgeSymbols holds, with -symbols, the exported identifiers of every
+// import that its importer refers to, sorted, by importer and import.
+var edgeSymbols map[[2]string][]string
+
+// A symbolImporter type-checks the packages of the graph from their source
+// for -symbols, and leaves the standard library and the packages outside the
+// graph to the source importer of go/importer.
+type symbolImporter struct {
+	fset     *token.FileSet
+	checked  map[string]*checkedPackage
+	fallback types.ImporterFrom
+}
+
+// A checkedPackage is a package type-checked by a symbolImporter, with the
+// uses of identifiers in its files.
+type checkedPackage struct {
+	pkg  *types.Package
+	info *types.Info
+}
+
+func newSymbolImporter() *symbolImporter {
+	fset := token.NewFileSet()
+	return &symbolImporter{
+		fset:     fset,
+		checked:  make(map[string]*checkedPackage),
+		fallback: importer.ForCompiler(fset, "source", nil).(types.ImporterFrom),
+	}
+}
+
+func (si *symbolImporter) Import(path string) (*types.Package, error) {
+	return si.ImportFrom(path, "", 0)
+}
+
+// ImportFrom resolves path as imported from dir like the loaders did, so
+// that the packages it returns have the import paths of the graph.
+func (si *symbolImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if build.IsLocalImport(path) && dir != "" {
+		path = localImportPath(filepath.Join(dir, filepath.FromSlash(path)))
+	} else if v := vendoredPath(dir, path); v != "" {
+		path = v
+	}
+	pkg := pkgs[path]
+	if pkg == nil || pkg.Goroot {
+		return si.fallback.ImportFrom(path, dir, mode)
+	}
+	cp, err := si.check(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return cp.pkg, nil
+}
+
+// check type-checks the non-test files of pkg once, recording the uses of
+// identifiers. Type errors don't stop it, as the identifiers resolved are
+// all that is needed.
+func (si *symbolImporter) check(pkg *node) (*checkedPackage, error) {
+	if cp, ok := si.checked[pkg.ImportPath]; ok {
+		if cp == nil {
+			return nil, fmt.Errorf("import cycle through %s", pkg.ImportPath)
+		}
+		return cp, nil
+	}
+	si.checked[pkg.ImportPath] = nil
+	var files []*ast.File
+	for _, name := range pkg.GoFiles[:len(pkg.GoFiles)-pkg.TestGoFiles] {
+		f, err := parser.ParseFile(si.fset, filepath.Join(pkg.Dir, name), nil, 0)
+		if err != nil {
+			delete(si.checked, pkg.ImportPath)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{
+		Importer:    si,
+		FakeImportC: true,
+		Error:       func(error) {},
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	tp, _ := conf.Check(pkg.ImportPath, si.fset, files, info)
+	cp := &checkedPackage{tp, info}
+	si.checked[pkg.ImportPath] = cp
+	return cp, nil
+}
+
+// computeSymbols records in edgeSymbols the exported identifiers each
+// package of the graph refers to in each of its imports.
+func computeSymbols(pkgKeys []string) {
+	edgeSymbols = make(map[[2]string][]string)
+	si := newSymbolImporter()
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		cp, err := si.check(pkg)
+		if err != nil {
+			log.Printf("failed to type-check %s: %s", name, err)
+			continue
+		}
+		used := make(map[string]map[string]bool)
+		for _, obj := range cp.info.Uses {
+			p := obj.Pkg()
+			if p == nil || p == cp.pkg || !obj.Exported() || obj.Parent() != p.Scope() {
+				continue
+			}
+			if used[p.Path()] == nil {
+				used[p.Path()] = make(map[string]bool)
+			}
+			used[p.Path()][obj.Name()] = true
+		}
+		for imp, ids := range used {
+			var syms []string
+			for id := range ids {
+				syms = append(syms, id)
+			}
+			sort.Strings(syms)
+			edgeSymbols[[2]string{name, imp}] = syms
+		}
+	}
+}
+
+// reportSymbols writes the imports of the graph through which only one or
+// two identifiers are used, candidates for copying them or moving them to a
+// lighter package, to w.
+func reportSymbols(w io.Writer, pkgKeys []string) {
+	var lines []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		for _, imp := range edgeImports(pkg) {
+			if syms := edgeSymbols[[2]string{name, imp}]; len(syms) > 0 && len(syms) <= 2 {
+				lines = append(lines, fmt.Sprintf("\t%s -> %s: %s", name, imp, strings.Join(syms, ", ")))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "imports used for one or two identifiers:")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
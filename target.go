@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A targetPreset bundles the build settings of a common deployment target,
+// selected with -target. Empty values keep the host's.
+type targetPreset struct {
+	GOOS, GOARCH string
+	// Cgo is the value of -cgo, 0 or 1.
+	Cgo  string
+	Tags []string
+}
+
+// targetPresets are the -target presets by name.
+var targetPresets = map[string]targetPreset{
+	"wasm":          {GOOS: "js", GOARCH: "wasm", Cgo: "0"},
+	"wasip1":        {GOOS: "wasip1", GOARCH: "wasm", Cgo: "0"},
+	"linux-static":  {GOOS: "linux", Cgo: "0", Tags: []string{"netgo", "osusergo"}},
+	"linux-arm64":   {GOOS: "linux", GOARCH: "arm64", Cgo: "0"},
+	"linux-cgo":     {GOOS: "linux", Cgo: "1"},
+	"darwin-arm64":  {GOOS: "darwin", GOARCH: "arm64", Cgo: "1"},
+	"windows":       {GOOS: "windows", GOARCH: "amd64", Cgo: "0"},
+	"windows-cgo":   {GOOS: "windows", GOARCH: "amd64", Cgo: "1"},
+	"android-arm64": {GOOS: "android", GOARCH: "arm64", Cgo: "1"},
+	"ios-arm64":     {GOOS: "ios", GOARCH: "arm64", Cgo: "1"},
+}
+
+// targetPresetNames returns the sorted names of targetPresets.
+func targetPresetNames() []string {
+	var names []string
+	for name := range targetPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// useTargetPreset sets -goos, -goarch and -cgo to the values of the preset
+// name, but those given themselves, and adds its tags to buildTags.
+func useTargetPreset(name string) error {
+	p, ok := targetPresets[name]
+	if !ok {
+		return fmt.Errorf("unknown target %q, want one of %s", name, strings.Join(targetPresetNames(), ", "))
+	}
+	if *targetOS == "" {
+		*targetOS = p.GOOS
+	}
+	if *targetArch == "" {
+		*targetArch = p.GOARCH
+	}
+	if *cgoEnabled == "" {
+		*cgoEnabled = p.Cgo
+	}
+	for _, tag := range p.Tags {
+		if !containsString(buildTags, tag) {
+			buildTags = append(buildTags, tag)
+		}
+	}
+	return nil
+}
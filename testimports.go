@@ -0,0 +1,48 @@
+package main
+
+import "go/build"
+
+// setTestImports records, with -t, which of the imports of pkg, loaded from
+// bp, only its tests make and which both its tests and its other files
+// make, for the edges of the one to be drawn apart and those of the other
+// to be drawn as both.
+func setTestImports(pkg *node, bp *build.Package) {
+	if !*includeTests {
+		return
+	}
+	code := make(map[string]bool)
+	for _, imp := range bp.Imports {
+		code[resolvedImport(bp.Dir, imp)] = true
+	}
+	var tests []string
+	if internalTests() {
+		tests = bp.TestImports
+	}
+	if externalTests() && !*splitXTests {
+		tests = append(tests[:len(tests):len(tests)], bp.XTestImports...)
+	}
+	tested := make(map[string]bool)
+	for _, imp := range tests {
+		tested[resolvedImport(bp.Dir, imp)] = true
+	}
+	for _, imp := range pkg.Imports {
+		switch {
+		case !tested[imp]:
+		case code[imp]:
+			pkg.SharedTestImports = append(pkg.SharedTestImports, imp)
+		default:
+			pkg.TestImports = append(pkg.TestImports, imp)
+		}
+	}
+}
+
+// isTestImport reports whether pkg imports imp only through its tests.
+func isTestImport(pkg *node, imp string) bool {
+	return containsString(pkg.TestImports, imp)
+}
+
+// isSharedTestImport reports whether both the tests of pkg and its other
+// files import imp.
+func isSharedTestImport(pkg *node, imp string) bool {
+	return containsString(pkg.SharedTestImports, imp)
+}
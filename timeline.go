@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// timelineKinds are the values of -timeline.
+var timelineKinds = []string{"monthly", "weekly", "tags"}
+
+// A timelinePoint is a revision of the -timeline and the size of its graph:
+// its packages, edges, external packages, those outside the repository or
+// vendored into it, and the modules of those.
+type timelinePoint struct {
+	Revision string
+	Commit   string
+	Date     time.Time
+	Packages int
+	Edges    int
+	External int
+	Modules  int
+	// Graph is the file of the -timeline-dir holding the graph of the
+	// revision.
+	Graph string `json:",omitempty"`
+}
+
+// timelineRevisions returns the revisions of -timeline in the history of
+// HEAD of the repository containing cwd, oldest first and at most max of
+// them: the last commit of every month or week with commits along the first
+// parents, or the tags, by the dates of their commits.
+func timelineRevisions(cwd, kind string, max int) ([]timelinePoint, error) {
+	var points []timelinePoint
+	if kind == "tags" {
+		out, err := git(cwd, "tag", "--merged", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range strings.Fields(out) {
+			p, err := commitPoint(cwd, tag+"^{commit}")
+			if err != nil {
+				return nil, err
+			}
+			p.Revision = tag
+			points = append(points, p)
+		}
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].Date.Before(points[j].Date)
+		})
+	} else {
+		out, err := git(cwd, "log", "--first-parent", "--format=%H %cI", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(out, "\n") {
+			p, err := parsePoint(line)
+			if err != nil {
+				return nil, err
+			}
+			p.Revision = p.Date.Format("2006-01")
+			if kind == "weekly" {
+				year, week := p.Date.ISOWeek()
+				p.Revision = fmt.Sprintf("%d-W%02d", year, week)
+			}
+			// The log is newest first, so the first commit of a period
+			// is its last.
+			if !seen[p.Revision] {
+				seen[p.Revision] = true
+				points = append([]timelinePoint{p}, points...)
+			}
+		}
+	}
+	if len(points) > max {
+		points = points[len(points)-max:]
+	}
+	return points, nil
+}
+
+// commitPoint returns the point of the commit rev with its hash and date.
+func commitPoint(cwd, rev string) (timelinePoint, error) {
+	out, err := git(cwd, "log", "-1", "--format=%H %cI", rev)
+	if err != nil {
+		return timelinePoint{}, err
+	}
+	return parsePoint(out)
+}
+
+// parsePoint parses a line of the hash and committer date of a commit.
+func parsePoint(line string) (timelinePoint, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return timelinePoint{}, fmt.Errorf("unexpected git log line %q", line)
+	}
+	date, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return timelinePoint{}, err
+	}
+	return timelinePoint{Commit: fields[0], Date: date}, nil
+}
+
+// runTimeline implements -timeline: it computes the graph at every revision
+// of the timeline, as -compare-ref does that of its ref, writes it to the
+// -timeline-dir if given, and writes the growth of the graphs, as a table or
+// with -format json as JSON, to w.
+func runTimeline(w io.Writer, cwd string) error {
+	points, err := timelineRevisions(cwd, *timelineBy, *timelineMax)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no %s revisions in the history of HEAD", *timelineBy)
+	}
+	if *timelineDir != "" {
+		if err := os.MkdirAll(*timelineDir, 0777); err != nil {
+			return err
+		}
+	}
+	for i := range points {
+		p := &points[i]
+		if *verbose {
+			debugf("graphing %s at %s (%d of %d)\n", p.Revision, p.Commit, i+1, len(points))
+		}
+		err := inWorktree(cwd, p.Commit, func(top, dir string) error {
+			var extra []string
+			if findGoMod(dir) != "" {
+				// The modules of the packages tell the external ones.
+				extra = append(extra, "-versions")
+			}
+			g, err := runGraph(dir, refArgs(cwd, extra...))
+			if err != nil {
+				return err
+			}
+			countTimeline(p, g, top)
+			if *timelineDir == "" {
+				return nil
+			}
+			p.Graph = filepath.Join(*timelineDir, strings.Replace(p.Revision, "/", "_", -1)+".json")
+			return writeTimelineGraph(p.Graph, g, top)
+		})
+		if err != nil {
+			return fmt.Errorf("graph of %s: %s", p.Revision, err)
+		}
+	}
+	if *outputFormat == "json" {
+		return writeIndentedJSON(w, points)
+	}
+	return writeTimelineTable(w, points)
+}
+
+// countTimeline records the size of the graph g of the revision checked out
+// in top in p. The packages of the standard library are counted, but not as
+// external ones.
+func countTimeline(p *timelinePoint, g *jsonGraph, top string) {
+	p.Packages = len(g.Packages)
+	for _, e := range g.Edges {
+		if e.Kind == "" {
+			p.Edges++
+		}
+	}
+	modules := make(map[string]bool)
+	for _, pkg := range g.Packages {
+		if pkg.Goroot || pkg.Missing || inTree(pkg.Dir, top) {
+			continue
+		}
+		p.External++
+		if pkg.Module != "" {
+			modules[pkg.Module] = true
+		}
+	}
+	p.Modules = len(modules)
+}
+
+// inTree reports whether the package directory dir is part of the tree
+// checked out in top and not vendored into it.
+func inTree(dir, top string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(top, dir)
+	if err != nil || isParentRel(rel) {
+		return false
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+		if elem == "vendor" {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTimelineGraph writes the graph g of the revision checked out in top
+// to the file path, with the directories of the packages of the worktree,
+// which is removed, made relative to it.
+func writeTimelineGraph(path string, g *jsonGraph, top string) error {
+	for i := range g.Packages {
+		pkg := &g.Packages[i]
+		if rel, err := filepath.Rel(top, pkg.Dir); pkg.Dir != "" && err == nil && !isParentRel(rel) {
+			pkg.Dir = filepath.ToSlash(rel)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeIndentedJSON(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTimelineTable writes the growth of the graphs of points to w as a
+// table, every count followed by its change from the previous revision.
+func writeTimelineTable(w io.Writer, points []timelinePoint) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "revision\tcommit\tdate\tpackages\tedges\texternal\tmodules")
+	for i, p := range points {
+		prev := p
+		if i > 0 {
+			prev = points[i-1]
+		}
+		fmt.Fprintf(tw, "%s\t%.12s\t%s\t%s\t%s\t%s\t%s\n", p.Revision, p.Commit, p.Date.Format("2006-01-02"),
+			growth(p.Packages, prev.Packages, i), growth(p.Edges, prev.Edges, i),
+			growth(p.External, prev.External, i), growth(p.Modules, prev.Modules, i))
+	}
+	return tw.Flush()
+}
+
+// growth formats the count n of the revision i of a timeline with its
+// change from the count prev of the revision before.
+func growth(n, prev, i int) string {
+	if i == 0 || n == prev {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%d (%+d)", n, n-prev)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// toolImports returns the imports of the package name in dir that only its
+// tools.go-style files make: files guarded by the tools build tag, by which
+// modules track the commands they build with. All the files of dir are
+// read, as those guarded by the tag are usually excluded from the build.
+func toolImports(dir, name string) map[string]bool {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	tools := make(map[string]bool)
+	other := make(map[string]bool)
+	for _, fi := range fis {
+		file := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, file), nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil || f.Name.Name != name {
+			continue
+		}
+		imports := other
+		for _, c := range f.Comments {
+			if c.Pos() > f.Package {
+				break
+			}
+			for _, line := range c.List {
+				if x, err := constraint.Parse(line.Text); err == nil && needsToolsTag(x) {
+					imports = tools
+				}
+			}
+		}
+		for _, spec := range f.Imports {
+			if imp, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[imp] = true
+			}
+		}
+	}
+	for imp := range tools {
+		if other[imp] {
+			delete(tools, imp)
+		}
+	}
+	return tools
+}
+
+// needsToolsTag reports whether the build constraint x only holds with the
+// tools tag.
+func needsToolsTag(x constraint.Expr) bool {
+	return x.Eval(func(tag string) bool { return tag == "tools" }) && !x.Eval(func(string) bool { return false })
+}
+
+// splitToolImports returns, for -tools, the indexes in the imports of pkg of
+// those only its tools.go files make, before the imports are resolved.
+func splitToolImports(pkg *node, name string) []int {
+	if pkg.Goroot {
+		return nil
+	}
+	tools := toolImports(pkg.Dir, name)
+	var idx []int
+	for i, imp := range pkg.Imports {
+		if tools[imp] {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// setToolImports records the resolved tool imports of pkg, at the indexes
+// splitToolImports returned, and with -tools exclude drops them.
+func setToolImports(pkg *node, idx []int) {
+	if len(idx) == 0 {
+		return
+	}
+	if *toolDeps == "exclude" {
+		drop := make(map[int]bool)
+		for _, i := range idx {
+			drop[i] = true
+		}
+		var kept []string
+		for i, imp := range pkg.Imports {
+			if !drop[i] {
+				kept = append(kept, imp)
+			}
+		}
+		pkg.Imports = kept
+		return
+	}
+	for _, i := range idx {
+		pkg.ToolImports = append(pkg.ToolImports, pkg.Imports[i])
+	}
+}
+
+// isToolImport reports whether pkg imports imp only through its tools.go
+// files.
+func isToolImport(pkg *node, imp string) bool {
+	for _, t := range pkg.ToolImports {
+		if t == imp {
+			return true
+		}
+	}
+	return false
+}
+
+// toolPackages returns, with -tools mark, the packages of the graph that
+// roots only reach through tool imports: the developer tooling, as opposed
+// to the dependencies of the code.
+func toolPackages(roots []string) map[string]bool {
+	if *toolDeps != "mark" {
+		return nil
+	}
+	runtime := make(map[string]bool)
+	stack := append([]string{}, roots...)
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		pkg := pkgs[name]
+		if runtime[name] || pkg == nil {
+			continue
+		}
+		runtime[name] = true
+		for _, imp := range getImports(pkg) {
+			if !isToolImport(pkg, imp) {
+				stack = append(stack, imp)
+			}
+		}
+	}
+	tools := make(map[string]bool)
+	for name, pkg := range pkgs {
+		if !runtime[name] && !isIgnored(pkg) {
+			tools[name] = true
+		}
+	}
+	return tools
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"go/types"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/kisielk/godepgraph/graph"
+	"github.com/kisielk/godepgraph/render"
+)
+
+// writeTypeGraph writes, instead of the package graph, the graph of the
+// exported types of the packages of pkgKeys in the -format, each type a node
+// named by the import path of its package and its name, and an edge from
+// every type to each exported type of another package of the graph it
+// embeds, holds in a field or refers to in the signature of a method, which
+// the edge lists the fields and methods of. Types depending on no other
+// package, and not depended on, are left out.
+func writeTypeGraph(pkgKeys []string) {
+	if render.Lookup(*outputFormat) == nil {
+		log.Fatalf("-types needs a format registered with package render, one of %s", strings.Join(render.Formats(), ", "))
+	}
+	si := newSymbolImporter()
+	nodes := make(map[string]graph.Package)
+	via := make(map[[2]string]map[string]bool)
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if isIgnored(pkg) || pkg.Goroot {
+			continue
+		}
+		cp, err := si.check(pkg)
+		if err != nil {
+			log.Printf("failed to type-check %s: %s", name, err)
+			continue
+		}
+		scope := cp.pkg.Scope()
+		for _, id := range scope.Names() {
+			obj, ok := scope.Lookup(id).(*types.TypeName)
+			if !ok || !obj.Exported() || obj.IsAlias() {
+				continue
+			}
+			from := typeNodeName(obj)
+			for _, ref := range typeRefs(obj) {
+				to := ref.obj
+				if to.Pkg() == nil || to.Pkg() == cp.pkg || !to.Exported() {
+					continue
+				}
+				toPkg := pkgs[to.Pkg().Path()]
+				if toPkg == nil || isIgnored(toPkg) {
+					continue
+				}
+				nodes[from] = graph.Package{ImportPath: from, Dir: pkg.Dir}
+				toName := typeNodeName(to)
+				nodes[toName] = graph.Package{ImportPath: toName, Dir: toPkg.Dir, Goroot: toPkg.Goroot}
+				key := [2]string{from, toName}
+				if via[key] == nil {
+					via[key] = make(map[string]bool)
+				}
+				if ref.via != "" {
+					via[key][ref.via] = true
+				}
+			}
+		}
+	}
+
+	g := graph.New()
+	for _, n := range nodes {
+		g.Packages = append(g.Packages, n)
+	}
+	for key, names := range via {
+		e := graph.Edge{From: key[0], To: key[1]}
+		for name := range names {
+			e.Symbols = append(e.Symbols, name)
+		}
+		sort.Strings(e.Symbols)
+		g.Edges = append(g.Edges, e)
+	}
+	g.Sort()
+
+	out := newOutput(*outputPath)
+	if err := render.Write(out, *outputFormat, g); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputFormat, err)
+	}
+	if err := out.close(); err != nil {
+		log.Fatalf("failed to write %s: %s", *outputPath, err)
+	}
+}
+
+// typeNodeName returns the name of the node of obj in type graphs.
+func typeNodeName(obj *types.TypeName) string {
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// A typeRef is a reference of a type to the named type obj through its
+// field or method via, or through its underlying type if via is "".
+type typeRef struct {
+	obj *types.TypeName
+	via string
+}
+
+// typeRefs returns the references of the type obj names to named types:
+// those of its underlying type, through the fields of a struct and the
+// methods and embedded interfaces of an interface, and those of the
+// signatures of its exported methods.
+func typeRefs(obj *types.TypeName) []typeRef {
+	var refs []typeRef
+	add := func(via string, t types.Type) {
+		for _, n := range namedTypes(t) {
+			refs = append(refs, typeRef{n, via})
+		}
+	}
+	switch u := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Exported() || f.Embedded() {
+				add(f.Name(), f.Type())
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < u.NumEmbeddeds(); i++ {
+			t := u.EmbeddedType(i)
+			if n, ok := t.(*types.Named); ok {
+				add(n.Obj().Name(), t)
+			} else {
+				add("", t)
+			}
+		}
+		for i := 0; i < u.NumExplicitMethods(); i++ {
+			m := u.ExplicitMethod(i)
+			add(m.Name(), m.Type())
+		}
+	default:
+		add("", u)
+	}
+	if n, ok := obj.Type().(*types.Named); ok {
+		for i := 0; i < n.NumMethods(); i++ {
+			if m := n.Method(i); m.Exported() {
+				add(m.Name(), m.Type())
+			}
+		}
+	}
+	return refs
+}
+
+// namedTypes returns the named types t is made of, stopping at each: the
+// fields of a named struct, for one, are its own references.
+func namedTypes(t types.Type) []*types.TypeName {
+	var names []*types.TypeName
+	var walk func(t types.Type)
+	walk = func(t types.Type) {
+		switch t := types.Unalias(t).(type) {
+		case *types.Named:
+			names = append(names, t.Obj())
+			if args := t.TypeArgs(); args != nil {
+				for i := 0; i < args.Len(); i++ {
+					walk(args.At(i))
+				}
+			}
+		case *types.Pointer:
+			walk(t.Elem())
+		case *types.Slice:
+			walk(t.Elem())
+		case *types.Array:
+			walk(t.Elem())
+		case *types.Chan:
+			walk(t.Elem())
+		case *types.Map:
+			walk(t.Key())
+			walk(t.Elem())
+		case *types.Signature:
+			for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+				for i := 0; i < tuple.Len(); i++ {
+					walk(tuple.At(i).Type())
+				}
+			}
+		case *types.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				walk(t.Field(i).Type())
+			}
+		case *types.Interface:
+			for i := 0; i < t.NumMethods(); i++ {
+				walk(t.Method(i).Type())
+			}
+		}
+	}
+	walk(t)
+	return names
+}
@@ -0,0 +1,53 @@
+package main
+
+func importsUnsafe(pkg *node) bool {
+	for _, imp := range getImports(pkg) {
+		if imp == "unsafe" {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeUsers returns the packages that import unsafe directly and, if
+// transitive is set, those that reach it through their imports.
+func unsafeUsers(transitive bool) (direct, indirect map[string]bool) {
+	direct = make(map[string]bool)
+	indirect = make(map[string]bool)
+	for name, pkg := range pkgs {
+		if importsUnsafe(pkg) {
+			direct[name] = true
+		}
+	}
+	if !transitive {
+		return direct, indirect
+	}
+	reach := taint(func(pkg *node) []string {
+		if direct[pkg.ImportPath] {
+			return []string{"unsafe"}
+		}
+		return nil
+	})
+	for name := range reach {
+		if !direct[name] {
+			indirect[name] = true
+		}
+	}
+	return direct, indirect
+}
+
+// decorateUnsafe renders direct importers of unsafe with a warning shape and
+// packages that only reach it transitively with a lighter variant.
+func decorateUnsafe(a *attrs, direct, indirect bool) {
+	switch {
+	case direct:
+		a.set("shape", "doubleoctagon")
+		a.set("penwidth", "2")
+		a.set("fontcolor", "red")
+		a.appendAttr("tooltip", "imports unsafe", `\n`)
+	case indirect:
+		a.set("shape", "octagon")
+		a.set("fontcolor", "red")
+		a.appendAttr("tooltip", "reaches unsafe", `\n`)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A variant is one build configuration under which the graph is loaded for
+// comparison with others.
+type variant struct {
+	Name  string
+	Apply func(*build.Context)
+}
+
+// tagVariants returns a variant for each comma-separated set of build tags.
+func tagVariants(sets []string) []variant {
+	var vs []variant
+	for _, set := range sets {
+		var tags []string
+		if set != "" {
+			tags = strings.Split(set, ",")
+		}
+		name := set
+		if name == "" {
+			name = "(no tags)"
+		}
+		vs = append(vs, variant{
+			Name: "tags " + name,
+			Apply: func(ctxt *build.Context) {
+				ctxt.BuildTags = tags
+			},
+		})
+	}
+	return vs
+}
+
+// platformVariants returns a variant for each GOOS/GOARCH pair.
+func platformVariants(platforms []string) ([]variant, error) {
+	var vs []variant
+	for _, p := range platforms {
+		i := strings.IndexByte(p, '/')
+		if i <= 0 || i == len(p)-1 {
+			return nil, fmt.Errorf("malformed platform %q, want GOOS/GOARCH", p)
+		}
+		goos, goarch := p[:i], p[i+1:]
+		vs = append(vs, variant{
+			Name: p,
+			Apply: func(ctxt *build.Context) {
+				setTarget(ctxt, goos, goarch)
+			},
+		})
+	}
+	return vs, nil
+}
+
+// crossVariants returns every combination of a variant of a with one of b.
+func crossVariants(a, b []variant) []variant {
+	var vs []variant
+	for _, va := range a {
+		for _, vb := range b {
+			va, vb := va, vb
+			vs = append(vs, variant{
+				Name: va.Name + " " + vb.Name,
+				Apply: func(ctxt *build.Context) {
+					va.Apply(ctxt)
+					vb.Apply(ctxt)
+				},
+			})
+		}
+	}
+	return vs
+}
+
+// A snapshot is the set of packages and edges of one loaded graph, and of
+// its import cycles, one a strongly connected component as -check cycles
+// reports them.
+type snapshot struct {
+	Packages map[string]bool
+	Edges    map[[2]string]bool
+	Cycles   map[string]bool
+}
+
+// loadVariant loads the graph of the roots matched by args under v and
+// returns its snapshot. The build context, the package caches and the
+// failures are restored afterwards.
+func loadVariant(cwd string, args []string, v variant) (*snapshot, error) {
+	savedContext, savedPkgs, savedListed, savedFailures := buildContext, pkgs, listed, failures
+	defer func() {
+		buildContext, pkgs, listed, failures = savedContext, savedPkgs, savedListed, savedFailures
+	}()
+	v.Apply(&buildContext)
+	pkgs = make(map[string]*node)
+	listed = nil
+	failures = make(map[string]error)
+
+	roots, err := expandRoots(cwd, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := processPackages(cwd, roots); err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{
+		Packages: make(map[string]bool),
+		Edges:    make(map[[2]string]bool),
+		Cycles:   make(map[string]bool),
+	}
+	var keys []string
+	for path, pkg := range pkgs {
+		if isIgnored(pkg) {
+			continue
+		}
+		keys = append(keys, path)
+		snap.Packages[path] = true
+		for _, imp := range edgeImports(pkg) {
+			snap.Edges[[2]string{path, imp}] = true
+		}
+	}
+	sort.Strings(keys)
+	for _, cycle := range cycles(keys) {
+		snap.Cycles[strings.Join(cycle, " -> ")] = true
+	}
+	return snap, nil
+}
+
+// reportVariants writes the packages, edges and import cycles that are not
+// present in the graphs of all variants to w, grouped by the variants they
+// are present in: the cycles listed are those that only some tags or
+// platforms create.
+func reportVariants(w io.Writer, vs []variant, snaps []*snapshot) {
+	type group struct {
+		in       []string
+		packages []string
+		edges    []string
+		cycles   []string
+	}
+	groups := make(map[string]*group)
+	groupFor := func(present func(*snapshot) bool) *group {
+		var in []string
+		for i, snap := range snaps {
+			if present(snap) {
+				in = append(in, vs[i].Name)
+			}
+		}
+		if len(in) == len(snaps) {
+			return nil
+		}
+		key := strings.Join(in, "\x00")
+		g := groups[key]
+		if g == nil {
+			g = &group{in: in}
+			groups[key] = g
+		}
+		return g
+	}
+
+	seenPkgs := make(map[string]bool)
+	seenEdges := make(map[[2]string]bool)
+	for _, snap := range snaps {
+		for path := range snap.Packages {
+			if seenPkgs[path] {
+				continue
+			}
+			seenPkgs[path] = true
+			if g := groupFor(func(s *snapshot) bool { return s.Packages[path] }); g != nil {
+				g.packages = append(g.packages, path)
+			}
+		}
+		for edge := range snap.Edges {
+			if seenEdges[edge] {
+				continue
+			}
+			seenEdges[edge] = true
+			if g := groupFor(func(s *snapshot) bool { return s.Edges[edge] }); g != nil {
+				g.edges = append(g.edges, edge[0]+" -> "+edge[1])
+			}
+		}
+	}
+	seenCycles := make(map[string]bool)
+	for _, snap := range snaps {
+		for cycle := range snap.Cycles {
+			if seenCycles[cycle] {
+				continue
+			}
+			seenCycles[cycle] = true
+			if g := groupFor(func(s *snapshot) bool { return s.Cycles[cycle] }); g != nil {
+				g.cycles = append(g.cycles, cycle)
+			}
+		}
+	}
+
+	if len(groups) == 0 {
+		fmt.Fprintf(w, "%d variants have identical graphs\n", len(vs))
+		return
+	}
+	keys := []string{}
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	// List the groups present in the fewest variants, the most specific
+	// ones, first.
+	sort.Slice(keys, func(i, j int) bool {
+		gi, gj := groups[keys[i]], groups[keys[j]]
+		if len(gi.in) != len(gj.in) {
+			return len(gi.in) < len(gj.in)
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		g := groups[k]
+		sort.Strings(g.packages)
+		sort.Strings(g.edges)
+		sort.Strings(g.cycles)
+		fmt.Fprintf(w, "only with %s:\n", strings.Join(g.in, ", "))
+		if len(g.packages) > 0 {
+			fmt.Fprintln(w, "\tpackages:")
+			for _, p := range g.packages {
+				fmt.Fprintf(w, "\t\t%s\n", p)
+			}
+		}
+		if len(g.edges) > 0 {
+			fmt.Fprintln(w, "\tedges:")
+			for _, e := range g.edges {
+				fmt.Fprintf(w, "\t\t%s\n", e)
+			}
+		}
+		if len(g.cycles) > 0 {
+			fmt.Fprintln(w, "\timport cycles:")
+			for _, c := range g.cycles {
+				fmt.Fprintf(w, "\t\t%s\n", c)
+			}
+		}
+	}
+}
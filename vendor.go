@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// vendorDirs memoizes the vendor directories seen from each package
+// directory, innermost first, for vendoredPath.
+var vendorDirs = make(map[string][]string)
+
+// vendoredPath returns the import path the go command resolves the import
+// imp of the package in dir to when it is vendored: that of the copy in the
+// innermost vendor directory of dir or its parents, below its GOROOT or
+// GOPATH src directory, that holds one, such as a/vendor/x for x. That path
+// names the copy wherever it's imported from, so that roots sharing a
+// vendored tree load it once. It returns "" if imp isn't vendored or dir is
+// in a module, where the go command resolves vendoring itself.
+func vendoredPath(dir, imp string) string {
+	if dir == "" || imp == "C" || strings.HasPrefix(imp, ".") {
+		return ""
+	}
+	vendors, ok := vendorDirs[dir]
+	if !ok {
+		vendors = findVendorDirs(dir)
+		vendorDirs[dir] = vendors
+	}
+	for _, v := range vendors {
+		// As for the go command, a directory without Go files doesn't
+		// shadow the import.
+		files, _ := filepath.Glob(filepath.Join(v, filepath.FromSlash(imp), "*.go"))
+		if len(files) == 0 {
+			continue
+		}
+		for _, src := range srcDirs() {
+			if rel, err := filepath.Rel(src, v); err == nil && !isParentRel(rel) {
+				return path.Join(filepath.ToSlash(rel), imp)
+			}
+		}
+	}
+	return ""
+}
+
+// findVendorDirs returns the vendor directories of dir and its parents
+// below its GOPATH src directory, innermost first, or none if dir is in a
+// module or outside GOPATH. In GOROOT, whose standard library and commands
+// vendor their dependencies in src/vendor and src/cmd/vendor, they are
+// returned in module mode too.
+func findVendorDirs(dir string) []string {
+	goroot := filepath.Join(buildContext.GOROOT, "src")
+	if isBelow(goroot, dir) {
+		return parentVendorDirs(dir, filepath.Dir(goroot))
+	}
+	if os.Getenv("GO111MODULE") != "off" && findGoMod(dir) != "" {
+		return nil
+	}
+	for _, src := range gopathSrcDirs() {
+		if isBelow(src, dir) {
+			return parentVendorDirs(dir, src)
+		}
+	}
+	return nil
+}
+
+// parentVendorDirs returns the vendor directories of dir and its parents
+// below stop, innermost first.
+func parentVendorDirs(dir, stop string) []string {
+	var vendors []string
+	for d := dir; d != stop && d != filepath.Dir(d); d = filepath.Dir(d) {
+		if fi, err := os.Stat(filepath.Join(d, "vendor")); err == nil && fi.IsDir() {
+			vendors = append(vendors, filepath.Join(d, "vendor"))
+		}
+	}
+	return vendors
+}
+
+// isBelow reports whether dir is below root.
+func isBelow(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != "." && !isParentRel(rel)
+}
+
+// isParentRel reports whether the relative path rel, as returned by
+// filepath.Rel, leads out of the directory it is relative to.
+func isParentRel(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// srcDirs returns the src directories of GOROOT and GOPATH, in the order
+// packages are looked up in them.
+func srcDirs() []string {
+	return append([]string{filepath.Join(buildContext.GOROOT, "src")}, gopathSrcDirs()...)
+}
+
+// gopathSrcDirs returns the src directories of the GOPATH of the build
+// context.
+func gopathSrcDirs() []string {
+	var dirs []string
+	for _, p := range filepath.SplitList(buildContext.GOPATH) {
+		if p != "" {
+			dirs = append(dirs, filepath.Join(p, "src"))
+		}
+	}
+	return dirs
+}
+
+// resolveVendored replaces the vendored imports of pkg with the import paths
+// of the copies they resolve to, by which they are loaded and drawn.
+func resolveVendored(pkg *node) {
+	for i, imp := range pkg.Imports {
+		v := vendoredPath(pkg.Dir, imp)
+		if v == "" {
+			continue
+		}
+		pkg.Imports[i] = intern(v)
+		if pos, ok := pkg.ImportPos[imp]; ok {
+			delete(pkg.ImportPos, imp)
+			pkg.ImportPos[v] = append(pkg.ImportPos[v], pos...)
+		}
+	}
+}
+
+// unvendoredPath returns the import path the package at path is vendored
+// as, stripping everything up to its innermost vendor directory.
+func unvendoredPath(path string) string {
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
+
+// unvendorGraph renames the vendored packages of the graph after the import
+// paths they are vendored as, for -V, and returns roots renamed alike. The
+// copies of a package in several vendor directories become one node.
+func unvendorGraph(roots []string) []string {
+	return mergePackages(unvendoredPath, roots, true)
+}
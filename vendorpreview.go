@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// vendorMetaPrefixes are the prefixes of the names of the files go mod
+// vendor copies from the root of a module, and the directories between it
+// and a vendored package, along with the package.
+var vendorMetaPrefixes = []string{"AUTHORS", "CONTRIBUTORS", "COPYLEFT", "COPYING", "COPYRIGHT", "LEGAL", "LICENSE", "NOTICE", "PATENTS"}
+
+// vendoredFiles returns the bytes of the files of dir go mod vendor would
+// copy: all but its tests, go.mod and go.sum. Go files excluded from every
+// build by constraints are counted too, unlike go mod vendor.
+func vendoredFiles(dir string) int64 {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	var n int64
+	for _, fi := range files {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || strings.HasSuffix(name, "_test.go") || name == "go.mod" || name == "go.sum" {
+			continue
+		}
+		n += fi.Size()
+	}
+	return n
+}
+
+// vendoredMetaFiles returns the bytes of the license and notice files go mod
+// vendor would copy for the package in dir of the module in modDir, those
+// of modDir and of the directories between them not counted in seen yet,
+// which it records.
+func vendoredMetaFiles(dir, modDir string, seen map[string]bool) int64 {
+	var n int64
+	for {
+		if !seen[dir] {
+			seen[dir] = true
+			files, _ := ioutil.ReadDir(dir)
+			for _, fi := range files {
+				if fi.Mode().IsRegular() && hasPrefixes(fi.Name(), vendorMetaPrefixes) {
+					n += fi.Size()
+				}
+			}
+		}
+		parent := filepath.Dir(dir)
+		if dir == modDir || parent == dir || !strings.HasPrefix(dir, modDir) {
+			return n
+		}
+		dir = parent
+	}
+}
+
+// reportVendor writes to w the packages of pkgKeys that go mod vendor would
+// copy to vendor/, those of the modules but the main ones, grouped by the
+// module version providing them, with an estimate of the bytes each
+// module would take. go mod vendor also copies the imports of the tests of
+// the main modules, which are only in the graph with -t.
+func reportVendor(w io.Writer, pkgKeys []string) {
+	type vendoredModule struct {
+		path, version string
+		names         []string
+		size          int64
+	}
+	byModule := make(map[*moduleInfo]*vendoredModule)
+	seen := make(map[string]bool)
+	var total int64
+	packages := 0
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		m := packageModule(name)
+		if isIgnored(pkg) || pkg.Goroot || pkg.XTest || m == nil || m.Main {
+			continue
+		}
+		vm := byModule[m]
+		if vm == nil {
+			vm = &vendoredModule{path: m.Path, version: m.Version}
+			if m.Replace != nil {
+				vm.version += " => " + strings.TrimSpace(m.Replace.Path+" "+m.Replace.Version)
+			}
+			byModule[m] = vm
+		}
+		vm.names = append(vm.names, name)
+		modDir := m.Dir
+		if m.Replace != nil {
+			modDir = m.Replace.Dir
+		}
+		size := vendoredFiles(pkg.Dir)
+		if modDir != "" {
+			size += vendoredMetaFiles(pkg.Dir, modDir, seen)
+		}
+		vm.size += size
+		total += size
+		packages++
+	}
+	if packages == 0 {
+		fmt.Fprintln(w, "nothing would be vendored")
+		return
+	}
+
+	var vms []*vendoredModule
+	for _, vm := range byModule {
+		vms = append(vms, vm)
+	}
+	sort.Slice(vms, func(i, j int) bool {
+		return vms[i].path < vms[j].path
+	})
+	fmt.Fprintf(w, "vendor/ would hold %d packages of %d modules, about %s:\n", packages, len(vms), formatSize(total))
+	for _, vm := range vms {
+		fmt.Fprintf(w, "\t%s %s, %s\n", vm.path, vm.version, formatSize(vm.size))
+		for _, name := range vm.names {
+			fmt.Fprintf(w, "\t\t%s\n", name)
+		}
+	}
+}
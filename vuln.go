@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// govulncheckMessage is one message of the JSON stream written by
+// `govulncheck -json`. Only the parts godepgraph uses are decoded.
+type govulncheckMessage struct {
+	OSV *struct {
+		ID      string `json:"id"`
+		Summary string `json:"summary"`
+	} `json:"osv"`
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module  string `json:"module"`
+			Version string `json:"version"`
+			Package string `json:"package"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// vuln is a known vulnerability affecting a package or module.
+type vuln struct {
+	ID      string
+	Summary string
+	Fixed   string
+	Module  string
+	Version string
+}
+
+// vulnIndex holds the vulnerabilities reported for packages and, when no
+// package is known, for whole modules.
+type vulnIndex struct {
+	packages map[string][]vuln
+	modules  map[string][]vuln
+}
+
+// readVulns reads govulncheck JSON output from path, or from stdin if path
+// is "-".
+func readVulns(path string) (*vulnIndex, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	idx := &vulnIndex{
+		packages: make(map[string][]vuln),
+		modules:  make(map[string][]vuln),
+	}
+	summaries := make(map[string]string)
+	seen := make(map[string]bool)
+	var findings []vuln
+	var findingPkgs []string
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg govulncheckMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+		}
+		if f := msg.Finding; f != nil && len(f.Trace) > 0 {
+			// The first frame of a trace is the vulnerable symbol.
+			frame := f.Trace[0]
+			key := f.OSV + " " + frame.Module + " " + frame.Package
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			findings = append(findings, vuln{
+				ID:      f.OSV,
+				Fixed:   f.FixedVersion,
+				Module:  frame.Module,
+				Version: frame.Version,
+			})
+			findingPkgs = append(findingPkgs, frame.Package)
+		}
+	}
+
+	for i, v := range findings {
+		v.Summary = summaries[v.ID]
+		if p := findingPkgs[i]; p != "" {
+			idx.packages[p] = append(idx.packages[p], v)
+		} else {
+			idx.modules[v.Module] = append(idx.modules[v.Module], v)
+		}
+	}
+	return idx, nil
+}
+
+// lookup returns the vulnerabilities affecting the package path, and whether
+// they were reported for the package itself rather than its module.
+func (idx *vulnIndex) lookup(path string) ([]vuln, bool) {
+	if vs := idx.packages[path]; len(vs) > 0 {
+		return vs, true
+	}
+	mods := make([]string, 0, len(idx.modules))
+	for m := range idx.modules {
+		mods = append(mods, m)
+	}
+	if m := moduleFor(path, mods); m != "" {
+		return idx.modules[m], false
+	}
+	return nil, false
+}
+
+// decorateVulns outlines vulnerable packages in red: thick for packages with
+// a reported finding, thin for packages in an affected module.
+func decorateVulns(a *attrs, idx *vulnIndex, path string) {
+	vs, direct := idx.lookup(path)
+	if len(vs) == 0 {
+		return
+	}
+	if a.get("fillcolor") == "" {
+		a.set("fillcolor", a.get("color"))
+	}
+	a.set("color", "red")
+	if direct {
+		a.set("penwidth", "3")
+	} else {
+		a.set("penwidth", "1.5")
+	}
+	for _, v := range vs {
+		a.appendAttr("tooltip", v.String(), `\n`)
+	}
+}
+
+func (v vuln) String() string {
+	s := v.ID
+	if v.Version != "" {
+		s += " in " + v.Module + "@" + v.Version
+	}
+	if v.Fixed != "" {
+		s += ", fixed in " + v.Fixed
+	}
+	return s
+}
+
+// reportVulns writes the vulnerable packages among pkgKeys to w.
+func reportVulns(w io.Writer, idx *vulnIndex, pkgKeys []string) {
+	var lines []string
+	for _, name := range pkgKeys {
+		if isIgnored(pkgs[name]) {
+			continue
+		}
+		vs, _ := idx.lookup(name)
+		for _, v := range vs {
+			line := fmt.Sprintf("\t%s: %s", name, v)
+			if v.Summary != "" {
+				line += " (" + strings.TrimSpace(v.Summary) + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "no known vulnerabilities")
+		return
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(w, "vulnerabilities:")
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchDirsEnv names the file to which a run started by -watch writes the
+// directories of the packages it loaded.
+const watchDirsEnv = "GODEPGRAPH_WATCH_DIRS"
+
+// watchInterval is how often -watch looks for changed files.
+const watchInterval = 500 * time.Millisecond
+
+// rerunArgs returns the command line of this run with overrides added after
+// its flags, before the arguments.
+func rerunArgs(overrides ...string) []string {
+	args := os.Args[1:]
+	flags, rest := args[:len(args)-flag.NArg()], args[len(args)-flag.NArg():]
+	if n := len(flags); n > 0 && flags[n-1] == "--" {
+		flags, rest = flags[:n-1], args[n-1:]
+	}
+	return append(append(append([]string{}, flags...), overrides...), rest...)
+}
+
+// runWatch implements -watch: it runs godepgraph again without -watch
+// whenever a Go file in the directory of a package of the graph changes, or
+// the go.mod or go.sum of the working directory does, so that the -o file
+// follows the imports of the tree.
+func runWatch(cwd string) {
+	dirsFile, err := ioutil.TempFile("", "godepgraph-watch")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dirsFile.Close()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		os.Remove(dirsFile.Name())
+		os.Exit(1)
+	}()
+
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	args := rerunArgs("-watch=false")
+	var dirs []string
+	for {
+		cmd := exec.Command(self, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), watchDirsEnv+"="+dirsFile.Name())
+		if err := cmd.Run(); err != nil {
+			log.Printf("godepgraph failed: %s", err)
+		} else {
+			log.Printf("updated %s", *outputPath)
+		}
+		if names, err := readRootsFile(dirsFile.Name()); err == nil && len(names) > 0 {
+			dirs = names
+		}
+
+		files := watchedFiles(cwd, dirs)
+		for {
+			time.Sleep(watchInterval)
+			if changed := watchedFiles(cwd, dirs); !sameModTimes(files, changed) {
+				break
+			}
+		}
+	}
+}
+
+// writeWatchDirs writes the directories of the packages of the graph outside
+// GOROOT to the file named by watchDirsEnv, if it is set.
+func writeWatchDirs(pkgKeys []string) {
+	path := os.Getenv(watchDirsEnv)
+	if path == "" {
+		return
+	}
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, name := range pkgKeys {
+		pkg := pkgs[name]
+		if pkg.Goroot || pkg.Dir == "" || seen[pkg.Dir] {
+			continue
+		}
+		seen[pkg.Dir] = true
+		dirs = append(dirs, pkg.Dir)
+	}
+	sort.Strings(dirs)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(dirs, "\n")+"\n"), 0644); err != nil {
+		log.Printf("failed to record the directories to watch: %s", err)
+	}
+}
+
+// watchedFiles returns the modification times of the Go files in dirs and of
+// the go.mod and go.sum files in cwd.
+func watchedFiles(cwd string, dirs []string) map[string]time.Time {
+	files := make(map[string]time.Time)
+	for _, name := range []string{"go.mod", "go.sum"} {
+		path := filepath.Join(cwd, name)
+		if fi, err := os.Stat(path); err == nil {
+			files[path] = fi.ModTime()
+		}
+	}
+	for _, dir := range dirs {
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+				files[filepath.Join(dir, fi.Name())] = fi.ModTime()
+			}
+		}
+	}
+	return files
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, t := range a {
+		if u, ok := b[path]; !ok || !u.Equal(t) {
+			return false
+		}
+	}
+	return true
+}
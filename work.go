@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workFile is the subset of a go.work file that godepgraph cares about.
+type workFile struct {
+	Path string
+	Dir  string
+	Use  []string // module directories, relative to Dir
+}
+
+// findGoWork returns the go.work file that the go command would use for
+// commands run in dir: the one named by $GOWORK, or the first one found
+// walking up from dir. It returns the empty string if workspace mode is off
+// or no go.work file is found.
+func findGoWork(dir string) string {
+	switch env := os.Getenv("GOWORK"); env {
+	case "off":
+		return ""
+	case "":
+	default:
+		return env
+	}
+	dir = filepath.Clean(dir)
+	for {
+		p := filepath.Join(dir, "go.work")
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readGoWork parses the use directives of the go.work file at path.
+func readGoWork(path string) (*workFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	wf := &workFile{Path: path, Dir: filepath.Dir(path)}
+	inUse := false
+	s := bufio.NewScanner(f)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields, err := modFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineno, err)
+		}
+		switch {
+		case len(fields) == 0:
+		case inUse && fields[0] == ")":
+			inUse = false
+		case inUse:
+			wf.Use = append(wf.Use, fields[0])
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inUse = true
+		case fields[0] == "use" && len(fields) == 2:
+			wf.Use = append(wf.Use, fields[1])
+		}
+	}
+	return wf, s.Err()
+}
+
+// modFiles returns the go.mod files of the workspace modules. Modules whose
+// go.mod cannot be read are skipped with a warning.
+func (wf *workFile) modFiles() []*modFile {
+	var mods []*modFile
+	for _, use := range wf.Use {
+		dir := use
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(wf.Dir, dir)
+		}
+		mf, err := readGoMod(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping workspace module %s: %s\n", use, err)
+			continue
+		}
+		mods = append(mods, mf)
+	}
+	return mods
+}
+
+// useWorkspace makes all package resolution happen in the context of the
+// workspace wf, so that packages of sibling modules resolve no matter which
+// directory godepgraph is run from.
+func useWorkspace(wf *workFile) error {
+	abs, err := filepath.Abs(wf.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv("GOWORK", abs); err != nil {
+		return err
+	}
+	buildContext.Dir = filepath.Dir(abs)
+	return nil
+}